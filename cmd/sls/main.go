@@ -36,6 +36,7 @@ import (
 	"github.com/namsral/flag"
 
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
 	"github.com/gorilla/mux"
 )
 
@@ -49,17 +50,26 @@ type Route struct {
 type Routes []Route
 
 const (
-	API_ROOT      = "/v1"
-	API_READINESS = API_ROOT + "/readiness"
-	API_LIVENESS  = API_ROOT + "/liveness"
-	API_HEALTH    = API_ROOT + "/health"
-	API_READY     = API_ROOT + "/ready" // DEPREICATED
-	API_VERSION   = API_ROOT + "/version"
-	API_HARDWARE  = API_ROOT + "/hardware"
-	API_NETWORKS  = API_ROOT + "/networks"
-	API_SEARCH    = API_ROOT + "/search"
-	API_DUMPSTATE = API_ROOT + "/dumpstate"
-	API_LOADSTATE = API_ROOT + "/loadstate"
+	API_ROOT          = "/v1"
+	API_READINESS     = API_ROOT + "/readiness"
+	API_LIVENESS      = API_ROOT + "/liveness"
+	API_HEALTH        = API_ROOT + "/health"
+	API_READY         = API_ROOT + "/ready" // DEPREICATED
+	API_VERSION       = API_ROOT + "/version"
+	API_HARDWARE      = API_ROOT + "/hardware"
+	API_NETWORKS      = API_ROOT + "/networks"
+	API_SEARCH        = API_ROOT + "/search"
+	API_DUMPSTATE     = API_ROOT + "/dumpstate"
+	API_LOADSTATE     = API_ROOT + "/loadstate"
+	API_SCHEMAS       = API_ROOT + "/schemas"
+	API_RESOLVE       = API_ROOT + "/resolve"
+	API_NIDS          = API_ROOT + "/nids"
+	API_POWER         = API_ROOT + "/power"
+	API_RESTORE       = API_ROOT + "/restore"
+	API_DIFF          = API_ROOT + "/diff"
+	API_EVENTS        = API_ROOT + "/events"
+	API_SUBSCRIPTIONS = API_ROOT + "/subscriptions"
+	API_SNAPSHOTS     = API_ROOT + "/snapshots"
 )
 
 var httpAddr string
@@ -67,6 +77,11 @@ var debugLevel int
 
 var vaultEnabled bool
 var vaultKeypath string
+var validationMode string
+var referenceMode string
+var loadStateSnapshots bool
+var loadStateSnapshotsKeep int
+var subscriptionNetworks string
 
 var compCredStore compcredentials.CompCredStore
 var Running = true
@@ -117,6 +132,11 @@ func generateRoutes() Routes {
 			API_HARDWARE,
 			doHardwarePost,
 		},
+		Route{"doHardwareBulkPost",
+			strings.ToUpper("Post"),
+			API_HARDWARE + "/bulk",
+			doHardwareBulkPost,
+		},
 		Route{"doHardwareGet",
 			strings.ToUpper("Get"),
 			API_HARDWARE,
@@ -127,16 +147,75 @@ func generateRoutes() Routes {
 			API_HARDWARE + "/{xname}",
 			doHardwareObjGet,
 		},
+		Route{"doHardwareObjTreeGet",
+			strings.ToUpper("Get"),
+			API_HARDWARE + "/{xname}/tree",
+			doHardwareObjTreeGet,
+		},
+		Route{"doHardwareObjNamesGet",
+			strings.ToUpper("Get"),
+			API_HARDWARE + "/{xname}/names",
+			doHardwareObjNamesGet,
+		},
+		Route{"doHardwareObjConnectionsGet",
+			strings.ToUpper("Get"),
+			API_HARDWARE + "/{xname}/connections",
+			doHardwareObjConnectionsGet,
+		},
+		Route{"doHardwareObjNetworksGet",
+			strings.ToUpper("Get"),
+			API_HARDWARE + "/{xname}/networks",
+			doHardwareObjNetworksGet,
+		},
+		Route{"doHardwareObjHistoryGet",
+			strings.ToUpper("Get"),
+			API_HARDWARE + "/{xname}/history",
+			doHardwareObjHistoryGet,
+		},
 		Route{"doHardwareObjPut",
 			strings.ToUpper("Put"),
 			API_HARDWARE + "/{xname}",
 			doHardwareObjPut,
 		},
+		Route{"doHardwareObjPatch",
+			strings.ToUpper("Patch"),
+			API_HARDWARE + "/{xname}",
+			doHardwareObjPatch,
+		},
 		Route{"doHardwareObjDelete",
 			strings.ToUpper("Delete"),
 			API_HARDWARE + "/{xname}",
 			doHardwareObjDelete,
 		},
+		Route{"doResolveGet",
+			strings.ToUpper("Get"),
+			API_RESOLVE + "/{name}",
+			doResolveGet,
+		},
+
+		// NIDs
+		Route{"doNIDsGet",
+			strings.ToUpper("Get"),
+			API_NIDS,
+			doNIDsGet,
+		},
+		Route{"doNIDsAllocatePost",
+			strings.ToUpper("Post"),
+			API_NIDS + "/allocate",
+			doNIDsAllocatePost,
+		},
+
+		// Power
+		Route{"doPowerUpstreamGet",
+			strings.ToUpper("Get"),
+			API_POWER + "/{xname}/upstream",
+			doPowerUpstreamGet,
+		},
+		Route{"doPowerDownstreamGet",
+			strings.ToUpper("Get"),
+			API_POWER + "/{xname}/downstream",
+			doPowerDownstreamGet,
+		},
 
 		// Networks
 		Route{"doNetworksGet",
@@ -154,6 +233,11 @@ func generateRoutes() Routes {
 			API_NETWORKS + "/{network}",
 			doNetworkObjGet,
 		},
+		Route{"doNetworkObjHistoryGet",
+			strings.ToUpper("Get"),
+			API_NETWORKS + "/{network}/history",
+			doNetworkObjHistoryGet,
+		},
 		Route{"doNetworkObjPut",
 			strings.ToUpper("Put"),
 			API_NETWORKS + "/{network}",
@@ -195,6 +279,92 @@ func generateRoutes() Routes {
 			API_LOADSTATE,
 			doLoadState,
 		},
+		Route{"doRestorePost",
+			strings.ToUpper("Post"),
+			API_RESTORE,
+			doRestorePost,
+		},
+		Route{"doDiffGet",
+			strings.ToUpper("Get"),
+			API_DIFF,
+			doDiffGet,
+		},
+		Route{"doDiffPost",
+			strings.ToUpper("Post"),
+			API_DIFF,
+			doDiffPost,
+		},
+		Route{"doEventsGet",
+			strings.ToUpper("Get"),
+			API_EVENTS,
+			doEventsGet,
+		},
+
+		// Subscriptions
+		Route{"doSubscriptionsGet",
+			strings.ToUpper("Get"),
+			API_SUBSCRIPTIONS,
+			doSubscriptionsGet,
+		},
+		Route{"doSubscriptionPost",
+			strings.ToUpper("Post"),
+			API_SUBSCRIPTIONS,
+			doSubscriptionPost,
+		},
+		Route{"doSubscriptionGet",
+			strings.ToUpper("Get"),
+			API_SUBSCRIPTIONS + "/{id}",
+			doSubscriptionGet,
+		},
+		Route{"doSubscriptionDelete",
+			strings.ToUpper("Delete"),
+			API_SUBSCRIPTIONS + "/{id}",
+			doSubscriptionDelete,
+		},
+		Route{"doSubscriptionDeadLettersGet",
+			strings.ToUpper("Get"),
+			API_SUBSCRIPTIONS + "/{id}/deadletters",
+			doSubscriptionDeadLettersGet,
+		},
+
+		// Snapshots
+		Route{"doSnapshotsGet",
+			strings.ToUpper("Get"),
+			API_SNAPSHOTS,
+			doSnapshotsGet,
+		},
+		Route{"doSnapshotPost",
+			strings.ToUpper("Post"),
+			API_SNAPSHOTS,
+			doSnapshotPost,
+		},
+		Route{"doSnapshotGet",
+			strings.ToUpper("Get"),
+			API_SNAPSHOTS + "/{name}",
+			doSnapshotGet,
+		},
+		Route{"doSnapshotDelete",
+			strings.ToUpper("Delete"),
+			API_SNAPSHOTS + "/{name}",
+			doSnapshotDelete,
+		},
+		Route{"doSnapshotRestorePost",
+			strings.ToUpper("Post"),
+			API_SNAPSHOTS + "/{name}/restore",
+			doSnapshotRestorePost,
+		},
+
+		// Schemas
+		Route{"doSchemasGet",
+			strings.ToUpper("Get"),
+			API_SCHEMAS,
+			doSchemasGet,
+		},
+		Route{"doSchemaGet",
+			strings.ToUpper("Get"),
+			API_SCHEMAS + "/{type}",
+			doSchemaGet,
+		},
 	}
 }
 
@@ -219,6 +389,38 @@ func envVars() {
 			debugLevel = 0
 		}
 	}
+	envstr = os.Getenv("SLS_VALIDATION_MODE")
+	if envstr != "" {
+		validationMode = envstr
+	}
+	envstr = os.Getenv("SLS_REFERENCE_MODE")
+	if envstr != "" {
+		referenceMode = envstr
+	}
+	envstr = os.Getenv("SLS_SUBSCRIPTION_NETWORKS")
+	if envstr != "" {
+		subscriptionNetworks = envstr
+	}
+	envstr = os.Getenv("SLS_LOADSTATE_SNAPSHOTS")
+	if envstr != "" {
+		var err error
+		loadStateSnapshots, err = strconv.ParseBool(envstr)
+		if err != nil {
+			log.Printf("Setting env var SLS_LOADSTATE_SNAPSHOTS bad value (%s), setting to true.\n",
+				envstr)
+			loadStateSnapshots = true
+		}
+	}
+	envstr = os.Getenv("SLS_LOADSTATE_SNAPSHOTS_KEEP")
+	if envstr != "" {
+		var err error
+		loadStateSnapshotsKeep, err = strconv.Atoi(envstr)
+		if err != nil {
+			log.Printf("Setting env var SLS_LOADSTATE_SNAPSHOTS_KEEP bad value (%s), setting to 10.\n",
+				envstr)
+			loadStateSnapshotsKeep = 10
+		}
+	}
 }
 
 func main() {
@@ -233,9 +435,32 @@ func main() {
 	flag.BoolVar(&vaultEnabled, "vault_enabled", true, "Should vault be used for credentials?")
 	flag.StringVar(&vaultKeypath, "vault_keypath", "secret/hms-creds",
 		"Keypath for Vault credentials.")
+	flag.StringVar(&validationMode, "validation_mode", string(datastore.ValidationLenient),
+		"How to handle hardware with invalid ExtraProperties: strict rejects it, lenient only logs it.")
+	flag.StringVar(&referenceMode, "reference_mode", string(datastore.ReferencesIgnore),
+		"How to handle connectors and NICs linked to hardware that does not exist: ignore, report (log) or reject.")
+	flag.StringVar(&subscriptionNetworks, "subscription_networks", "",
+		"Comma separated private networks, in CIDR notation, subscribers can be in.")
+	flag.BoolVar(&loadStateSnapshots, "loadstate_snapshots", true,
+		"Should a snapshot be taken before every /loadstate?")
+	flag.IntVar(&loadStateSnapshotsKeep, "loadstate_snapshots_keep", 10,
+		"How many of the snapshots taken before /loadstate to keep, 0 keeps them all.")
 	flag.Parse()
 	envVars()
 
+	if err := datastore.SetValidationMode(datastore.ValidationMode(validationMode)); err != nil {
+		log.Fatalf("Invalid validation mode %s: %v", validationMode, err)
+	}
+	if err := datastore.SetReferenceMode(datastore.ReferenceMode(referenceMode)); err != nil {
+		log.Fatalf("Invalid reference mode %s: %v", referenceMode, err)
+	}
+	if err := datastore.SetLoadStateSnapshots(loadStateSnapshots, loadStateSnapshotsKeep); err != nil {
+		log.Fatalf("Invalid number of /loadstate snapshots to keep %d: %v", loadStateSnapshotsKeep, err)
+	}
+	if err := datastore.SetSubscriptionNetworks(subscriptionNetworks); err != nil {
+		log.Fatalf("Invalid subscription networks %s: %v", subscriptionNetworks, err)
+	}
+
 	// Hook up the API routes
 	routes := generateRoutes()
 	router := newRouter(routes)
@@ -245,12 +470,17 @@ func main() {
 		Handler: router,
 	}
 
+	// Event streams never go idle on their own, end them to let Shutdown finish.
+	srv.RegisterOnShutdown(datastore.StopEvents)
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	idleConnsClosed := make(chan struct{})
+	subscriptionsStop := make(chan struct{})
 	go func() {
 		<-c
 		Running = false
+		close(subscriptionsStop)
 
 		// Gracefully shutdown the HTTP server.
 		if err := srv.Shutdown(context.Background()); err != nil {
@@ -276,6 +506,8 @@ func main() {
 		setupVault()
 	}
 
+	go datastore.RunSubscriptions(subscriptionsStop)
+
 	log.Printf("INFO: Beginning to serve HTTP")
 	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 		// Error starting or closing listener:
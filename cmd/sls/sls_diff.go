@@ -0,0 +1,149 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/Cray-HPE/hms-sls/internal/diff"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+const unifiedDiffContentType = "text/x-diff"
+
+// Send a diff as JSON, or in the unified format if asked for with
+// format=unified.
+
+func sendDiff(w http.ResponseWriter, r *http.Request, result sls_common.SLSDiff) {
+	var ba []byte
+	contentType := "application/json"
+
+	switch r.FormValue("format") {
+	case "", "json":
+		var err error
+		ba, err = json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: JSON marshal of diff failed:", err)
+			pdet := base.NewProblemDetails("about: blank",
+				"Internal Server Error",
+				"JSON marshal error",
+				r.URL.Path, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+	case "unified":
+		ba = []byte(diff.Unified(result))
+		contentType = unifiedDiffContentType
+	default:
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"format must be json or unified",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+// Send the problem with a diff that failed.
+
+func sendDiffError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, datastore.InvalidDiff) {
+		log.Println("ERROR: Invalid diff request:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+	} else if errors.Is(err, datastore.VersionUnavailable) {
+		log.Println("ERROR: Unable to diff:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			err.Error(),
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+	} else {
+		log.Println("ERROR: Unable to diff:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to diff",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+	}
+}
+
+//  /diff GET API
+
+func doDiffGet(w http.ResponseWriter, r *http.Request) {
+	result, err := datastore.GetDiff(r.FormValue("from"), r.FormValue("to"))
+	if err != nil {
+		sendDiffError(w, r, err)
+		return
+	}
+
+	sendDiff(w, r, result)
+}
+
+//  /diff POST API
+
+func doDiffPost(w http.ResponseWriter, r *http.Request) {
+	var state sls_common.SLSState
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR: Unable to read request body:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to read request body",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal(body, &state)
+	if err != nil {
+		log.Println("ERROR: Unable to unmarshal SLS state:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Unable to unmarshal SLS state",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	result, err := datastore.DiffState(state)
+	if err != nil {
+		sendDiffError(w, r, err)
+		return
+	}
+
+	sendDiff(w, r, result)
+}
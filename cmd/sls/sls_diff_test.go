@@ -0,0 +1,174 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiffTestSuite struct {
+	apiTestSuite
+}
+
+func (suite *DiffTestSuite) TearDownSuite() {
+	req, _ := http.NewRequest("DELETE", nwURLBase+"/networks/T6200", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	hwDBClear()
+}
+
+func (suite *DiffTestSuite) getDiff(response *httptest.ResponseRecorder) sls_common.SLSDiff {
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var result sls_common.SLSDiff
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &result))
+	return result
+}
+
+func (suite *DiffTestSuite) TestDiff() {
+	payload := `[
+		{"Parent":"x6200c0s0b0","Xname":"x6200c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6200,"Role":"Compute"}},
+		{"Parent":"x6200c0s1b0","Xname":"x6200c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6201,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/hardware", "")
+	from, err := strconv.Unquote(response.Header().Get("ETag"))
+	suite.Require().NoError(err)
+
+	response = suite.do("PATCH", "/hardware/x6200c0s0b0n0", `{"ExtraProperties":{"NID":6210,"Role":null}}`)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/hardware/x6200c0s1b0n0", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("POST", "/networks",
+		`{"Name":"T6200","FullName":"Diff test network","IPRanges":["10.162.0.0/24"],"Type":"ethernet"}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/hardware", "")
+	to, err := strconv.Unquote(response.Header().Get("ETag"))
+	suite.Require().NoError(err)
+
+	result := suite.getDiff(suite.do("GET", "/diff?from="+from+"&to="+to, ""))
+	suite.Equal(from, result.From)
+	suite.Equal(to, result.To)
+	suite.Empty(result.Hardware.Added)
+	suite.Require().Len(result.Hardware.Removed, 1)
+	suite.Equal("x6200c0s1b0n0", result.Hardware.Removed[0].Xname)
+	suite.Empty(result.Hardware.Removed[0].LastUpdated)
+	suite.Equal([]sls_common.ObjectDiff{{
+		Name: "x6200c0s0b0n0",
+		Changes: []sls_common.FieldChange{
+			{Path: "/ExtraProperties/NID", Op: "replace", From: 6200.0, To: 6210.0},
+			{Path: "/ExtraProperties/Role", Op: "remove", From: "Compute"},
+		},
+	}}, result.Hardware.Modified)
+	suite.Require().Len(result.Networks.Added, 1)
+	suite.Equal("T6200", result.Networks.Added[0].Name)
+
+	// to defaults to the current version
+	suite.Equal(result, suite.getDiff(suite.do("GET", "/diff?from="+from, "")))
+
+	// Backwards the other way around
+	reversed := suite.getDiff(suite.do("GET", "/diff?from="+to+"&to="+from, ""))
+	suite.Equal(result.Hardware.Removed, reversed.Hardware.Added)
+	suite.Equal(result.Networks.Added, reversed.Networks.Removed)
+
+	response = suite.do("GET", "/diff?from="+from+"&to="+to+"&format=unified", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Equal("text/x-diff", response.Header().Get("Content-Type"))
+	suite.Contains(response.Body.String(), "--- "+from+"\n+++ "+to+"\n")
+	suite.Contains(response.Body.String(),
+		"@@ hardware x6200c0s0b0n0 modified @@\n"+
+			"-/ExtraProperties/NID: 6200\n"+
+			"+/ExtraProperties/NID: 6210\n"+
+			"-/ExtraProperties/Role: \"Compute\"\n")
+	suite.Contains(response.Body.String(), "@@ hardware x6200c0s1b0n0 removed @@\n")
+	suite.Contains(response.Body.String(), "@@ network T6200 added @@\n")
+
+	for query, status := range map[string]int{
+		"":                              http.StatusBadRequest,
+		"?from=abc":                     http.StatusBadRequest,
+		"?from=" + from + "&to=abc":     http.StatusBadRequest,
+		"?from=" + from + "&format=xml": http.StatusBadRequest,
+		"?from=0":                       http.StatusNotFound,
+		"?from=" + strconv.Itoa(1<<30):  http.StatusNotFound,
+		"?from=" + from + "&to=0":       http.StatusNotFound,
+	} {
+		response = suite.do("GET", "/diff"+query, "")
+		suite.Equal(status, response.Code, "GET /diff%s: %s", query, response.Body.String())
+	}
+}
+
+func (suite *DiffTestSuite) TestDiffState() {
+	payload := `[
+		{"Parent":"x6201c0s0b0","Xname":"x6201c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6220,"Role":"Compute"}},
+		{"Parent":"x6201c0s1b0","Xname":"x6201c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6221,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/dumpstate", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var state sls_common.SLSState
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &state))
+
+	// Nothing changes if the dump is loaded back
+	result := suite.getDiff(suite.do("POST", "/diff", response.Body.String()))
+	suite.Equal("upload", result.To)
+	suite.Empty(result.Hardware.Added)
+	suite.Empty(result.Hardware.Removed)
+	suite.Empty(result.Hardware.Modified)
+	suite.Empty(result.Networks.Added)
+	suite.Empty(result.Networks.Removed)
+	suite.Empty(result.Networks.Modified)
+
+	delete(state.Hardware, "x6201c0s1b0n0")
+	node := state.Hardware["x6201c0s0b0n0"]
+	node.ExtraPropertiesRaw = map[string]interface{}{"NID": 6222, "Role": "Compute"}
+	state.Hardware["x6201c0s0b0n0"] = node
+	ba, err := json.Marshal(state)
+	suite.Require().NoError(err)
+
+	result = suite.getDiff(suite.do("POST", "/diff", string(ba)))
+	suite.Require().Len(result.Hardware.Removed, 1)
+	suite.Equal("x6201c0s1b0n0", result.Hardware.Removed[0].Xname)
+	suite.Equal([]sls_common.ObjectDiff{{
+		Name:    "x6201c0s0b0n0",
+		Changes: []sls_common.FieldChange{{Path: "/ExtraProperties/NID", Op: "replace", From: 6220.0, To: 6222.0}},
+	}}, result.Hardware.Modified)
+
+	response = suite.do("POST", "/diff", `{"Hardware":`)
+	suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+}
+
+func TestDiffSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
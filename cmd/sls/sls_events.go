@@ -0,0 +1,198 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+// How often to send a comment down a quiet event stream so proxies don't
+// time it out.
+
+const eventsKeepAlive = 30 * time.Second
+
+// Write events in the Server-Sent Events format, named by their entity type.
+// A version can make many changes, only the last one of each version has
+// an id, so a client resuming from it missed none of that version.
+
+func writeEvents(w http.ResponseWriter, changes []sls_common.Event) error {
+	for i, event := range changes {
+		ba, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "event: %s\n", event.EntityType)
+		if i == len(changes)-1 || changes[i+1].Version != event.Version {
+			fmt.Fprintf(w, "id: %d\n", event.Version)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", ba)
+	}
+
+	return nil
+}
+
+//  /events GET API
+
+func doEventsGet(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("ERROR: Response can't be streamed")
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Response can't be streamed",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	documents := r.FormValue("documents") == "true"
+
+	// Without a Last-Event-ID, only changes from now on are sent.
+	var lastVersion int64
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		version, err := getCollectionVersion()
+		if err != nil {
+			log.Println("ERROR: unable to get current version: ", err)
+			pdet := base.NewProblemDetails("about: blank",
+				"Internal Server Error",
+				"Failed to get version info from DB",
+				r.URL.Path, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		lastVersion = version
+	} else {
+		var err error
+		lastVersion, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			log.Println("ERROR: Invalid Last-Event-ID: ", lastEventID)
+			pdet := base.NewProblemDetails("about: blank",
+				"Bad Request",
+				"Last-Event-ID must be a version",
+				r.URL.Path, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+	}
+
+	// Subscribe before catching up, so nothing committed in between is missed.
+	subscriber, err := datastore.SubscribeEvents()
+	if err != nil {
+		log.Println("ERROR: Unable to subscribe to events: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to subscribe to events",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	defer datastore.UnsubscribeEvents(subscriber)
+
+	changes, current, err := datastore.GetEventsSince(lastVersion, documents)
+	if errors.Is(err, datastore.VersionUnavailable) {
+		log.Println("ERROR: Unable to resume events: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			err.Error(),
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to get events: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get events",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	// Versions that were caught up on may still be announced.
+	sent := make(map[int64]bool)
+	for _, event := range changes {
+		sent[event.Version] = true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	err = writeEvents(w, changes)
+	if err != nil {
+		log.Println("ERROR: Unable to send events: ", err)
+		return
+	}
+	// Nothing changed in the versions after the last event, and versions
+	// commit in order so none before the current one can still appear, a
+	// client can resume from it.
+	fmt.Fprintf(w, "id: %d\n\n", current)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case version, ok := <-subscriber:
+			// Either this client fell behind or SLS lost track of versions
+			// for a while, it can resume from the last id it got.
+			if !ok || version == 0 {
+				return
+			}
+			if sent[version] {
+				continue
+			}
+
+			changes, err := datastore.GetVersionEvents(version, documents)
+			if err != nil {
+				log.Println("ERROR: Unable to get events: ", err)
+				return
+			}
+			err = writeEvents(w, changes)
+			if err != nil {
+				log.Println("ERROR: Unable to send events: ", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
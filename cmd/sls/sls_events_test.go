@@ -0,0 +1,159 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventsTestSuite struct {
+	apiTestSuite
+}
+
+// One message of an event stream, its fields by name.
+type streamMessage map[string]string
+
+func (suite *EventsTestSuite) TearDownSuite() {
+	hwDBClear()
+}
+
+func (suite *EventsTestSuite) currentVersion() string {
+	response := suite.do("GET", "/hardware", "")
+	version, err := strconv.Unquote(response.Header().Get("ETag"))
+	suite.Require().NoError(err)
+	return version
+}
+
+// Read the next message from an event stream, skipping comments.
+func (suite *EventsTestSuite) next(stream *bufio.Reader) streamMessage {
+	message := make(streamMessage)
+	for {
+		line, err := stream.ReadString('\n')
+		suite.Require().NoError(err)
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && len(message) > 0 {
+			return message
+		}
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ": ", 2)
+		suite.Require().Len(parts, 2, "line %q", line)
+		message[parts[0]] = parts[1]
+	}
+}
+
+func (suite *EventsTestSuite) event(message streamMessage) sls_common.Event {
+	var event sls_common.Event
+	suite.Require().NoError(json.Unmarshal([]byte(message["data"]), &event), "message %v", message)
+	return event
+}
+
+func (suite *EventsTestSuite) TestEvents() {
+	from := suite.currentVersion()
+
+	response := suite.do("POST", "/hardware",
+		`{"Parent":"x6300c0s0b0","Xname":"x6300c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6300,"Role":"Compute"}}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	inserted := suite.currentVersion()
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/events?documents=true", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Last-Event-ID", from)
+	rsp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer rsp.Body.Close()
+	suite.Require().Equal(http.StatusOK, rsp.StatusCode)
+	suite.Equal("text/event-stream", rsp.Header.Get("Content-Type"))
+	stream := bufio.NewReader(rsp.Body)
+
+	// Catching up on the insert
+	message := suite.next(stream)
+	suite.Equal("hardware", message["event"])
+	suite.Equal(inserted, message["id"])
+	event := suite.event(message)
+	suite.Equal("x6300c0s0b0n0", event.Name)
+	suite.Equal("insert", event.Operation)
+	suite.Contains(message["data"], `"NID":6300`)
+
+	message = suite.next(stream)
+	suite.Equal(streamMessage{"id": inserted}, message)
+
+	// Then following along
+	response = suite.do("PATCH", "/hardware/x6300c0s0b0n0", `{"ExtraProperties":{"NID":6301}}`)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/hardware/x6300c0s0b0n0", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	message = suite.next(stream)
+	event = suite.event(message)
+	suite.Equal(strconv.FormatInt(event.Version, 10), message["id"])
+	suite.Equal("x6300c0s0b0n0", event.Name)
+	suite.Equal("update", event.Operation)
+	suite.Contains(message["data"], `"NID":6301`)
+
+	message = suite.next(stream)
+	event = suite.event(message)
+	suite.Equal("x6300c0s0b0n0", event.Name)
+	suite.Equal("delete", event.Operation)
+	suite.Nil(event.Document)
+}
+
+func (suite *EventsTestSuite) TestEvents_LastEventID() {
+	for lastEventID, status := range map[string]int{
+		"abc":                 http.StatusBadRequest,
+		"0":                   http.StatusNotFound,
+		strconv.Itoa(1 << 30): http.StatusNotFound,
+	} {
+		req, err := http.NewRequest("GET", nwURLBase+"/events", nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Last-Event-ID", lastEventID)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		suite.Equal(status, response.Code, "Last-Event-ID %s: %s", lastEventID, response.Body.String())
+	}
+}
+
+func TestEventsSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
@@ -24,9 +24,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
@@ -35,6 +37,7 @@ import (
 	"github.com/Cray-HPE/hms-sls/internal/database"
 
 	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	"github.com/gorilla/mux"
 )
 
@@ -137,6 +140,16 @@ func doHardwarePost(w http.ResponseWriter, r *http.Request) {
 	// Write these into the DB
 
 	err = datastore.SetXname(jdata.Xname, jdata)
+	if errors.Is(err, datastore.InvalidHardware) {
+		log.Printf("ERROR, invalid component '%s': %s\n", jdata.Xname, err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.AliasInUse) || errors.Is(err, database.NIDInUse) {
+		log.Printf("ERROR, component '%s' has an alias or NID in use: %s\n", jdata.Xname, err)
+		sendJsonRsp(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		log.Printf("ERROR inserting component '%s' into DB: %s\n", jdata.Xname, err)
 		sendJsonRsp(w, http.StatusInternalServerError, "error inserting object into DB")
@@ -146,10 +159,100 @@ func doHardwarePost(w http.ResponseWriter, r *http.Request) {
 	sendJsonRsp(w, http.StatusOK, "inserted new entry")
 }
 
+//  /hardware/bulk POST API
+
+func doHardwareBulkPost(w http.ResponseWriter, r *http.Request) {
+	var jdata []sls_common.GenericHardware
+
+	// Figure out whether this is all or nothing (the default) or best effort
+
+	var atomic bool
+	switch r.FormValue("mode") {
+	case "", "atomic":
+		atomic = true
+	case "best-effort":
+		atomic = false
+	default:
+		log.Printf("ERROR, invalid bulk mode: '%s'\n", r.FormValue("mode"))
+		sendJsonRsp(w, http.StatusBadRequest, "invalid mode, must be atomic or best-effort")
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR reading request body:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "error reading REST request")
+		return
+	}
+	err = json.Unmarshal(body, &jdata)
+	if err != nil {
+		log.Println("ERROR unmarshalling request body:", err)
+		sendJsonRsp(w, http.StatusBadRequest, "error decoding JSON")
+		return
+	}
+	if len(jdata) == 0 {
+		log.Printf("ERROR, bulk request with no hardware.\n")
+		sendJsonRsp(w, http.StatusBadRequest, "no hardware objects in request")
+		return
+	}
+
+	// Validate and write everything, each item gets its own result
+
+	results, version, err := datastore.SetXnames(jdata, atomic)
+
+	code := http.StatusOK
+	if errors.Is(err, datastore.InvalidHardware) {
+		log.Printf("ERROR, bulk request has invalid hardware, nothing written.\n")
+		code = http.StatusBadRequest
+	} else if errors.Is(err, database.AliasInUse) || errors.Is(err, database.NIDInUse) {
+		log.Println("ERROR, bulk request has an alias or NID in use, nothing written:", err)
+		code = http.StatusConflict
+	} else if err != nil {
+		log.Println("ERROR writing bulk hardware to DB:", err)
+		code = http.StatusInternalServerError
+	}
+
+	ba, baerr := json.Marshal(sls_common.BulkHardwareResponse{
+		Atomic:  atomic,
+		Version: version,
+		Results: results,
+	})
+	if baerr != nil {
+		log.Println("ERROR: JSON marshal of /hardware/bulk failed:", baerr)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(ba)
+}
+
 //  /hardware GET API
 
 func doHardwareGet(w http.ResponseWriter, r *http.Request) {
-	hwList, err := datastore.GetAllXnameObjects()
+	// Grab the version before the data so the ETag can never be newer
+	// than what is returned.
+	version, asOf, status, err := getReadVersion(r)
+	if status == http.StatusInternalServerError {
+		log.Println("ERROR getting current version from DB:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed version DB query")
+		return
+	} else if err != nil {
+		log.Println("ERROR, invalid asOfVersion or asOf:", err)
+		sendJsonRsp(w, status, err.Error())
+		return
+	}
+	if checkNotModified(w, r, version) {
+		return
+	}
+
+	var hwList []sls_common.GenericHardware
+	if asOf {
+		hwList, err = datastore.GetAllHardwareAsOf(version)
+	} else {
+		hwList, err = datastore.GetAllXnameObjects()
+	}
 	if err != nil {
 		log.Println("ERROR getting all /hardware objects from DB:", err)
 		sendJsonRsp(w, http.StatusInternalServerError, "failed hardware DB query")
@@ -162,6 +265,7 @@ func doHardwareGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(ba)
@@ -183,7 +287,7 @@ func doHardwareObjGet(w http.ResponseWriter, r *http.Request) {
 	// Fetch the item and all of its descendants from the database.  If
 	// the item does not exist, error.
 
-	cmp, err := datastore.GetXname(xname)
+	cmp, version, err := datastore.GetXnameAndVersion(xname)
 	if cmp == nil {
 		log.Printf("ERROR, requested component not found in DB: '%s'\n",
 			xname)
@@ -196,7 +300,260 @@ func doHardwareObjGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return the HW component.
+	// Return the HW component.  Its Children can change without its version
+	// changing, so If-None-Match isn't honoured here.
+
+	w.Header().Set("ETag", versionETag(version))
+	sendJsonCompRsp(w, *cmp)
+}
+
+//  /hardware/{xname}/tree GET API
+
+func doHardwareObjTreeGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	// No depth means the whole subtree.
+	depth := -1
+	if depthStr := r.FormValue("depth"); depthStr != "" {
+		var err error
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil || depth < 0 {
+			log.Printf("ERROR, invalid depth in request URL: '%s'\n", depthStr)
+			sendJsonRsp(w, http.StatusBadRequest, "depth must be a non-negative integer")
+			return
+		}
+	}
+
+	var types []sls_common.HMSStringType
+	for _, hmsType := range r.Form["type"] {
+		types = append(types, sls_common.HMSStringType(hmsType))
+	}
+
+	var rsp interface{}
+	var found bool
+	var err error
+	switch r.FormValue("format") {
+	case "", "nested":
+		var tree *sls_common.GenericHardwareTree
+		tree, err = datastore.GetXnameTree(xname, depth, types)
+		rsp, found = tree, tree != nil
+	case "flat":
+		rsp, found, err = datastore.GetXnameDescendants(xname, depth, types)
+	default:
+		log.Printf("ERROR, invalid format in request URL: '%s'\n", r.FormValue("format"))
+		sendJsonRsp(w, http.StatusBadRequest, "format must be nested or flat")
+		return
+	}
+	if errors.Is(err, datastore.UnknownType) || errors.Is(err, datastore.UnsupportedType) {
+		log.Println("ERROR, invalid type in request URL:", err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if !found {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(rsp)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of hardware tree failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /hardware/{xname}/names GET API
+
+func doHardwareObjNamesGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	names, err := datastore.GetXnameNames(xname)
+	if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if names == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(names)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of hardware names failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /hardware/{xname}/connections GET API
+
+func doHardwareObjConnectionsGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	connections, err := datastore.GetXnameConnections(xname)
+	if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if connections == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(connections)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of hardware connections failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /hardware/{xname}/networks GET API
+
+func doHardwareObjNetworksGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	networks, err := datastore.GetXnameNetworks(xname, r.FormValue("profile"))
+	if errors.Is(err, datastore.InvalidNetworkProfile) {
+		log.Printf("ERROR, invalid profile in request URL: '%s'\n", r.FormValue("profile"))
+		sendJsonRsp(w, http.StatusBadRequest, "profile must be cn or ncn")
+		return
+	} else if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if networks == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(networks)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of hardware networks failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /hardware/{xname}/history GET API
+
+func doHardwareObjHistoryGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	limit, offset, err := getPage(r)
+	if err != nil {
+		log.Println("ERROR, invalid limit or offset in request URL:", err)
+		sendJsonRsp(w, http.StatusBadRequest, "limit and offset must be integers")
+		return
+	}
+
+	history, err := datastore.GetXnameHistory(xname, limit, offset)
+	if errors.Is(err, datastore.InvalidPage) {
+		log.Println("ERROR, invalid limit or offset in request URL:", err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if history == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(history)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of hardware history failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /resolve/{name} GET API
+
+func doResolveGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	cmp, err := datastore.ResolveName(name)
+	if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if cmp == nil {
+		log.Printf("ERROR, no component has the alias '%s'\n", name)
+		sendJsonRsp(w, http.StatusNotFound, "no component has that alias")
+		return
+	}
 
 	sendJsonCompRsp(w, *cmp)
 }
@@ -333,47 +690,70 @@ func doHardwareObjPut(w http.ResponseWriter, r *http.Request) {
 		cmp.ExtraPropertiesRaw = jdata.ExtraPropertiesRaw
 	}
 
-	// Write back to the DB
+	// Write back to the DB, as long as the object still matches any
+	// If-Match the client sent.
 
-	err = datastore.SetXname(cmp.Xname, cmp)
+	version, err := datastore.SetXnameIfMatch(cmp.Xname, cmp, getIfMatch(r))
+	if errors.Is(err, database.PreconditionFailed) {
+		log.Printf("ERROR, '%s' does not match If-Match: %s\n", xname, r.Header.Get("If-Match"))
+		sendJsonRsp(w, http.StatusPreconditionFailed, "component does not match If-Match")
+		return
+	}
+	if errors.Is(err, datastore.InvalidHardware) {
+		log.Printf("ERROR, invalid component '%s': %s\n", xname, err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.AliasInUse) || errors.Is(err, database.NIDInUse) {
+		log.Printf("ERROR, component '%s' has an alias or NID in use: %s\n", xname, err)
+		sendJsonRsp(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		log.Println("ERROR updating DB:", err)
 		sendJsonRsp(w, http.StatusInternalServerError, "DB update failed")
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(version))
 	sendJsonCompRsp(w, cmp)
 }
 
-// Recursive function used to get all components of a component
-// tree and put them into a linear slice.
+//  /hardware/{xname} PATCH API
 
-func getCompTree(gcomp sls_common.GenericHardware, compList *[]sls_common.GenericHardware) error {
-	for _, cxname := range gcomp.Children {
-		cmp, err := datastore.GetXname(cxname)
-		if cmp == nil {
-			log.Printf("WARNING: child component '%s' not found in DB.\n",
-				cxname)
-			continue
-		}
-		if err != nil {
-			return err
-		}
-		err = getCompTree(*cmp, compList)
-		if err != nil {
-			return err
-		}
+func doHardwareObjPatch(w http.ResponseWriter, r *http.Request) {
+	// Decode the URL to get the XName
+
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, PATCH request with xname: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR reading request body:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "unable to read request body")
+		return
 	}
 
-	*compList = append(*compList, gcomp)
-	return nil
+	cmp, version, err := datastore.PatchXname(xname, r.Header.Get("Content-Type"), body, getIfMatch(r))
+	if err != nil {
+		log.Printf("ERROR patching '%s': %s\n", xname, err)
+		sendPatchErrorRsp(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(version))
+	sendJsonCompRsp(w, cmp)
 }
 
 //  /hardware/{xname} DELETE API
 
 func doHardwareObjDelete(w http.ResponseWriter, r *http.Request) {
-	var compList []sls_common.GenericHardware
-
 	// Decode the URL to get the XName
 	vars := mux.Vars(r)
 	xname := base.NormalizeHMSCompID(vars["xname"])
@@ -384,45 +764,51 @@ func doHardwareObjDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch the item and all of its descendants from the database.  If
-	// the item does not exist, error.
-
-	cmp, err := datastore.GetXname(xname)
-	if err != nil {
-		log.Println("ERROR, error in DB query:", err)
-		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
-		return
+	dryRun := false
+	if dryRunStr := r.FormValue("dryRun"); dryRunStr != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			log.Printf("ERROR, invalid dryRun in request URL: '%s'\n", dryRunStr)
+			sendJsonRsp(w, http.StatusBadRequest, "dryRun must be true or false")
+			return
+		}
 	}
-	if cmp == nil {
+
+	// Delete the item and all of its descendants from the database in one
+	// go, so either all of them are gone or none are.
+
+	result, err := datastore.DeleteXnameTree(xname, getIfMatch(r), dryRun)
+	if errors.Is(err, database.NoSuch) {
 		log.Printf("ERROR, no '%s' component in DB.\n", xname)
 		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
 		return
-	}
-
-	err = getCompTree(*cmp, &compList)
-	if err != nil {
-		log.Println("ERROR, error in comp tree DB query:", err)
-		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+	} else if errors.Is(err, database.PreconditionFailed) {
+		log.Printf("ERROR, '%s' does not match If-Match: %s\n", xname, r.Header.Get("If-Match"))
+		sendJsonRsp(w, http.StatusPreconditionFailed, "component does not match If-Match")
+		return
+	} else if err != nil {
+		log.Println("ERROR, failed to delete component tree:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to delete entry in DB")
 		return
 	}
 
-	// Delete the item(s) from the database
-
-	ok := true
-	for _, component := range compList {
-		log.Printf("INFO: Deleting: '%s'\n", component.Xname)
-		err = datastore.DeleteXname(component.Xname)
-		if err != nil {
-			ok = false
-		}
+	if !dryRun {
+		log.Printf("INFO: Deleted: %v\n", result.Xnames)
+		sendJsonRsp(w, http.StatusOK, "deleted entry and its descendants")
+		return
 	}
 
-	if !ok {
-		sendJsonRsp(w, http.StatusInternalServerError, "failed to delete entry in DB")
+	ba, err := json.Marshal(result)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of delete preview failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
 		return
 	}
 
-	sendJsonRsp(w, http.StatusOK, "deleted entry and its descendants")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
 }
 
 //  /search/hardware GET API
@@ -494,7 +880,7 @@ func doHardwareSearch(w http.ResponseWriter, r *http.Request) {
 
 	hardware.ExtraPropertiesRaw = properties
 
-	returnedHardware, err := datastore.SearchGenericHardware(hardware)
+	returnedHardware, err := datastore.SearchGenericHardware(hardware, r.FormValue("filter"))
 	if err == database.NoSuch {
 		log.Println("ERROR: ", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -503,6 +889,14 @@ func doHardwareSearch(w http.ResponseWriter, r *http.Request) {
 			r.URL.Path, http.StatusNotFound)
 		base.SendProblemDetails(w, pdet, 0)
 		return
+	} else if errors.Is(err, search.InvalidFilter) {
+		log.Println("ERROR: Invalid search filter:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
 	} else if err != nil {
 		log.Println("ERROR: Failed to search for hardware:", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -31,6 +31,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -458,6 +459,121 @@ func (suite *HardwareSearchTestSuite) TestSearchExtraProperties() {
 	}
 }
 
+func (suite *HardwareSearchTestSuite) TestSearchFilter() {
+	tests := []struct {
+		filter         string
+		query          string
+		expectedStatus int
+		expectedXnames []string
+	}{{
+		filter:         "NID < 5 OR NID > 100010",
+		expectedStatus: http.StatusOK,
+		expectedXnames: []string{
+			"x3000c0s13b1n0",
+			"x3000c0s13b2n0",
+			"x3000c0s13b3n0",
+			"x3000c0s13b4n0",
+			"x3000c0s1b0n0",
+			"x3000c0s2b0n0",
+		},
+	}, {
+		filter:         "Role = Management AND SubRole IN (Master, Storage) AND NOT xname IN (x3000c0s1b0n0, X3000C0S10B0N0)",
+		expectedStatus: http.StatusOK,
+		expectedXnames: []string{
+			"x3000c0s2b0n0",
+			"x3000c0s3b0n0",
+			"x3000c0s11b0n0",
+			"x3000c0s12b0n0",
+		},
+	}, {
+		filter:         "xname LIKE x3000c0w32j4?",
+		expectedStatus: http.StatusOK,
+		expectedXnames: []string{
+			"x3000c0w32j41",
+			"x3000c0w32j42",
+			"x3000c0w32j43",
+			"x3000c0w32j47",
+			"x3000c0w32j48",
+		},
+	}, {
+		// The filter is combined with the other query parameters.
+		filter:         "SubRole != Worker",
+		query:          "extra_properties.Role=Management",
+		expectedStatus: http.StatusOK,
+		expectedXnames: []string{
+			"x3000c0s1b0n0",
+			"x3000c0s2b0n0",
+			"x3000c0s3b0n0",
+			"x3000c0s10b0n0",
+			"x3000c0s11b0n0",
+			"x3000c0s12b0n0",
+		},
+	}, {
+		// Values are never part of the query itself.
+		filter:         "xname = \"x3000' OR '1'='1\"",
+		expectedStatus: http.StatusOK,
+		expectedXnames: []string{},
+	}, {
+		filter:         "NID >= ",
+		expectedStatus: http.StatusBadRequest,
+	}, {
+		filter:         "(Role = Management",
+		expectedStatus: http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		searchURL := hwSearchURLBase + "?filter=" + url.QueryEscape(test.filter)
+		if test.query != "" {
+			searchURL += "&" + test.query
+		}
+		suite.T().Logf("Search URL: %s", searchURL)
+
+		returnedHardware, pd := suite.doSearch(searchURL, test.expectedStatus)
+		if test.expectedStatus == http.StatusOK {
+			suite.Nil(pd)
+		} else {
+			suite.NotNil(pd)
+		}
+
+		suite.verifyReturnedHardware(returnedHardware, test.expectedXnames)
+	}
+}
+
+func (suite *HardwareSearchTestSuite) TestSearchExtraPropertiesPath() {
+	tests := []struct {
+		query          string
+		expectedXnames []string
+	}{{
+		query:          "extra_properties.Networks.cn.HMN.VLan=1513",
+		expectedXnames: []string{"x3000"},
+	}, {
+		query:          "extra_properties.Networks.cn.NMN.CIDR=10.100.0.0/22&type=comptype_cabinet",
+		expectedXnames: []string{"x1000"},
+	}, {
+		query:          "extra_properties.Networks.ncn.HMN.VLan=1513&extra_properties.Networks.cn.HMN.VLan=1000",
+		expectedXnames: []string{},
+	}, {
+		query:          "filter=" + url.QueryEscape("Networks.cn.HMN.VLan < 1500"),
+		expectedXnames: []string{"x1000"},
+	}, {
+		query:          "filter=" + url.QueryEscape("type = comptype_cabinet AND NOT Networks.ncn.HMN.VLan = 1513"),
+		expectedXnames: []string{"x1000"},
+	}}
+
+	for _, test := range tests {
+		searchURL := hwSearchURLBase + "?" + test.query
+		suite.T().Logf("Search URL: %s", searchURL)
+
+		returnedHardware, pd := suite.doSearch(searchURL, http.StatusOK)
+		suite.Nil(pd)
+
+		suite.verifyReturnedHardware(returnedHardware, test.expectedXnames)
+	}
+
+	_, pd := suite.doSearch(hwSearchURLBase+"?filter="+url.QueryEscape("Networks[x].cn = 1"), http.StatusBadRequest)
+	suite.NotNil(pd)
+}
+
 func TestHardwareSearchTestSuite(t *testing.T) {
 	suite.Run(t, new(HardwareSearchTestSuite))
 }
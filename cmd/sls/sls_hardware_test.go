@@ -34,6 +34,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/suite"
@@ -897,6 +898,35 @@ func dbInit() {
 	dbInitOK = true
 }
 
+// apiTestSuite is embedded by the suites that go through router. It sets up router and the database and clears out
+// hardware left over from earlier runs.
+type apiTestSuite struct {
+	suite.Suite
+}
+
+func (suite *apiTestSuite) SetupSuite() {
+	if router == nil {
+		routes = generateRoutes()
+		router = newRouter(routes)
+	}
+
+	dbInit()
+	hwDBClear()
+}
+
+// do sends a request for url, relative to nwURLBase, through router. The body of a PATCH is a JSON Merge Patch.
+func (suite *apiTestSuite) do(method string, url string, body string) *httptest.ResponseRecorder {
+	req, reqerr := http.NewRequest(method, nwURLBase+url, bytes.NewBufferString(body))
+	suite.NoError(reqerr, "creating http %s request", method)
+	if method == "PATCH" {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	return response
+}
+
 //Handles POST/PUT/DELETE
 
 func doSet(pl testData) error {
@@ -1329,6 +1359,13 @@ func (suite *HardwareTestSuite) SetupSuite() {
 	hwDBClear()
 }
 
+// requiredExtraProperties are the smallest valid ExtraProperties for the types that require some
+var requiredExtraProperties = map[sls_common.HMSStringType]interface{}{
+	sls_common.HSNConnector:        map[string]interface{}{"NodeNics": []string{}},
+	sls_common.MgmtSwitchConnector: map[string]interface{}{"NodeNics": []string{}},
+	sls_common.NodePowerConnector:  map[string]interface{}{"PoweredBy": []string{}},
+}
+
 func (suite *HardwareTestSuite) TestVerifyPOSTAllTypes() {
 	// Verify the hardware search endpoint accepts the following SLS types via the type query param
 	tests := []string{
@@ -1387,11 +1424,12 @@ func (suite *HardwareTestSuite) TestVerifyPOSTAllTypes() {
 		suite.NotEqual("INVALID", slsType)
 
 		h := sls_common.GenericHardware{
-			Parent:     base.GetHMSCompParent(xname),
-			Xname:      xname,
-			Class:      sls_common.ClassRiver,
-			Type:       slsType,
-			TypeString: hmsType,
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Class:              sls_common.ClassRiver,
+			Type:               slsType,
+			TypeString:         hmsType,
+			ExtraPropertiesRaw: requiredExtraProperties[slsType],
 		}
 
 		payload, err := json.Marshal(h)
@@ -1472,11 +1510,12 @@ func (suite *HardwareTestSuite) TestVerifyPUTAllTypes() {
 		suite.NotEqual("INVALID", slsType)
 
 		h := sls_common.GenericHardware{
-			Parent:     base.GetHMSCompParent(xname),
-			Xname:      xname,
-			Class:      sls_common.ClassRiver,
-			Type:       slsType,
-			TypeString: hmsType,
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Class:              sls_common.ClassRiver,
+			Type:               slsType,
+			TypeString:         hmsType,
+			ExtraPropertiesRaw: requiredExtraProperties[slsType],
 		}
 
 		payload, err := json.Marshal(h)
@@ -1496,6 +1535,797 @@ func (suite *HardwareTestSuite) TestVerifyPUTAllTypes() {
 	}
 }
 
+func (suite *HardwareTestSuite) doPatch(xname string, contentType string, body string) *httptest.ResponseRecorder {
+	req, preqerr := http.NewRequest("PATCH", hwURLBase+"/"+xname, bytes.NewBufferString(body))
+	suite.NoError(preqerr, "creating http PATCH request")
+	req.Header.Set("Content-Type", contentType)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	return response
+}
+
+func (suite *HardwareTestSuite) TestPATCHNode() {
+	xname := "x3000c0s19b1n0"
+	h := sls_common.GenericHardware{
+		Parent:             base.GetHMSCompParent(xname),
+		Xname:              xname,
+		Class:              sls_common.ClassRiver,
+		Type:               sls_common.Node,
+		TypeString:         base.Node,
+		ExtraPropertiesRaw: sls_common.ComptypeNode{NID: 1, Role: "Compute", Aliases: []string{"nid000001"}},
+	}
+
+	payload, err := json.Marshal(h)
+	suite.NoError(err)
+
+	req, preqerr := http.NewRequest("PUT", hwURLBase+"/"+xname, bytes.NewBuffer(payload))
+	suite.NoError(preqerr, "creating http PUT request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// Add an alias without knowing about the rest of the object
+	response = suite.doPatch(xname, "application/json-patch+json",
+		`[{"op": "add", "path": "/ExtraProperties/Aliases/-", "value": "cn001"}]`)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// Change the role, NID and aliases must be left alone
+	response = suite.doPatch(xname, "application/merge-patch+json", `{"ExtraProperties": {"Role": "Application"}}`)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	var patched sls_common.GenericHardware
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &patched))
+
+	var node sls_common.ComptypeNode
+	epBytes, err := json.Marshal(patched.ExtraPropertiesRaw)
+	suite.NoError(err)
+	suite.NoError(json.Unmarshal(epBytes, &node))
+	suite.Equal(sls_common.ComptypeNode{NID: 1, Role: "Application", Aliases: []string{"nid000001", "cn001"}}, node)
+
+	// The patched object still has to be valid
+	response = suite.doPatch(xname, "application/merge-patch+json", `{"Type": "comptype_cabinet"}`)
+	suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+
+	// And keep its xname
+	response = suite.doPatch(xname, "application/merge-patch+json", `{"Xname": "x3000c0s19b1n1"}`)
+	suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+	response = suite.doPatch(xname, "application/json-patch+json",
+		`[{"op": "replace", "path": "/Xname", "value": "x3000c0s19b1n1"}]`)
+	suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+	response = suite.doPatch(xname, "application/merge-patch+json", `{"Xname": "X3000C0S19B1N0"}`)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doPatch(xname, "text/plain", `{}`)
+	suite.Equal(http.StatusUnsupportedMediaType, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doPatch("x3000c0s19b1n7", "application/merge-patch+json", `{}`)
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+}
+
+func (suite *HardwareTestSuite) doConditional(method string, xname string, header string, value string,
+	body []byte) *httptest.ResponseRecorder {
+	req, reqerr := http.NewRequest(method, hwURLBase+"/"+xname, bytes.NewBuffer(body))
+	suite.NoError(reqerr, "creating http %s request", method)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	if method == "PATCH" {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	return response
+}
+
+func (suite *HardwareTestSuite) TestETagNode() {
+	xname := "x3000c0s20b1n0"
+	h := sls_common.GenericHardware{
+		Parent:             base.GetHMSCompParent(xname),
+		Xname:              xname,
+		Class:              sls_common.ClassRiver,
+		Type:               sls_common.Node,
+		TypeString:         base.Node,
+		ExtraPropertiesRaw: sls_common.ComptypeNode{NID: 2, Role: "Compute"},
+	}
+
+	payload, err := json.Marshal(h)
+	suite.NoError(err)
+
+	// Creating the node with If-Match "*" has to fail, there is nothing to match yet.
+	response := suite.doConditional("PUT", xname, "If-Match", "*", payload)
+	suite.Equal(http.StatusPreconditionFailed, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("PUT", xname, "", "", payload)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	putETag := response.Header().Get("ETag")
+	suite.NotEmpty(putETag)
+
+	response = suite.doConditional("GET", xname, "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	etag := response.Header().Get("ETag")
+	suite.Equal(putETag, etag)
+
+	// Children aren't covered by the ETag, so the object is always returned
+	response = suite.doConditional("GET", xname, "If-None-Match", etag, nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// Someone else gets a write in first
+	response = suite.doConditional("PATCH", xname, "If-Match", etag, []byte(`{"ExtraProperties": {"Role": "Application"}}`))
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	newETag := response.Header().Get("ETag")
+	suite.NotEqual(etag, newETag)
+
+	// So every write based on the old ETag is turned away
+	response = suite.doConditional("PATCH", xname, "If-Match", etag, []byte(`{"ExtraProperties": {"Role": "Compute"}}`))
+	suite.Equal(http.StatusPreconditionFailed, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("PUT", xname, "If-Match", etag, payload)
+	suite.Equal(http.StatusPreconditionFailed, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("DELETE", xname, "If-Match", etag, nil)
+	suite.Equal(http.StatusPreconditionFailed, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("DELETE", xname, "If-Match", newETag, nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+}
+
+func (suite *HardwareTestSuite) TestBulkPOST() {
+	doBulk := func(mode string, body string) (int, sls_common.BulkHardwareResponse) {
+		req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk?mode="+mode, bytes.NewBufferString(body))
+		suite.NoError(reqerr, "creating http POST request")
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		var bulkResponse sls_common.BulkHardwareResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &bulkResponse)
+		return response.Code, bulkResponse
+	}
+
+	payload := `[
+		{"Parent":"x3000c0s21b0","Xname":"x3000c0s21b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":21,"Role":"Compute"}},
+		{"Parent":"x3000c0s22b0","Xname":"x3000c0s22b0n0","Type":"comptype_cabinet","TypeString":"Node","Class":"River"},
+		{"Parent":"x3000c0s23b0","Xname":"x3000c0s23b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":23,"Role":"Compute"}}
+	]`
+
+	// One bad object stops the whole request
+	code, bulkResponse := doBulk("atomic", payload)
+	suite.Equal(http.StatusBadRequest, code)
+	suite.True(bulkResponse.Atomic)
+	suite.Equal(int64(0), bulkResponse.Version)
+	suite.Len(bulkResponse.Results, 3)
+	suite.Equal(sls_common.BulkHardwareInvalid, bulkResponse.Results[1].Status)
+
+	response := suite.doConditional("GET", "x3000c0s21b0n0", "", "", nil)
+	suite.Equal(http.StatusNotFound, response.Code)
+
+	// Unless it's best effort
+	code, bulkResponse = doBulk("best-effort", payload)
+	suite.Equal(http.StatusOK, code)
+	suite.False(bulkResponse.Atomic)
+	suite.NotEqual(int64(0), bulkResponse.Version)
+	suite.Equal(sls_common.BulkHardwareCreated, bulkResponse.Results[0].Status)
+	suite.Equal(sls_common.BulkHardwareInvalid, bulkResponse.Results[1].Status)
+	suite.Equal(sls_common.BulkHardwareCreated, bulkResponse.Results[2].Status)
+
+	// Both of them were written in the same version
+	response = suite.doConditional("GET", "x3000c0s21b0n0", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code)
+	suite.Equal(versionETag(bulkResponse.Version), response.Header().Get("ETag"))
+	response = suite.doConditional("GET", "x3000c0s23b0n0", "", "", nil)
+	suite.Equal(versionETag(bulkResponse.Version), response.Header().Get("ETag"))
+
+	code, _ = doBulk("sometimes", payload)
+	suite.Equal(http.StatusBadRequest, code)
+
+	code, _ = doBulk("atomic", `[]`)
+	suite.Equal(http.StatusBadRequest, code)
+}
+
+func (suite *HardwareTestSuite) TestTreeGET() {
+	payload := `[
+		{"Parent":"","Xname":"x5000","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"Mountain"},
+		{"Parent":"x5000","Xname":"x5000c0","Type":"comptype_chassis","TypeString":"Chassis","Class":"Mountain"},
+		{"Parent":"x5000c0","Xname":"x5000c0s0","Type":"comptype_compmod","TypeString":"ComputeModule","Class":"Mountain"},
+		{"Parent":"x5000c0s0","Xname":"x5000c0s0b0","Type":"comptype_ncard","TypeString":"NodeBMC","Class":"Mountain"},
+		{"Parent":"x5000c0s0b0","Xname":"x5000c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5000,"Role":"Compute"}},
+		{"Parent":"x5000c0s0b0","Xname":"x5000c0s0b0n1","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5001,"Role":"Compute"}},
+		{"Parent":"x5000c0","Xname":"x5000c0w1","Type":"comptype_mgmt_switch","TypeString":"MgmtSwitch","Class":"Mountain"}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	doTree := func(xname string, query string) *httptest.ResponseRecorder {
+		req, reqerr := http.NewRequest("GET", hwURLBase+"/"+xname+"/tree?"+query, nil)
+		suite.NoError(reqerr, "creating http GET request")
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	getTree := func(query string) sls_common.GenericHardwareTree {
+		response := doTree("x5000", query)
+		suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+		var tree sls_common.GenericHardwareTree
+		suite.NoError(json.Unmarshal(response.Body.Bytes(), &tree))
+		return tree
+	}
+	getFlat := func(query string) []string {
+		response := doTree("x5000", "format=flat&"+query)
+		suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+		var hardware []sls_common.GenericHardware
+		suite.NoError(json.Unmarshal(response.Body.Bytes(), &hardware))
+		xnames := []string{}
+		for _, hw := range hardware {
+			xnames = append(xnames, hw.Xname)
+		}
+		return xnames
+	}
+
+	// The whole tree, children ordered by xname
+	tree := getTree("")
+	suite.Equal("x5000", tree.Xname)
+	suite.Require().Len(tree.ChildHardware, 1)
+	chassis := tree.ChildHardware[0]
+	suite.Equal("x5000c0", chassis.Xname)
+	suite.Require().Len(chassis.ChildHardware, 2)
+	suite.Equal("x5000c0s0", chassis.ChildHardware[0].Xname)
+	suite.Equal("x5000c0w1", chassis.ChildHardware[1].Xname)
+	suite.Require().Len(chassis.ChildHardware[0].ChildHardware, 1)
+	bmc := chassis.ChildHardware[0].ChildHardware[0]
+	suite.Equal("x5000c0s0b0", bmc.Xname)
+	suite.Len(bmc.ChildHardware, 2)
+	suite.Equal(sls_common.Node, bmc.ChildHardware[1].Type)
+
+	// Children is still filled in below the requested depth
+	tree = getTree("depth=1")
+	suite.Require().Len(tree.ChildHardware, 1)
+	suite.Empty(tree.ChildHardware[0].ChildHardware)
+	suite.ElementsMatch([]string{"x5000c0s0", "x5000c0w1"}, tree.ChildHardware[0].Children)
+
+	// Only the path down to matching types is kept
+	tree = getTree("type=comptype_node")
+	suite.Require().Len(tree.ChildHardware, 1)
+	suite.Require().Len(tree.ChildHardware[0].ChildHardware, 1)
+	suite.Require().Len(tree.ChildHardware[0].ChildHardware[0].ChildHardware, 1)
+	suite.Len(tree.ChildHardware[0].ChildHardware[0].ChildHardware[0].ChildHardware, 2)
+
+	suite.Equal([]string{"x5000c0", "x5000c0s0", "x5000c0w1", "x5000c0s0b0", "x5000c0s0b0n0", "x5000c0s0b0n1"},
+		getFlat(""))
+	suite.Equal([]string{"x5000c0", "x5000c0s0", "x5000c0w1"}, getFlat("depth=2"))
+	suite.Equal([]string{"x5000c0s0b0n0", "x5000c0s0b0n1"}, getFlat("type=comptype_node"))
+	suite.Equal([]string{"x5000c0w1", "x5000c0s0b0n0", "x5000c0s0b0n1"},
+		getFlat("type=comptype_node&type=comptype_mgmt_switch"))
+	suite.Equal([]string{}, getFlat("depth=0"))
+
+	suite.Equal(http.StatusBadRequest, doTree("x5000", "depth=-1").Code)
+	suite.Equal(http.StatusBadRequest, doTree("x5000", "depth=x").Code)
+	suite.Equal(http.StatusBadRequest, doTree("x5000", "format=xml").Code)
+	suite.Equal(http.StatusBadRequest, doTree("x5000", "type=comptype_all").Code)
+	suite.Equal(http.StatusBadRequest, doTree("foo", "").Code)
+	suite.Equal(http.StatusNotFound, doTree("x5999", "").Code)
+}
+
+func (suite *HardwareTestSuite) TestDeleteTree() {
+	payload := `[
+		{"Parent":"","Xname":"x5100","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"Mountain"},
+		{"Parent":"x5100","Xname":"x5100c0","Type":"comptype_chassis","TypeString":"Chassis","Class":"Mountain"},
+		{"Parent":"x5100c0","Xname":"x5100c0s0","Type":"comptype_compmod","TypeString":"ComputeModule","Class":"Mountain"},
+		{"Parent":"x5100c0s0","Xname":"x5100c0s0b0","Type":"comptype_ncard","TypeString":"NodeBMC","Class":"Mountain"},
+		{"Parent":"x5100c0s0b0","Xname":"x5100c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5100,"Role":"Compute"}},
+		{"Parent":"x5100","Xname":"x5100m0","Type":"comptype_cab_pdu_controller","TypeString":"CabinetPDUController","Class":"Mountain"},
+		{"Parent":"x5101c0w1","Xname":"x5101c0w1j1","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"NodeNics":["x5100c0s0b0"],"VendorName":"1/1/1"}},
+		{"Parent":"x5101c0s0","Xname":"x5101c0s0v1","Type":"comptype_compmod_power_connector","TypeString":"NodePowerConnector","Class":"River","ExtraProperties":{"PoweredBy":["x5100m0"]}}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	subtree := []string{"x5100c0s0b0n0", "x5100c0s0b0", "x5100c0s0", "x5100c0", "x5100m0", "x5100"}
+
+	// A dry run shows what would go, and what points at it
+	response = suite.doConditional("DELETE", "x5100?dryRun=true", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var result sls_common.HardwareDeleteResult
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &result))
+	suite.True(result.DryRun)
+	suite.Equal(subtree, result.Xnames)
+	suite.Equal([]sls_common.HardwareReference{
+		{Xname: "x5101c0s0v1", Property: "PoweredBy", Target: "x5100m0"},
+		{Xname: "x5101c0w1j1", Property: "NodeNics", Target: "x5100c0s0b0"},
+	}, result.References)
+
+	for _, xname := range subtree {
+		response = suite.doConditional("GET", xname, "", "", nil)
+		suite.Equal(http.StatusOK, response.Code, "%s was deleted by a dry run", xname)
+	}
+
+	response = suite.doConditional("DELETE", "x5100?dryRun=maybe", "", "", nil)
+	suite.Equal(http.StatusBadRequest, response.Code)
+	response = suite.doConditional("DELETE", "x5100?dryRun=true", "If-Match", `"0"`, nil)
+	suite.Equal(http.StatusPreconditionFailed, response.Code)
+
+	// The real thing removes the whole subtree in one version, along with the references to it
+	response = suite.doConditional("DELETE", "x5100", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	for _, xname := range subtree {
+		response = suite.doConditional("GET", xname, "", "", nil)
+		suite.Equal(http.StatusNotFound, response.Code, "%s was not deleted", xname)
+	}
+
+	for xname, property := range map[string]string{"x5101c0w1j1": "NodeNics", "x5101c0s0v1": "PoweredBy"} {
+		response = suite.doConditional("GET", xname, "", "", nil)
+		suite.Equal(http.StatusOK, response.Code)
+		var hardware sls_common.GenericHardware
+		suite.NoError(json.Unmarshal(response.Body.Bytes(), &hardware))
+		suite.Equal([]interface{}{}, hardware.ExtraPropertiesRaw.(map[string]interface{})[property], xname)
+	}
+
+	response = suite.doConditional("DELETE", "x5100?dryRun=true", "", "", nil)
+	suite.Equal(http.StatusNotFound, response.Code)
+}
+
+func (suite *HardwareTestSuite) getLinks(xname string, property string) []interface{} {
+	response := suite.doConditional("GET", xname, "", "", nil)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	var hardware sls_common.GenericHardware
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &hardware))
+	links, _ := hardware.ExtraPropertiesRaw.(map[string]interface{})[property].([]interface{})
+	return links
+}
+
+func (suite *HardwareTestSuite) TestLinks() {
+	nic := func(xname string, peers string) json.RawMessage {
+		return json.RawMessage(`{"Parent":"` + base.GetHMSCompParent(xname) + `","Xname":"` + xname + `","Type":"comptype_bmc_nic","TypeString":"NodeBMCNic","Class":"River","ExtraProperties":{"Networks":[],"Peers":[` + peers + `]}}`)
+	}
+	connector := func(xname string, nics string) json.RawMessage {
+		return json.RawMessage(`{"Parent":"` + base.GetHMSCompParent(xname) + `","Xname":"` + xname + `","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"NodeNics":[` + nics + `],"VendorName":"1/1/1"}}`)
+	}
+	put := func(payload json.RawMessage) int {
+		var h sls_common.GenericHardware
+		suite.Require().NoError(json.Unmarshal(payload, &h))
+		response := suite.doConditional("PUT", h.Xname, "", "", payload)
+		return response.Code
+	}
+
+	suite.Equal(http.StatusOK, put(nic("x5600c0s0b0i0", "")))
+	suite.Equal(http.StatusOK, put(nic("x5600c0s1b0i0", "")))
+
+	// Plugging a NIC into a connector adds the connector to its peers
+	suite.Equal(http.StatusOK, put(connector("x5600c0w1j1", `"x5600c0s0b0i0"`)))
+	suite.Equal([]interface{}{"x5600c0w1j1"}, suite.getLinks("x5600c0s0b0i0", "Peers"))
+
+	// Moving it to another NIC updates both the old and the new one
+	suite.Equal(http.StatusOK, put(connector("x5600c0w1j1", `"x5600c0s1b0i0"`)))
+	suite.Equal([]interface{}{}, suite.getLinks("x5600c0s0b0i0", "Peers"))
+	suite.Equal([]interface{}{"x5600c0w1j1"}, suite.getLinks("x5600c0s1b0i0", "Peers"))
+
+	// It works the other way around too
+	suite.Equal(http.StatusOK, put(nic("x5600c0s0b0i0", `"x5600c0w1j1"`)))
+	suite.Equal([]interface{}{"x5600c0s1b0i0", "x5600c0s0b0i0"}, suite.getLinks("x5600c0w1j1", "NodeNics"))
+	suite.Equal(http.StatusOK, put(nic("x5600c0s1b0i0", "")))
+	suite.Equal([]interface{}{"x5600c0s0b0i0"}, suite.getLinks("x5600c0w1j1", "NodeNics"))
+
+	// A NIC created after the connector that lists it picks up the link
+	suite.Equal(http.StatusOK, put(connector("x5600c0w1j2", `"x5600c0s2b0i0"`)))
+	suite.Equal(http.StatusOK, put(nic("x5600c0s2b0i0", "")))
+	suite.Equal([]interface{}{"x5600c0w1j2"}, suite.getLinks("x5600c0s2b0i0", "Peers"))
+
+	// Deleting one side removes it from the other
+	response := suite.doConditional("DELETE", "x5600c0s0b0i0", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Equal([]interface{}{}, suite.getLinks("x5600c0w1j1", "NodeNics"))
+
+	// Links to hardware that doesn't exist can be rejected
+	suite.Require().NoError(datastore.SetReferenceMode(datastore.ReferencesReject))
+	defer func() {
+		suite.NoError(datastore.SetReferenceMode(datastore.ReferencesIgnore))
+	}()
+
+	suite.Equal(http.StatusBadRequest, put(connector("x5600c0w1j1", `"x5600c0s9b0i0"`)))
+	suite.Equal(http.StatusBadRequest, put(nic("x5600c0s1b0i0", `"x5600c0w1j9"`)))
+	suite.Equal(http.StatusOK, put(connector("x5600c0w1j1", `"x5600c0s1b0i0"`)))
+
+	// Unless they are created along with it
+	payload, _ := json.Marshal([]json.RawMessage{connector("x5600c0w1j3", `"x5600c0s3b0i0"`), nic("x5600c0s3b0i0", "")})
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBuffer(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Equal([]interface{}{"x5600c0w1j3"}, suite.getLinks("x5600c0s3b0i0", "Peers"))
+}
+
+func (suite *HardwareTestSuite) TestConnections() {
+	payload := `[
+		{"Parent":"x5700c0","Xname":"x5700c0w1","Type":"comptype_mgmt_switch","TypeString":"MgmtSwitch","Class":"River"},
+		{"Parent":"x5700c0w1","Xname":"x5700c0w1j1","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"NodeNics":["x5700c0s1b0"],"VendorName":"1/1/1"}},
+		{"Parent":"x5700c0w1","Xname":"x5700c0w1j2","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"NodeNics":[],"VendorName":"1/1/2"}},
+		{"Parent":"x5700c0s1","Xname":"x5700c0s1b0","Type":"comptype_ncard","TypeString":"NodeBMC","Class":"River"},
+		{"Parent":"x5700c0s1b0","Xname":"x5700c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5700,"Role":"Compute"}}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	doConnections := func(xname string) (int, sls_common.HardwareConnections) {
+		req, reqerr := http.NewRequest("GET", hwURLBase+"/"+xname+"/connections", nil)
+		suite.NoError(reqerr, "creating http GET request")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		var connections sls_common.HardwareConnections
+		if response.Code == http.StatusOK {
+			suite.NoError(json.Unmarshal(response.Body.Bytes(), &connections))
+		}
+		return response.Code, connections
+	}
+
+	port := sls_common.HardwareConnection{Switch: "x5700c0w1", Port: "x5700c0w1j1",
+		Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/1", Devices: []string{"x5700c0s1b0"}}
+
+	code, connections := doConnections("x5700c0w1")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("x5700c0w1", connections.Xname)
+	suite.Len(connections.Connections, 2)
+	suite.Equal(port, connections.Connections[0])
+	suite.Equal("x5700c0w1j2", connections.Connections[1].Port)
+	suite.Empty(connections.Connections[1].Devices)
+
+	for _, xname := range []string{"x5700c0s1b0", "x5700c0s1b0n0"} {
+		code, connections = doConnections(xname)
+		suite.Equal(http.StatusOK, code)
+		suite.Equal([]sls_common.HardwareConnection{port}, connections.Connections, xname)
+	}
+
+	code, _ = doConnections("foo")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doConnections("x5799c0w1")
+	suite.Equal(http.StatusNotFound, code)
+}
+
+func (suite *HardwareTestSuite) TestHardwareNetworks() {
+	payload := `[
+		{"Parent":"","Xname":"x5900","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"River","ExtraProperties":{"Networks":{
+			"cn":{"HMN":{"CIDR":"10.159.0.0/22","Gateway":"10.159.0.1","VLan":1590},"NMN":{"CIDR":"10.159.4.0/22","Gateway":"10.159.4.1","VLan":1591}},
+			"ncn":{"HMN":{"CIDR":"10.159.8.0/22","MACPrefix":"02"}}}}},
+		{"Parent":"x5900c0s0b0","Xname":"x5900c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5900,"Role":"Management"}},
+		{"Parent":"x5900c0s1b0","Xname":"x5900c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5901,"Role":"Compute"}}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	network := `{"Name":"HMN_T5900","FullName":"Cabinet network test network","Type":"ethernet","IPRanges":["10.159.8.0/22"],
+		"ExtraProperties":{"CIDR":"10.159.8.0/22","VlanRange":[1592],"Subnets":[{"Name":"cabinet_5900","FullName":"","CIDR":"10.159.8.0/22","VlanID":1592,"Gateway":"10.159.8.1"}]}}`
+	req, reqerr = http.NewRequest("POST", nwURLBase+"/networks", bytes.NewBufferString(network))
+	suite.NoError(reqerr, "creating http POST request")
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	defer func() {
+		req, _ := http.NewRequest("DELETE", nwURLBase+"/networks/HMN_T5900", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	doNetworks := func(url string) (int, sls_common.HardwareNetworks) {
+		req, reqerr := http.NewRequest("GET", hwURLBase+"/"+url, nil)
+		suite.NoError(reqerr, "creating http GET request")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		var networks sls_common.HardwareNetworks
+		if response.Code == http.StatusOK {
+			suite.NoError(json.Unmarshal(response.Body.Bytes(), &networks))
+		}
+		return response.Code, networks
+	}
+
+	// A management node gets the ncn networks, with the gateway and VLAN of the matching subnet
+	code, networks := doNetworks("x5900c0s0b0n0/networks")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("x5900c0s0b0n0", networks.Xname)
+	suite.Equal("x5900", networks.Cabinet)
+	suite.Equal("ncn", networks.Profile)
+	suite.Require().Len(networks.Networks, 1)
+	suite.Equal("HMN", networks.Networks[0].Name)
+	suite.Equal("10.159.8.0/22", networks.Networks[0].CIDR)
+	suite.Equal("10.159.8.1", networks.Networks[0].Gateway)
+	suite.Equal(1592, networks.Networks[0].VLan)
+	suite.Equal("02", networks.Networks[0].MACPrefix)
+	suite.Equal("HMN_T5900", networks.Networks[0].Network)
+	suite.Require().NotNil(networks.Networks[0].Subnet)
+	suite.Equal("cabinet_5900", networks.Networks[0].Subnet.Name)
+
+	// A compute node gets the cn networks
+	code, networks = doNetworks("x5900c0s1b0n0/networks")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("cn", networks.Profile)
+	suite.Require().Len(networks.Networks, 2)
+	suite.Equal("HMN", networks.Networks[0].Name)
+	suite.Equal("10.159.0.1", networks.Networks[0].Gateway)
+	suite.Equal(1590, networks.Networks[0].VLan)
+	suite.Nil(networks.Networks[0].Subnet)
+	suite.Equal("NMN", networks.Networks[1].Name)
+
+	code, networks = doNetworks("x5900c0s0b0n0/networks?profile=cn")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("cn", networks.Profile)
+	suite.Len(networks.Networks, 2)
+
+	code, _ = doNetworks("x5900c0s0b0n0/networks?profile=bogus")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doNetworks("foo/networks")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doNetworks("x5999c0s0b0n0/networks")
+	suite.Equal(http.StatusNotFound, code)
+}
+
+func (suite *HardwareTestSuite) TestHistory() {
+	payload := `[
+		{"Parent":"x6000c0w1","Xname":"x6000c0w1j1","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"NodeNics":["x6000c0s0b0"],"VendorName":"1/1/1"}},
+		{"Parent":"x6000c0s0","Xname":"x6000c0s0b0","Type":"comptype_ncard","TypeString":"NodeBMC","Class":"River"},
+		{"Parent":"x6000c0s0b0","Xname":"x6000c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6000,"Role":"Compute"}}
+	]`
+
+	doHistory := func(url string) (int, sls_common.HardwareHistory) {
+		req, reqerr := http.NewRequest("GET", hwURLBase+"/"+url, nil)
+		suite.NoError(reqerr, "creating http GET request")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		var history sls_common.HardwareHistory
+		if response.Code == http.StatusOK {
+			suite.NoError(json.Unmarshal(response.Body.Bytes(), &history))
+		}
+		return response.Code, history
+	}
+
+	// Earlier runs against the same DB leave history behind.
+	totals := map[string]int64{}
+	for _, xname := range []string{"x6000c0w1j1", "x6000c0s0b0", "x6000c0s0b0n0"} {
+		if code, history := doHistory(xname + "/history"); code == http.StatusOK {
+			totals[xname] = history.Total
+		}
+	}
+
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("PATCH", "x6000c0s0b0n0", "", "", []byte(`{"ExtraProperties":{"NID":6001}}`))
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	code, history := doHistory("x6000c0s0b0n0/history")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("x6000c0s0b0n0", history.Xname)
+	suite.Equal(totals["x6000c0s0b0n0"]+2, history.Total)
+	suite.Require().GreaterOrEqual(len(history.Revisions), 2)
+
+	// Newest first, the NID change along with what it was before
+	updated, inserted := history.Revisions[0], history.Revisions[1]
+	suite.Equal("update", updated.Operation)
+	suite.Equal("x6000c0s0b0n0", updated.UpdatedEntity)
+	suite.Require().NotNil(updated.Before)
+	suite.Require().NotNil(updated.After)
+	suite.EqualValues(6000, updated.Before.ExtraPropertiesRaw.(map[string]interface{})["NID"])
+	suite.EqualValues(6001, updated.After.ExtraPropertiesRaw.(map[string]interface{})["NID"])
+	suite.Equal(base.Node, updated.After.TypeString)
+	suite.Greater(updated.Version, inserted.Version)
+	suite.NotZero(updated.Timestamp)
+	suite.NotEmpty(updated.TimestampTime)
+
+	suite.Equal("insert", inserted.Operation)
+	suite.Equal("bulk:hardware", inserted.UpdatedEntity)
+	suite.Nil(inserted.Before)
+	suite.Require().NotNil(inserted.After)
+	suite.Equal("x6000c0s0b0", inserted.After.Parent)
+
+	code, history = doHistory("x6000c0s0b0n0/history?limit=1&offset=1")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(totals["x6000c0s0b0n0"]+2, history.Total)
+	suite.Require().Len(history.Revisions, 1)
+	suite.Equal(inserted.Revision, history.Revisions[0].Revision)
+
+	// Deleting the BMC takes it out of the NodeNics of the switch port, which is recorded as a change to the port
+	response = suite.doConditional("DELETE", "x6000c0s0b0", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	code, history = doHistory("x6000c0s0b0/history")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(totals["x6000c0s0b0"]+2, history.Total)
+	suite.Require().NotEmpty(history.Revisions)
+	suite.Equal("delete", history.Revisions[0].Operation)
+	suite.NotNil(history.Revisions[0].Before)
+	suite.Nil(history.Revisions[0].After)
+
+	code, history = doHistory("x6000c0w1j1/history")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(totals["x6000c0w1j1"]+2, history.Total)
+	suite.Require().NotEmpty(history.Revisions)
+	suite.Equal("update", history.Revisions[0].Operation)
+	suite.Equal("x6000c0s0b0", history.Revisions[0].UpdatedEntity)
+	suite.Equal([]interface{}{}, history.Revisions[0].After.ExtraPropertiesRaw.(map[string]interface{})["NodeNics"])
+
+	code, _ = doHistory("x6000c0s0b0n0/history?limit=abc")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doHistory("x6000c0s0b0n0/history?offset=-1")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doHistory("foo/history")
+	suite.Equal(http.StatusBadRequest, code)
+	code, _ = doHistory("x6099c0s0b0n0/history")
+	suite.Equal(http.StatusNotFound, code)
+}
+
+func (suite *HardwareTestSuite) TestExtraPropertiesValidation() {
+	invalid := []json.RawMessage{
+		// Wrong type
+		json.RawMessage(`{"Parent":"x5200c0s0b0","Xname":"x5200c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":"abc","Role":"Compute"}}`),
+		// Unknown property
+		json.RawMessage(`{"Parent":"x5200c0s0b0","Xname":"x5200c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5200,"Rolle":"Compute"}}`),
+		// Unknown property below the top level
+		json.RawMessage(`{"Parent":"","Xname":"x5200","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"Mountain","ExtraProperties":{"Networks":{"cn":{"HMN":{"CIDR":"10.254.0.0/22","Bogus":1}}}}}`),
+		// Missing required property
+		json.RawMessage(`{"Parent":"x5200c0w1","Xname":"x5200c0w1j1","Type":"comptype_mgmt_switch_connector","TypeString":"MgmtSwitchConnector","Class":"River","ExtraProperties":{"VendorName":"1/1/1"}}`),
+		// No ExtraProperties for this type
+		json.RawMessage(`{"Parent":"x5200","Xname":"x5200c0","Type":"comptype_chassis","TypeString":"Chassis","Class":"Mountain","ExtraProperties":{"NID":5200}}`),
+	}
+
+	suite.Require().NoError(datastore.SetValidationMode(datastore.ValidationStrict))
+	defer func() {
+		suite.NoError(datastore.SetValidationMode(datastore.ValidationLenient))
+	}()
+
+	for _, payload := range invalid {
+		var h sls_common.GenericHardware
+		suite.NoError(json.Unmarshal(payload, &h))
+
+		req, reqerr := http.NewRequest("POST", hwURLBase, bytes.NewBuffer(payload))
+		suite.NoError(reqerr, "creating http POST request")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+
+		response = suite.doConditional("PUT", h.Xname, "", "", payload)
+		suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+
+		response = suite.doConditional("GET", h.Xname, "", "", nil)
+		suite.Equal(http.StatusNotFound, response.Code, "%s was stored", h.Xname)
+	}
+
+	// Legacy data can still be stored in lenient mode
+	suite.Require().NoError(datastore.SetValidationMode(datastore.ValidationLenient))
+
+	response := suite.doConditional("PUT", "x5200c0s0b0n0", "", "", invalid[0])
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("DELETE", "x5200c0s0b0n0", "", "", nil)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+}
+
+func (suite *HardwareTestSuite) TestAliases() {
+	payload := `[
+		{"Parent":"","Xname":"x5300","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"River"},
+		{"Parent":"x5300c0s0b0","Xname":"x5300c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5300,"Role":"Management","Aliases":["ncn-t001"]}},
+		{"Parent":"x5300c0s1b0","Xname":"x5300c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5301,"Role":"Management","Aliases":["ncn-t002","ncn-t002-alt"]}}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	network := `{"Name":"T5300","FullName":"Alias test network","Type":"ethernet","IPRanges":["10.253.0.0/24"],
+		"ExtraProperties":{"CIDR":"10.253.0.0/24","VlanRange":[5],"Subnets":[{"Name":"bootstrap","FullName":"","CIDR":"10.253.0.0/24","VlanID":5,"Gateway":"10.253.0.1",
+		"IPReservations":[{"Name":"ncn-t001","IPAddress":"10.253.0.5","Comment":"x5300c0s0b0n0"},{"Name":"ncn-t002-alt","IPAddress":"10.253.0.6"}]}]}}`
+	req, reqerr = http.NewRequest("POST", nwURLBase+"/networks", bytes.NewBufferString(network))
+	suite.NoError(reqerr, "creating http POST request")
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	defer func() {
+		req, _ := http.NewRequest("DELETE", nwURLBase+"/networks/T5300", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	doGet := func(url string) *httptest.ResponseRecorder {
+		req, reqerr := http.NewRequest("GET", url, nil)
+		suite.NoError(reqerr, "creating http GET request")
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// Aliases resolve to their hardware, ignoring case
+	response = doGet(nwURLBase + "/resolve/NCN-T002-alt")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var hw sls_common.GenericHardware
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &hw))
+	suite.Equal("x5300c0s1b0n0", hw.Xname)
+
+	response = doGet(nwURLBase + "/resolve/ncn-t999")
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+
+	// Names include the reservations made for the hardware in any network
+	response = doGet(hwURLBase + "/x5300c0s1b0n0/names")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var names sls_common.HardwareNames
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &names))
+	suite.Equal([]string{"ncn-t002", "ncn-t002-alt"}, names.Aliases)
+	suite.Require().Len(names.IPReservations, 1)
+	suite.Equal("T5300", names.IPReservations[0].Network)
+	suite.Equal("bootstrap", names.IPReservations[0].Subnet)
+	suite.Equal("ncn-t002-alt", names.IPReservations[0].Name)
+
+	response = doGet(hwURLBase + "/x5300/names")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.JSONEq(`{"Xname":"x5300","Aliases":[],"IPReservations":[]}`, response.Body.String())
+
+	response = doGet(hwURLBase + "/x5399/names")
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+
+	// An alias can't be taken by other hardware, however it is written
+	taken := []byte(`{"Parent":"x5300c0s2b0","Xname":"x5300c0s2b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5302,"Role":"Management","Aliases":["NCN-T001"]}}`)
+
+	req, reqerr = http.NewRequest("POST", hwURLBase, bytes.NewBuffer(taken))
+	suite.NoError(reqerr, "creating http POST request")
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("PUT", "x5300c0s2b0n0", "", "", taken)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	req, reqerr = http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString("["+string(taken)+"]"))
+	suite.NoError(reqerr, "creating http POST request")
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doPatch("x5300c0s1b0n0", "application/merge-patch+json", `{"ExtraProperties":{"Aliases":["ncn-t001"]}}`)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	// Once the owner lets go of it the alias is free again
+	response = suite.doPatch("x5300c0s0b0n0", "application/merge-patch+json", `{"ExtraProperties":{"Aliases":["ncn-t000"]}}`)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.doConditional("PUT", "x5300c0s2b0n0", "", "", taken)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = doGet(nwURLBase + "/resolve/ncn-t001")
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &hw))
+	suite.Equal("x5300c0s2b0n0", hw.Xname)
+
+	// Deleted hardware gives up its aliases
+	for _, xname := range []string{"x5300", "x5300c0s0b0n0", "x5300c0s1b0n0", "x5300c0s2b0n0"} {
+		response = suite.doConditional("DELETE", xname, "", "", nil)
+		suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	}
+
+	response = doGet(nwURLBase + "/resolve/ncn-t001")
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+}
+
 func TestHardwareTestSuite(t *testing.T) {
 	suite.Run(t, new(HardwareTestSuite))
 }
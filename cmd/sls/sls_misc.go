@@ -31,12 +31,15 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"log"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
 	"github.com/Cray-HPE/hms-sls/pkg/sls-common"
 
 	compcredentials "github.com/Cray-HPE/hms-compcredentials"
@@ -44,6 +47,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/Cray-HPE/hms-sls/internal/patch"
 )
 
 // Used for response functions
@@ -274,7 +278,37 @@ func doDumpState(w http.ResponseWriter, r *http.Request) {
 	var shaHash hash.Hash
 	var publicKey *rsa.PublicKey
 
-	allHardware, err := datastore.GetAllHardware()
+	// Only a plain GET can be answered from the client's cache, a POST
+	// carries Vault data that isn't covered by the version.  Vault only
+	// has the current credentials, even for an older version.
+	version, asOf, status, err := getReadVersion(r)
+	if status == http.StatusInternalServerError {
+		log.Println("ERROR: unable to get current version: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Failed to get version info from DB",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: invalid asOfVersion or asOf: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			http.StatusText(status),
+			err.Error(),
+			r.URL.Path, status)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if r.Method == "GET" && checkNotModified(w, r, version) {
+		return
+	}
+
+	var allHardware []sls_common.GenericHardware
+	if asOf {
+		allHardware, err = datastore.GetAllHardwareAsOf(version)
+	} else {
+		allHardware, err = datastore.GetAllHardware()
+	}
 	if err != nil {
 		log.Println("ERROR: unable to get hardware: ", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -394,7 +428,12 @@ func doDumpState(w http.ResponseWriter, r *http.Request) {
 		ret.Hardware[hardware.Xname] = hardware
 	}
 
-	allNetworks, err := datastore.GetAllNetworks()
+	var allNetworks []sls_common.Network
+	if asOf {
+		allNetworks, err = datastore.GetAllNetworksAsOf(version)
+	} else {
+		allNetworks, err = datastore.GetAllNetworks()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
@@ -413,6 +452,9 @@ func doDumpState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.Method == "GET" {
+		w.Header().Set("ETag", versionETag(version))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
@@ -426,6 +468,32 @@ func doLoadState(w http.ResponseWriter, r *http.Request) {
 	var privateKey *rsa.PrivateKey
 	var buf bytes.Buffer
 
+	mode, modeErr := datastore.ParseLoadStateMode(r.FormValue("mode"))
+	if modeErr != nil {
+		log.Println("ERROR: invalid loadstate mode:", modeErr)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			modeErr.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	dryRun := false
+	if dryRunStr := r.FormValue("dryRun"); dryRunStr != "" {
+		var dryRunErr error
+		dryRun, dryRunErr = strconv.ParseBool(dryRunStr)
+		if dryRunErr != nil {
+			log.Printf("ERROR: invalid dryRun in request URL: '%s'\n", dryRunStr)
+			pdet := base.NewProblemDetails("about: blank",
+				"Bad Request",
+				"dryRun must be true or false",
+				r.URL.Path, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+	}
+
 	// Check to see if we've been given a key to encrypt with.
 	privateKeyFile, _, privateKeyErr := r.FormFile("private_key")
 	if privateKeyErr == http.ErrMissingFile {
@@ -512,10 +580,10 @@ func doLoadState(w http.ResponseWriter, r *http.Request) {
 
 	// Finally we are ready to put the info back into the database.
 	shaHash = sha256.New()
-	var hardware []sls_common.GenericHardware
-	var networks []sls_common.Network
 
-	// Loop through all of the provided hardware looking for those with Vault details that need to go back.
+	// Loop through all of the provided hardware looking for those with Vault details that need to go back.  They are
+	// only stored once everything else has been, so they are all decrypted first.
+	var credentialsToStore []compcredentials.CompCredentials
 	for _, obj := range inputData.Hardware {
 		if vaultEnabled && obj.VaultData != nil && privateKey != nil {
 			// Decode the base64 encoded string.
@@ -555,49 +623,126 @@ func doLoadState(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			// Now finally we can put the credentials back into Vault.
-			compCredErr := compCredStore.StoreCompCred(credentials)
-			if compCredErr != nil {
-				log.Println("ERROR: unable to store credentials:", compCredErr)
-				pdet := base.NewProblemDetails("about: blank",
-					"Internal Server Error",
-					"Failed to store credentials",
-					r.URL.Path, http.StatusInternalServerError)
-				base.SendProblemDetails(w, pdet, 0)
-				return
-			}
+			credentialsToStore = append(credentialsToStore, credentials)
 		}
+	}
 
-		hardware = append(hardware, obj)
+	// Now finally we can put the credentials back into Vault, once the database has everything else.  If that fails
+	// the load is undone.
+	var storeCredentials func() error
+	if len(credentialsToStore) > 0 && !dryRun {
+		storeCredentials = func() error {
+			return storeCompCreds(credentialsToStore)
+		}
 	}
 
-	hardwareErr := datastore.ReplaceGenericHardware(hardware)
-	if hardwareErr != nil {
-		log.Println("ERROR: unable to replace hardware:", hardwareErr)
+	result, loadErr := datastore.LoadState(inputData, mode, dryRun, storeCredentials)
+	var invalidState *datastore.InvalidState
+	if errors.As(loadErr, &invalidState) {
+		log.Println("ERROR: invalid state:", loadErr)
+		sendValidationProblem(w, r, invalidState)
+		return
+	}
+	if errors.Is(loadErr, datastore.InvalidHardware) || errors.Is(loadErr, datastore.InvalidLoadState) {
+		log.Println("ERROR: invalid state:", loadErr)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			loadErr.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if errors.Is(loadErr, database.AliasInUse) || errors.Is(loadErr, database.NIDInUse) {
+		log.Println("ERROR: hardware shares an alias or NID:", loadErr)
+		pdet := base.NewProblemDetails("about: blank",
+			"Conflict",
+			loadErr.Error(),
+			r.URL.Path, http.StatusConflict)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if errors.Is(loadErr, database.PreconditionFailed) {
+		log.Println("ERROR: state changed while loading:", loadErr)
+		pdet := base.NewProblemDetails("about: blank",
+			"Conflict",
+			"Hardware or networks changed while the state was being loaded, nothing was loaded",
+			r.URL.Path, http.StatusConflict)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if errors.Is(loadErr, datastore.LoadStateUndone) {
+		log.Println("ERROR: unable to store credentials:", loadErr)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Failed to store credentials, the state was not loaded",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if loadErr != nil {
+		log.Println("ERROR: unable to load state:", loadErr)
 		pdet := base.NewProblemDetails("about: blank",
 			"Internal Server Error",
-			"Failed to replace hardware",
+			"Failed to load state",
 			r.URL.Path, http.StatusInternalServerError)
 		base.SendProblemDetails(w, pdet, 0)
 		return
 	}
 
-	for _, obj := range inputData.Networks {
-		networks = append(networks, obj)
+	if !dryRun {
+		log.Printf("INFO: Loaded state in %s mode", mode)
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
 
-	networksErr := datastore.ReplaceAllNetworks(networks)
-	if networksErr != nil {
-		log.Println("ERROR: unable to replace networks:", networksErr)
+	ba, err := json.Marshal(result)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of loadstate preview failed:", err)
 		pdet := base.NewProblemDetails("about: blank",
 			"Internal Server Error",
-			"Failed to replace networks",
+			"JSON marshal error",
 			r.URL.Path, http.StatusInternalServerError)
 		base.SendProblemDetails(w, pdet, 0)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+// Send the RFC7807 problem listing everything wrong with a loaded state.
+// It is a conflict if all that is wrong is aliases or NIDs used more than
+// once.
+
+func sendValidationProblem(w http.ResponseWriter, r *http.Request, invalid *datastore.InvalidState) {
+	status := http.StatusBadRequest
+	if invalid.Conflict {
+		status = http.StatusConflict
+	}
+
+	problem := sls_common.ValidationProblem{
+		ProblemDetails: *base.NewProblemDetails("about: blank",
+			http.StatusText(status),
+			fmt.Sprintf("Found %d problem(s) with the state, see errors", len(invalid.Errors)),
+			r.URL.Path, status),
+		Errors: invalid.Errors,
+	}
+
+	ba, err := json.Marshal(problem)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of validation problem failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", base.ProblemDetailContentType)
+	w.WriteHeader(status)
+	w.Write(ba)
 }
 
 // Send a JSON response.  If the ecode indicates an error, send
@@ -623,6 +768,173 @@ func sendJsonRsp(w http.ResponseWriter, ecode int, message string) {
 	}
 }
 
+// Send the RFC7807 problem matching an error returned while applying a
+// PATCH request.
+
+func sendPatchErrorRsp(w http.ResponseWriter, r *http.Request, err error) {
+	var title string
+	var status int
+
+	switch {
+	case errors.Is(err, database.NoSuch):
+		title, status = "Not Found", http.StatusNotFound
+	case errors.Is(err, database.PreconditionFailed):
+		title, status = "Precondition Failed", http.StatusPreconditionFailed
+	case errors.Is(err, patch.UnsupportedContentType):
+		title, status = "Unsupported Media Type", http.StatusUnsupportedMediaType
+	case errors.Is(err, patch.TestFailed), errors.Is(err, patch.PathNotFound),
+		errors.Is(err, database.AliasInUse), errors.Is(err, database.NIDInUse):
+		title, status = "Conflict", http.StatusConflict
+	case errors.Is(err, patch.InvalidPatch),
+		errors.Is(err, datastore.InvalidHardware),
+		errors.Is(err, datastore.InvalidNetworkType),
+		errors.Is(err, datastore.InvalidNetworkName):
+		title, status = "Bad Request", http.StatusBadRequest
+	default:
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Failed to apply patch",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	pdet := base.NewProblemDetails("about: blank", title, err.Error(), r.URL.Path, status)
+	base.SendProblemDetails(w, pdet, 0)
+}
+
+// Objects are tagged with the version they were last updated in, collections
+// with the current version of the whole datastore.  The tag of hardware
+// doesn't cover its Children, which are added and removed without updating
+// it, so only the collections and networks honour If-None-Match with it.
+
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Split a list of entity tags from an If-Match or If-None-Match header.
+// Weak tags have their W/ prefix removed, the caller decides whether that
+// is acceptable.
+
+func parseETags(r *http.Request, header string) (tags []string, weak []bool) {
+	for _, value := range r.Header.Values(header) {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+
+			isWeak := strings.HasPrefix(tag, "W/")
+			tags = append(tags, strings.TrimPrefix(tag, "W/"))
+			weak = append(weak, isWeak)
+		}
+	}
+
+	return
+}
+
+// Turn the If-Match header of a request into a precondition for the
+// datastore.  If-Match always uses the strong comparison, so weak tags and
+// tags that aren't ours can never match.
+
+func getIfMatch(r *http.Request) database.Precondition {
+	var precondition database.Precondition
+
+	tags, weak := parseETags(r, "If-Match")
+	for i, tag := range tags {
+		precondition.IfMatch = true
+		if tag == "*" {
+			precondition.Any = true
+			continue
+		}
+		if weak[i] {
+			continue
+		}
+
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			continue
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err != nil {
+			continue
+		}
+		precondition.Versions = append(precondition.Versions, version)
+	}
+
+	return precondition
+}
+
+// Check If-None-Match against the version of what is about to be returned.
+// If the client already has it, send 304 with the ETag and return true.
+
+func checkNotModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	etag := versionETag(version)
+
+	tags, _ := parseETags(r, "If-None-Match")
+	for _, tag := range tags {
+		if tag == "*" || tag == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read the limit and offset query parameters of a paged GET.  Leaving out
+// limit returns everything, leaving out offset starts at the beginning.
+
+func getPage(r *http.Request) (limit int, offset int, err error) {
+	if value := r.FormValue("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	}
+	if value := r.FormValue("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+	}
+
+	return
+}
+
+// Look up the version of the whole datastore for tagging collection GETs.
+
+func getCollectionVersion() (int64, error) {
+	version, err := database.GetCurrentVersion()
+	return int64(version), err
+}
+
+// Work out the version a collection GET is for, the one asked for with
+// asOfVersion or asOf or else the current one.  asOf is true if the
+// response has to come from the revisions.  On error, status is the HTTP
+// status to answer with.
+
+func getReadVersion(r *http.Request) (version int64, asOf bool, status int, err error) {
+	version, asOf, err = datastore.GetAsOfVersion(r.FormValue("asOfVersion"), r.FormValue("asOf"))
+	if errors.Is(err, datastore.InvalidAsOf) {
+		status = http.StatusBadRequest
+		return
+	} else if errors.Is(err, datastore.VersionUnavailable) {
+		status = http.StatusNotFound
+		return
+	} else if err != nil {
+		status = http.StatusInternalServerError
+		return
+	}
+	if asOf {
+		return
+	}
+
+	version, err = getCollectionVersion()
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	return
+}
+
 // Send a simple message for cases where need a non-error response.  If
 // a more feature filled message needs to be returned then do it with a
 // different function.  Code is the http status response, converted to
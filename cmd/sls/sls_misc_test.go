@@ -351,6 +351,20 @@ OrdRstweaH84Wxjriy/PGWbxUxgPRu0=
 	const slsDump = `
 {
   "Hardware": {
+    "x0": {
+      "Parent": "",
+      "Xname": "x0",
+      "Type": "comptype_cabinet",
+      "Class": "River",
+      "TypeString": "Cabinet"
+    },
+    "x1000": {
+      "Parent": "",
+      "Xname": "x1000",
+      "Type": "comptype_cabinet",
+      "Class": "Mountain",
+      "TypeString": "Cabinet"
+    },
     "x0c0s0b0": {
       "Parent": "x0c0s0",
       "Xname": "x0c0s0b0",
@@ -456,12 +470,12 @@ OrdRstweaH84Wxjriy/PGWbxUxgPRu0=
 	if err != nil {
 		t.Errorf("Error retrieving database contents: %s", err)
 	}
-	if len(currentXnames) != 3 {
+	if len(currentXnames) != 5 {
 		t.Errorf("Datastore has the wrong number of xnames: %d", len(currentXnames))
 	}
 
 	for _, r2 := range currentXnames {
-		if r2 != "x1000c3" && r2 != "x1000c3s2" && r2 != "x0c0s0b0" {
+		if r2 != "x0" && r2 != "x1000" && r2 != "x1000c3" && r2 != "x1000c3s2" && r2 != "x0c0s0b0" {
 			t.Errorf("Unexpected xname in results: %s", r2)
 		}
 	}
@@ -490,6 +504,20 @@ func TestDoLoadstateWithoutKey(t *testing.T) {
 	const slsDump = `
 {
   "Hardware": {
+    "x0": {
+      "Parent": "",
+      "Xname": "x0",
+      "Type": "comptype_cabinet",
+      "Class": "River",
+      "TypeString": "Cabinet"
+    },
+    "x1000": {
+      "Parent": "",
+      "Xname": "x1000",
+      "Type": "comptype_cabinet",
+      "Class": "Mountain",
+      "TypeString": "Cabinet"
+    },
     "x0c0s0b0": {
       "Parent": "x0c0s0",
       "Xname": "x0c0s0b0",
@@ -586,12 +614,12 @@ func TestDoLoadstateWithoutKey(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error retrieving database contents: %s", err)
 	}
-	if len(currentXnames) != 3 {
+	if len(currentXnames) != 5 {
 		t.Errorf("Datastore has the wrong number of xnames: %d", len(currentXnames))
 	}
 
 	for _, r2 := range currentXnames {
-		if r2 != "x1000c3" && r2 != "x1000c3s2" && r2 != "x0c0s0b0" {
+		if r2 != "x0" && r2 != "x1000" && r2 != "x1000c3" && r2 != "x1000c3s2" && r2 != "x0c0s0b0" {
 			t.Errorf("Unexpected xname in results: %s", r2)
 		}
 	}
@@ -605,6 +633,431 @@ func TestDoLoadstateWithoutKey(t *testing.T) {
 	}
 }
 
+func TestDoLoadstateInvalidExtraProperties(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	if err := datastore.SetValidationMode(datastore.ValidationStrict); err != nil {
+		t.Fatal("Unable to set strict validation:", err)
+	}
+	defer func() {
+		_ = datastore.SetValidationMode(datastore.ValidationLenient)
+	}()
+
+	// Preload the database with some data so after we make the request we can make sure it's still there
+	sampleObj := sls_common.GenericHardware{"x0", []string{}, "x0c0", sls_common.Chassis, sls_common.ClassRiver, base.Chassis, 0, "2014-07-16 20:55:46 +0000 UTC", nil, nil}
+	datastore.SetXname(sampleObj.Xname, sampleObj)
+
+	const slsDump = `
+{
+  "Hardware": {
+    "x1000c3s2b0n0": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": {
+        "NID": "abc",
+        "Role": "Compute"
+      }
+    }
+  },
+  "Networks": {}
+}
+`
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fw, err := writer.CreateFormFile("sls_dump", "sls_test_config.json")
+	if err != nil {
+		t.Error("Failed to create form file for dump:", err)
+	}
+	_, err = io.Copy(fw, strings.NewReader(slsDump))
+	if err != nil {
+		t.Error("Failed to copy form file for dump:", err)
+	}
+
+	writer.Close()
+
+	t.Log("Making request to /loadstate")
+	req, rerr := http.NewRequest("POST", "http://localhost:8080"+API_LOADSTATE, &buf)
+	if rerr != nil {
+		t.Error("ERROR setting up /loadstate request:", rerr)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(doLoadState)
+
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusBadRequest, rr.Code)
+	}
+
+	// Nothing was replaced
+	r, err := datastore.GetXname("x0c0")
+	if err != nil {
+		t.Errorf("Error retrieving old data: %s", err)
+	}
+	if r == nil {
+		t.Errorf("Old data was removed from the database!")
+	}
+}
+
+func TestDoLoadstateConflict(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	// Preload the database with some data so after we make the request we can make sure it's still there
+	sampleObj := sls_common.GenericHardware{"x0", []string{}, "x0c0", sls_common.Chassis, sls_common.ClassRiver, base.Chassis, 0, "2014-07-16 20:55:46 +0000 UTC", nil, nil}
+	datastore.SetXname(sampleObj.Xname, sampleObj)
+
+	const slsDump = `
+{
+  "Hardware": {
+    "x1000": {
+      "Parent": "",
+      "Xname": "x1000",
+      "Type": "comptype_cabinet",
+      "Class": "Mountain",
+      "TypeString": "Cabinet"
+    },
+    "x1000c3s2b0n0": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": %s
+    },
+    "x1000c3s2b0n1": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n1",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": %s
+    }
+  },
+  "Networks": {}
+}
+`
+	conflicts := [][2]string{
+		// Shared alias
+		{`{"NID": 1, "Role": "Compute", "Aliases": ["nid000001"]}`, `{"NID": 2, "Role": "Compute", "Aliases": ["NID000001"]}`},
+		// Shared NID
+		{`{"NID": 1, "Role": "Compute"}`, `{"NID": 1, "Role": "Compute"}`},
+	}
+
+	for _, conflict := range conflicts {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+
+		fw, err := writer.CreateFormFile("sls_dump", "sls_test_config.json")
+		if err != nil {
+			t.Error("Failed to create form file for dump:", err)
+		}
+		_, err = io.Copy(fw, strings.NewReader(fmt.Sprintf(slsDump, conflict[0], conflict[1])))
+		if err != nil {
+			t.Error("Failed to copy form file for dump:", err)
+		}
+
+		writer.Close()
+
+		t.Log("Making request to /loadstate")
+		req, rerr := http.NewRequest("POST", "http://localhost:8080"+API_LOADSTATE, &buf)
+		if rerr != nil {
+			t.Error("ERROR setting up /loadstate request:", rerr)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(doLoadState)
+
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusConflict, rr.Code)
+		}
+
+		// Nothing was replaced
+		r, err := datastore.GetXname("x0c0")
+		if err != nil {
+			t.Errorf("Error retrieving old data: %s", err)
+		}
+		if r == nil {
+			t.Errorf("Old data was removed from the database!")
+		}
+	}
+}
+
+// Send slsDump to /loadstate with the given query.
+
+func postLoadState(t *testing.T, slsDump string, query string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fw, err := writer.CreateFormFile("sls_dump", "sls_test_config.json")
+	if err != nil {
+		t.Error("Failed to create form file for dump:", err)
+	}
+	_, err = io.Copy(fw, strings.NewReader(slsDump))
+	if err != nil {
+		t.Error("Failed to copy form file for dump:", err)
+	}
+
+	writer.Close()
+
+	t.Log("Making request to /loadstate" + query)
+	req, rerr := http.NewRequest("POST", "http://localhost:8080"+API_LOADSTATE+query, &buf)
+	if rerr != nil {
+		t.Error("ERROR setting up /loadstate request:", rerr)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(doLoadState)
+
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestDoLoadstateModes(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	err := database.DeleteAllGenericHardware()
+	if err != nil {
+		t.Error("Error clearing database:", err)
+	}
+
+	// Preload the database with some data the loads should leave alone or merge into
+	sampleObj := sls_common.GenericHardware{"x0", []string{}, "x0c0", sls_common.Chassis, sls_common.ClassRiver, base.Chassis, 0, "2014-07-16 20:55:46 +0000 UTC", nil, nil}
+	datastore.SetXname(sampleObj.Xname, sampleObj)
+	sampleCabinet := sls_common.GenericHardware{"", []string{}, "x1000", sls_common.Cabinet, sls_common.ClassMountain, base.Cabinet, 0, "", nil, nil}
+	datastore.SetXname(sampleCabinet.Xname, sampleCabinet)
+	sampleNode := sls_common.GenericHardware{"x1000c3s2b0", []string{}, "x1000c3s2b0n0", sls_common.Node, sls_common.ClassMountain, base.Node, 0, "", map[string]interface{}{"NID": 1, "Role": "Compute"}, nil}
+	datastore.SetXname(sampleNode.Xname, sampleNode)
+
+	const slsDump = `
+{
+  "Hardware": {
+    "x1000": {
+      "Parent": "",
+      "Xname": "x1000",
+      "Type": "comptype_cabinet",
+      "Class": "Mountain",
+      "TypeString": "Cabinet"
+    },
+    "x1000c3s2b0n0": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": %s
+    },
+    "x1000c3s2b0n1": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n1",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": {"NID": 2, "Role": "Compute"}
+    }
+  },
+  "Networks": {}
+}
+`
+
+	// A dry run reports what would change without changing it
+	rr := postLoadState(t, fmt.Sprintf(slsDump, `{"Aliases": ["nid000001"]}`), "?mode=merge&dryRun=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusOK, rr.Code)
+	}
+	var result sls_common.SLSDiff
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal("Unable to unmarshal dry run:", err)
+	}
+	if len(result.Hardware.Added) != 1 || result.Hardware.Added[0].Xname != "x1000c3s2b0n1" {
+		t.Errorf("Dry run has the wrong additions: %v", result.Hardware.Added)
+	}
+	if len(result.Hardware.Removed) != 0 {
+		t.Errorf("Dry run of a merge removes hardware: %v", result.Hardware.Removed)
+	}
+	if len(result.Hardware.Modified) != 1 || len(result.Hardware.Modified[0].Changes) != 1 ||
+		result.Hardware.Modified[0].Changes[0].Path != "/ExtraProperties/Aliases" {
+		t.Errorf("Dry run has the wrong modifications: %v", result.Hardware.Modified)
+	}
+	r, err := datastore.GetXname("x1000c3s2b0n1")
+	if err != nil {
+		t.Errorf("Error retrieving data: %s", err)
+	}
+	if r != nil {
+		t.Errorf("Dry run added hardware to the database!")
+	}
+
+	rr = postLoadState(t, fmt.Sprintf(slsDump, `{"Aliases": ["nid000001"]}`), "?dryRun=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusOK, rr.Code)
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal("Unable to unmarshal dry run:", err)
+	}
+	if len(result.Hardware.Removed) != 1 || result.Hardware.Removed[0].Xname != "x0c0" {
+		t.Errorf("Dry run of a replace has the wrong removals: %v", result.Hardware.Removed)
+	}
+
+	// Merging keeps the hardware that isn't loaded and the ExtraProperties that aren't given
+	rr = postLoadState(t, fmt.Sprintf(slsDump, `{"Aliases": ["nid000001"]}`), "?mode=merge")
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusNoContent, rr.Code)
+	}
+	for _, xname := range []string{"x0c0", "x1000c3s2b0n0", "x1000c3s2b0n1"} {
+		r, err = datastore.GetXname(xname)
+		if err != nil || r == nil {
+			t.Errorf("%s is not in the database after merging: %v", xname, err)
+		}
+	}
+	r, _ = datastore.GetXname("x1000c3s2b0n0")
+	if r != nil {
+		properties, _ := r.ExtraPropertiesRaw.(map[string]interface{})
+		if properties["NID"] != 1.0 || properties["Role"] != "Compute" || properties["Aliases"] == nil {
+			t.Errorf("ExtraProperties were not merged: %v", r.ExtraPropertiesRaw)
+		}
+	}
+
+	// Upserting overwrites what is loaded and still keeps everything else
+	rr = postLoadState(t, fmt.Sprintf(slsDump, `{"NID": 3, "Role": "Compute"}`), "?mode=upsert-only")
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusNoContent, rr.Code)
+	}
+	r, err = datastore.GetXname("x0c0")
+	if err != nil || r == nil {
+		t.Errorf("x0c0 is not in the database after upserting: %v", err)
+	}
+	r, _ = datastore.GetXname("x1000c3s2b0n0")
+	if r != nil {
+		properties, _ := r.ExtraPropertiesRaw.(map[string]interface{})
+		if properties["NID"] != 3.0 || properties["Aliases"] != nil {
+			t.Errorf("ExtraProperties were not overwritten: %v", r.ExtraPropertiesRaw)
+		}
+	}
+
+	for _, query := range []string{"?mode=append", "?dryRun=maybe"} {
+		rr = postLoadState(t, fmt.Sprintf(slsDump, `{}`), query)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("ERROR in /loadstate%s request, expected %d status, got: %d\n", query,
+				http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestDoLoadstateValidation(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	// Preload the database with some data so after we make the request we can make sure it's still there
+	sampleObj := sls_common.GenericHardware{"x0", []string{}, "x0c0", sls_common.Chassis, sls_common.ClassRiver, base.Chassis, 0, "2014-07-16 20:55:46 +0000 UTC", nil, nil}
+	datastore.SetXname(sampleObj.Xname, sampleObj)
+
+	const slsDump = `
+{
+  "Hardware": {
+    "x1000c3s2b0n0": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "NodeBMC",
+      "ExtraProperties": {"NID": 1, "Role": "Compute"}
+    },
+    "x1000c3s2b0n1": {
+      "Parent": "x1000c3s2b0",
+      "Xname": "x1000c3s2b0n2",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": {"NID": 2, "Role": "Compute"}
+    },
+    "x1000q3": {
+      "Parent": "x1000",
+      "Xname": "x1000q3",
+      "Type": "comptype_chassis",
+      "Class": "Mountain",
+      "TypeString": "Chassis"
+    },
+    "x1000c3": {
+      "Parent": "s0",
+      "Xname": "x1000c3",
+      "Type": "comptype_chassis",
+      "Class": "Mountain",
+      "TypeString": "Chassis"
+    },
+    "x1001c0s0b0n0": {
+      "Parent": "x1001c0s0b0",
+      "Xname": "x1001c0s0b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": {"NID": 3, "Role": "Compute"}
+    }
+  },
+  "Networks": {
+    "HSN": {
+      "Name": "HSN",
+      "FullName": "High Speed Network",
+      "IPRanges": ["192.168.1.0/28"],
+      "Type": "token-ring"
+    }
+  }
+}
+`
+	rr := postLoadState(t, slsDump, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusBadRequest, rr.Code)
+	}
+
+	// Every problem is reported, under the key of the object it is with
+	var problem sls_common.ValidationProblem
+	err := json.Unmarshal(rr.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatal("Unable to unmarshal problem:", err)
+	}
+	if problem.Status != http.StatusBadRequest {
+		t.Errorf("Problem has the wrong status: %d", problem.Status)
+	}
+	keys := make(map[string]bool)
+	for _, validationError := range problem.Errors {
+		keys[validationError.Key] = true
+	}
+	for _, key := range []string{"/Hardware/x1000c3s2b0n0", "/Hardware/x1000c3s2b0n1", "/Hardware/x1000q3",
+		"/Hardware/x1000c3", "/Hardware/x1001c0s0b0n0", "/Networks/HSN"} {
+		if !keys[key] {
+			t.Errorf("No problem reported for %s: %v", key, problem.Errors)
+		}
+	}
+	if len(problem.Errors) != 6 {
+		t.Errorf("Problem has the wrong number of errors: %v", problem.Errors)
+	}
+
+	// Nothing was replaced
+	r, err := datastore.GetXname("x0c0")
+	if err != nil {
+		t.Errorf("Error retrieving old data: %s", err)
+	}
+	if r == nil {
+		t.Errorf("Old data was removed from the database!")
+	}
+}
+
 func TestDoDumpstate(t *testing.T) {
 	kerr := setupInit(t)
 	if kerr != nil {
@@ -711,4 +1164,173 @@ func TestDoDumpstate(t *testing.T) {
 			t.Errorf("Missing expected  network name %s!", name)
 		}
 	}
+
+	// The same request with the returned ETag should not send the state again.
+	req, _ = http.NewRequest("GET", "http://localhost:8080"+API_DUMPSTATE, nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("ERROR in /dumpstate GET request with If-None-Match, expected 304 got %d", rr.Code)
+	}
+}
+
+func TestGetIfMatch(t *testing.T) {
+	tests := []struct {
+		header       []string
+		exists       bool
+		version      int64
+		expectedPass bool
+	}{
+		{nil, false, 0, true},
+		{nil, true, 7, true},
+		{[]string{`"7"`}, true, 7, true},
+		{[]string{`"6"`}, true, 7, false},
+		{[]string{`"6", "7"`}, true, 7, true},
+		{[]string{`"6"`, `"7"`}, true, 7, true},
+		{[]string{`W/"7"`}, true, 7, false},
+		{[]string{`"bogus"`}, true, 7, false},
+		{[]string{`*`}, true, 7, true},
+		{[]string{`*`}, false, 0, false},
+		{[]string{`"7"`}, false, 0, false},
+	}
+
+	for ii, tt := range tests {
+		req, _ := http.NewRequest("PUT", "http://localhost:8080"+API_HARDWARE+"/x0c0s0b0n0", nil)
+		for _, value := range tt.header {
+			req.Header.Add("If-Match", value)
+		}
+
+		err := getIfMatch(req).Check(tt.exists, tt.version)
+		if (err == nil) != tt.expectedPass {
+			t.Errorf("Test %d: If-Match %v against version %d (exists: %t), expected pass %t got %v",
+				ii, tt.header, tt.version, tt.exists, tt.expectedPass, err)
+		}
+	}
+}
+
+func TestDoLoadstateSingleVersion(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	const slsDump = `
+{
+  "Hardware": {
+    "x6600": {
+      "Parent": "",
+      "Xname": "x6600",
+      "Type": "comptype_cabinet",
+      "Class": "Mountain",
+      "TypeString": "Cabinet"
+    },
+    "x6600c0s0b0n0": {
+      "Parent": "x6600c0s0b0",
+      "Xname": "x6600c0s0b0n0",
+      "Type": "comptype_node",
+      "Class": "Mountain",
+      "TypeString": "Node",
+      "ExtraProperties": {"NID": 6600, "Role": "Compute"}
+    }
+  },
+  "Networks": {
+    "HSN": {
+      "Name": "HSN",
+      "FullName": "High Speed Network",
+      "IPRanges": ["192.168.1.0/28"],
+      "Type": "slingshot10"
+    }
+  }
+}
+`
+	before, err := database.GetCurrentVersion()
+	if err != nil {
+		t.Fatal("Unable to get current version:", err)
+	}
+
+	rr := postLoadState(t, slsDump, "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusNoContent, rr.Code)
+	}
+
+	// Hardware and networks are replaced together
+	after, err := database.GetCurrentVersion()
+	if err != nil {
+		t.Fatal("Unable to get current version:", err)
+	}
+	if after != before+1 {
+		t.Errorf("Expected /loadstate to make one version, went from %d to %d", before, after)
+	}
+
+	r, version, err := datastore.GetXnameAndVersion("x6600c0s0b0n0")
+	if err != nil || r == nil || version != int64(after) {
+		t.Errorf("Hardware was not loaded in version %d: %v %d %v", after, r, version, err)
+	}
+	_, version, err = datastore.GetNetworkAndVersion("HSN")
+	if err != nil || version != int64(after) {
+		t.Errorf("Network was not loaded in version %d: %d %v", after, version, err)
+	}
+}
+
+func TestDoLoadstateSnapshots(t *testing.T) {
+	kerr := setupInit(t)
+	if kerr != nil {
+		t.Error("Error with test setup:", kerr)
+	}
+
+	err := datastore.SetLoadStateSnapshots(true, 2)
+	if err != nil {
+		t.Fatal("Unable to set /loadstate snapshots:", err)
+	}
+	defer datastore.SetLoadStateSnapshots(true, 10)
+
+	for nid := 6600; nid < 6603; nid++ {
+		before, err := database.GetCurrentVersion()
+		if err != nil {
+			t.Fatal("Unable to get current version:", err)
+		}
+
+		rr := postLoadState(t, fmt.Sprintf(`{"Hardware":{"x6600":{"Parent":"","Xname":"x6600",`+
+			`"Type":"comptype_cabinet","Class":"Mountain","TypeString":"Cabinet"},`+
+			`"x6600c0s0b0n0":{"Parent":"x6600c0s0b0",`+
+			`"Xname":"x6600c0s0b0n0","Type":"comptype_node","Class":"Mountain","TypeString":"Node",`+
+			`"ExtraProperties":{"NID":%d,"Role":"Compute"}}}}`, nid), "")
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("ERROR in /loadstate request, expected %d status, got: %d\n", http.StatusNoContent, rr.Code)
+		}
+
+		// The snapshot is named after the version it is of, the one the load replaced
+		name := fmt.Sprintf("loadstate-%d", before)
+		snapshots, err := datastore.GetSnapshots()
+		if err != nil {
+			t.Fatal("Unable to get snapshots:", err)
+		}
+		found := false
+		for _, snapshot := range snapshots {
+			if snapshot.Name == name {
+				found = true
+				if snapshot.Version != int64(before) {
+					t.Errorf("Expected snapshot %s to be of version %d, not %d", name, before, snapshot.Version)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected /loadstate to take snapshot %s, got %v", name, snapshots)
+		}
+	}
+
+	snapshots, err := datastore.GetSnapshots()
+	if err != nil {
+		t.Fatal("Unable to get snapshots:", err)
+	}
+	var kept int
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.Name, "loadstate-") {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Errorf("Expected 2 /loadstate snapshots to be kept, got %d: %v", kept, snapshots)
+	}
 }
@@ -24,6 +24,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -32,6 +33,7 @@ import (
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/database"
 	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 	"github.com/gorilla/mux"
 )
@@ -39,8 +41,36 @@ import (
 //  /networks GET API
 
 func doNetworksGet(w http.ResponseWriter, r *http.Request) {
-	// Get the networks from the database
-	networks, err := datastore.GetAllNetworks()
+	// Grab the version before the networks so the ETag can never be newer than what is returned.
+	version, asOf, status, err := getReadVersion(r)
+	if status == http.StatusInternalServerError {
+		log.Println("ERROR: Can't get current version from DB:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get version info from DB",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Invalid asOfVersion or asOf:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			http.StatusText(status),
+			err.Error(),
+			r.URL.Path, status)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if checkNotModified(w, r, version) {
+		return
+	}
+
+	// Get the networks from the database, or from the revisions for an older version
+	var networks []sls_common.Network
+	if asOf {
+		networks, err = datastore.GetAllNetworksAsOf(version)
+	} else {
+		networks, err = datastore.GetAllNetworks()
+	}
 	if err != nil {
 		log.Println("ERROR: Can't get networks from DB:", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -62,6 +92,7 @@ func doNetworksGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(ba)
@@ -137,7 +168,7 @@ func doNetworkObjGet(w http.ResponseWriter, r *http.Request) {
 	networkName := mux.Vars(r)["network"]
 
 	// Get the networks from the database
-	network, err := datastore.GetNetwork(networkName)
+	network, version, err := datastore.GetNetworkAndVersion(networkName)
 	if err == database.NoSuch {
 		log.Println("ERROR: ", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -156,6 +187,10 @@ func doNetworkObjGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if checkNotModified(w, r, version) {
+		return
+	}
+
 	ba, err := json.Marshal(network)
 	if err != nil {
 		log.Println("ERROR: JSON marshal of network failed:", err)
@@ -167,6 +202,67 @@ func doNetworkObjGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /networks/{network}/history GET API
+
+func doNetworkObjHistoryGet(w http.ResponseWriter, r *http.Request) {
+	networkName := mux.Vars(r)["network"]
+
+	limit, offset, err := getPage(r)
+	if err != nil {
+		log.Println("ERROR: Invalid limit or offset:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"limit and offset must be integers",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	history, err := datastore.GetNetworkHistory(networkName, limit, offset)
+	if errors.Is(err, datastore.InvalidPage) {
+		log.Println("ERROR: Invalid limit or offset:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Failed to get network history from DB:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Failed to get network history from DB",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if history == nil {
+		log.Println("ERROR: Network not found in DB:", networkName)
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"Network not found in DB",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	ba, err := json.Marshal(history)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of network history failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(ba)
@@ -206,10 +302,17 @@ func doNetworkObjPut(w http.ResponseWriter, r *http.Request) {
 	// reference for which row to update.
 	network.Name = networkName
 
-	// Now do the update.
-	err = datastore.SetNetwork(network)
-
-	if err != nil {
+	// Now do the update, as long as the network still matches any If-Match the client sent.
+	version, err := datastore.SetNetworkIfMatch(network, getIfMatch(r))
+	if errors.Is(err, database.PreconditionFailed) {
+		log.Println("ERROR: Network does not match If-Match:", r.Header.Get("If-Match"))
+		pdet := base.NewProblemDetails("about: blank",
+			"Precondition Failed",
+			"Network does not match If-Match",
+			r.URL.Path, http.StatusPreconditionFailed)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
 		log.Println("ERROR: Failed to update network in DB:", err)
 		pdet := base.NewProblemDetails("about: blank",
 			"Internal Server Error",
@@ -230,6 +333,7 @@ func doNetworkObjPut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(ba)
@@ -238,8 +342,43 @@ func doNetworkObjPut(w http.ResponseWriter, r *http.Request) {
 //  /networks/{network} PATCH API
 
 func doNetworkObjPatch(w http.ResponseWriter, r *http.Request) {
-	log.Printf("doNetworkObjPatch: not implemented yet.\n")
-	w.WriteHeader(http.StatusNotImplemented)
+	// Figure out what the requested network is
+	networkName := mux.Vars(r)["network"]
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR: Failed to read body: ", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Failed to read body",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	network, version, err := datastore.PatchNetwork(networkName, r.Header.Get("Content-Type"), bodyBytes,
+		getIfMatch(r))
+	if err != nil {
+		log.Println("ERROR: Failed to patch network:", err)
+		sendPatchErrorRsp(w, r, err)
+		return
+	}
+
+	ba, err := json.Marshal(network)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of network failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
 }
 
 //  /networks/{network} DELETE API
@@ -249,8 +388,16 @@ func doNetworkObjDelete(w http.ResponseWriter, r *http.Request) {
 	networkName := mux.Vars(r)["network"]
 
 	// Delete the network from the DB
-	err := datastore.DeleteNetwork(networkName)
-	if err == database.NoSuch {
+	err := datastore.DeleteNetworkIfMatch(networkName, getIfMatch(r))
+	if err == database.PreconditionFailed {
+		log.Println("ERROR: Network does not match If-Match:", r.Header.Get("If-Match"))
+		pdet := base.NewProblemDetails("about: blank",
+			"Precondition Failed",
+			"Network does not match If-Match",
+			r.URL.Path, http.StatusPreconditionFailed)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err == database.NoSuch {
 		log.Println("ERROR: ", err)
 		pdet := base.NewProblemDetails("about: blank",
 			"Not Found",
@@ -314,7 +461,7 @@ func doNetworksSearch(w http.ResponseWriter, r *http.Request) {
 
 	network.ExtraPropertiesRaw = properties
 
-	networks, err := datastore.SearchNetworks(network)
+	networks, err := datastore.SearchNetworks(network, r.FormValue("filter"))
 	if err == database.NoSuch {
 		log.Println("ERROR: ", err)
 		pdet := base.NewProblemDetails("about: blank",
@@ -323,6 +470,14 @@ func doNetworksSearch(w http.ResponseWriter, r *http.Request) {
 			r.URL.Path, http.StatusNotFound)
 		base.SendProblemDetails(w, pdet, 0)
 		return
+	} else if errors.Is(err, search.InvalidFilter) {
+		log.Println("ERROR: Invalid search filter:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
 	} else if err != nil {
 		log.Println("ERROR: Failed to search for network:", err)
 		pdet := base.NewProblemDetails("about: blank",
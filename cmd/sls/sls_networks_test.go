@@ -328,3 +328,270 @@ func Test_doNetworkIO(t *testing.T) {
 		t.Errorf("ERROR PUT with bad JSON didn't fail!")
 	}
 }
+
+func doNWPatch(url string, contentType string, body string) *httptest.ResponseRecorder {
+	preq, _ := http.NewRequest("PATCH", url, bytes.NewBufferString(body))
+	preq.Header.Set("Content-Type", contentType)
+
+	pw := httptest.NewRecorder()
+	router.ServeHTTP(pw, preq)
+
+	return pw
+}
+
+func Test_doNetworkPatch(t *testing.T) {
+	if router == nil {
+		routes = generateRoutes()
+		router = newRouter(routes)
+	}
+	dbInit()
+
+	//Clean out whatever is there first
+
+	cleanDB()
+
+	pl := nwTestData{"POST",
+		nwURLBase + "/networks",
+		nwURLBase + "/networks/HMN",
+		json.RawMessage(`{"Name":"HMN","FullName":"Hardware Management Network","IPRanges":["10.254.0.0/17"],"Type":"ethernet",` +
+			`"ExtraProperties":{"CIDR":"10.254.0.0/17","VlanRange":[4],"Subnets":[{"Name":"network_hardware",` +
+			`"FullName":"","CIDR":"10.254.0.0/17","VlanID":4,"Gateway":"10.254.0.1","IPReservations":[` +
+			`{"Name":"sw-spine-001","IPAddress":"10.254.0.2"}]}]}}`),
+		sls_common.Network{},
+	}
+	psterr := doNWSet(pl)
+	if psterr != nil {
+		t.Fatalf("ERROR in POST /networks for PATCH test: %v", psterr)
+	}
+
+	//Merge patch
+
+	t.Logf("PATCH /networks merge patch test")
+	pw := doNWPatch(pl.getURL, "application/merge-patch+json", `{"FullName":"HMN patched"}`)
+	if pw.Code != http.StatusOK {
+		t.Errorf("ERROR in PATCH /networks merge patch test: %d/%s", pw.Code, pw.Body.String())
+	}
+
+	//JSON patch reaching into the IP reservations
+
+	t.Logf("PATCH /networks JSON patch test")
+	pw = doNWPatch(pl.getURL, "application/json-patch+json",
+		`[{"op":"add","path":"/ExtraProperties/Subnets/0/IPReservations/-","value":{"Name":"sw-spine-002","IPAddress":"10.254.0.3"}}]`)
+	if pw.Code != http.StatusOK {
+		t.Errorf("ERROR in PATCH /networks JSON patch test: %d/%s", pw.Code, pw.Body.String())
+	}
+
+	jdata, gterr := doNWObjGet(pl)
+	if gterr != nil {
+		t.Fatalf("ERROR in PATCH /networks test GET op: %v", gterr)
+	}
+	if jdata.FullName != "HMN patched" {
+		t.Errorf("ERROR merge patch not applied, FullName is '%s'", jdata.FullName)
+	}
+
+	var extraProperties sls_common.NetworkExtraProperties
+	epBytes, _ := json.Marshal(jdata.ExtraPropertiesRaw)
+	_ = json.Unmarshal(epBytes, &extraProperties)
+	if len(extraProperties.Subnets) != 1 || len(extraProperties.Subnets[0].IPReservations) != 2 {
+		t.Errorf("ERROR JSON patch not applied, got: %v", extraProperties)
+	}
+
+	//Error cases
+
+	errTests := []struct {
+		url         string
+		contentType string
+		body        string
+		code        int
+	}{
+		{pl.getURL, "application/json", `{"FullName":"x"}`, http.StatusUnsupportedMediaType},
+		{pl.getURL, "application/merge-patch+json", `{"Type":"token_ring"}`, http.StatusBadRequest},
+		{pl.getURL, "application/merge-patch+json", `{"Name":"NMN"}`, http.StatusBadRequest},
+		{pl.getURL, "application/merge-patch+json", `{"FullName":"x"} {"FullName":"y"}`, http.StatusBadRequest},
+		{pl.getURL, "application/json-patch+json", `[{"op":"test","path":"/Type","value":"mixed"}]`, http.StatusConflict},
+		{pl.getURL, "application/json-patch+json", `[{"op":"remove","path":"/ExtraProperties/Subnets/5"}]`, http.StatusConflict},
+		{nwURLBase + "/networks/ZZZ", "application/merge-patch+json", `{"FullName":"x"}`, http.StatusNotFound},
+	}
+
+	for ii, et := range errTests {
+		pw = doNWPatch(et.url, et.contentType, et.body)
+		if pw.Code != et.code {
+			t.Errorf("ERROR PATCH /networks error test %d, expected %d got %d", ii, et.code, pw.Code)
+		}
+	}
+}
+
+func doNWReq(method string, url string, header string, value string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	if method == "PATCH" {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func Test_doNetworkETag(t *testing.T) {
+	if router == nil {
+		routes = generateRoutes()
+		router = newRouter(routes)
+	}
+	dbInit()
+
+	//Clean out whatever is there first
+
+	cleanDB()
+
+	pl := nwTestData{"POST",
+		nwURLBase + "/networks",
+		nwURLBase + "/networks/HMN",
+		json.RawMessage(`{"Name":"HMN","FullName":"Hardware Management Network","IPRanges":["10.254.0.0/17"],"Type":"ethernet"}`),
+		sls_common.Network{},
+	}
+	psterr := doNWSet(pl)
+	if psterr != nil {
+		t.Fatalf("ERROR in POST /networks for ETag test: %v", psterr)
+	}
+
+	//Conditional GETs
+
+	gw := doNWReq("GET", pl.getURL, "", "", "")
+	etag := gw.Header().Get("ETag")
+	if gw.Code != http.StatusOK || etag == "" {
+		t.Fatalf("ERROR in GET /networks/HMN, expected an ETag: %d/%v", gw.Code, gw.Header())
+	}
+	gw = doNWReq("GET", pl.getURL, "If-None-Match", etag, "")
+	if gw.Code != http.StatusNotModified {
+		t.Errorf("ERROR in GET /networks/HMN with If-None-Match, expected 304 got %d", gw.Code)
+	}
+
+	cw := doNWReq("GET", pl.setURL, "", "", "")
+	collectionETag := cw.Header().Get("ETag")
+	cw = doNWReq("GET", pl.setURL, "If-None-Match", collectionETag, "")
+	if cw.Code != http.StatusNotModified {
+		t.Errorf("ERROR in GET /networks with If-None-Match, expected 304 got %d", cw.Code)
+	}
+
+	//Conditional writes
+
+	pw := doNWReq("PATCH", pl.getURL, "If-Match", `"0"`, `{"FullName":"HMN stale"}`)
+	if pw.Code != http.StatusPreconditionFailed {
+		t.Errorf("ERROR in PATCH /networks/HMN with stale If-Match, expected 412 got %d", pw.Code)
+	}
+	pw = doNWReq("PATCH", pl.getURL, "If-Match", etag, `{"FullName":"HMN patched"}`)
+	if pw.Code != http.StatusOK {
+		t.Fatalf("ERROR in PATCH /networks/HMN with current If-Match, expected 200 got %d", pw.Code)
+	}
+	newETag := pw.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("ERROR in PATCH /networks/HMN, expected a new ETag, got '%s'", newETag)
+	}
+
+	uw := doNWReq("PUT", pl.getURL, "If-Match", etag, string(pl.setString))
+	if uw.Code != http.StatusPreconditionFailed {
+		t.Errorf("ERROR in PUT /networks/HMN with stale If-Match, expected 412 got %d", uw.Code)
+	}
+
+	cw = doNWReq("GET", pl.setURL, "If-None-Match", collectionETag, "")
+	if cw.Code != http.StatusOK {
+		t.Errorf("ERROR in GET /networks with outdated If-None-Match, expected 200 got %d", cw.Code)
+	}
+
+	dw := doNWReq("DELETE", pl.getURL, "If-Match", etag, "")
+	if dw.Code != http.StatusPreconditionFailed {
+		t.Errorf("ERROR in DELETE /networks/HMN with stale If-Match, expected 412 got %d", dw.Code)
+	}
+	dw = doNWReq("DELETE", pl.getURL, "If-Match", newETag, "")
+	if dw.Code != http.StatusOK {
+		t.Errorf("ERROR in DELETE /networks/HMN with current If-Match, expected 200 got %d", dw.Code)
+	}
+}
+
+func Test_doNetworkHistory(t *testing.T) {
+	if router == nil {
+		routes = generateRoutes()
+		router = newRouter(routes)
+	}
+	dbInit()
+
+	historyURL := nwURLBase + "/networks/T6000/history"
+	getHistory := func(query string) (int, sls_common.NetworkHistory) {
+		var history sls_common.NetworkHistory
+		hw := doNWReq("GET", historyURL+query, "", "", "")
+		if hw.Code == http.StatusOK {
+			if err := json.Unmarshal(hw.Body.Bytes(), &history); err != nil {
+				t.Errorf("ERROR unmarshalling network history: %v", err)
+			}
+		}
+		return hw.Code, history
+	}
+
+	// Earlier runs against the same DB leave history behind.
+	var before int64
+	if code, history := getHistory(""); code == http.StatusOK {
+		before = history.Total
+	} else if code != http.StatusNotFound {
+		t.Fatalf("ERROR in GET /networks/T6000/history, expected 200 or 404 got %d", code)
+	}
+
+	pw := doNWReq("POST", nwURLBase+"/networks", "", "",
+		`{"Name":"T6000","FullName":"History test network","IPRanges":["10.160.0.0/24"],"Type":"ethernet"}`)
+	if pw.Code != http.StatusCreated {
+		t.Fatalf("ERROR in POST /networks for history test: %d", pw.Code)
+	}
+	pw = doNWReq("PATCH", nwURLBase+"/networks/T6000", "", "", `{"FullName":"History test network patched"}`)
+	if pw.Code != http.StatusOK {
+		t.Fatalf("ERROR in PATCH /networks/T6000 for history test: %d", pw.Code)
+	}
+	dw := doNWReq("DELETE", nwURLBase+"/networks/T6000", "", "", "")
+	if dw.Code != http.StatusOK {
+		t.Fatalf("ERROR in DELETE /networks/T6000 for history test: %d", dw.Code)
+	}
+
+	// The history outlives the network, newest first.
+	code, history := getHistory("?limit=3")
+	if code != http.StatusOK {
+		t.Fatalf("ERROR in GET /networks/T6000/history, expected 200 got %d", code)
+	}
+	if history.Name != "T6000" || history.Total != before+3 || len(history.Revisions) != 3 {
+		t.Fatalf("ERROR in GET /networks/T6000/history, expected 3 new revisions got %+v", history)
+	}
+
+	deleted, updated, inserted := history.Revisions[0], history.Revisions[1], history.Revisions[2]
+	if deleted.Operation != "delete" || deleted.Before == nil || deleted.After != nil ||
+		deleted.Before.FullName != "History test network patched" {
+		t.Errorf("ERROR in GET /networks/T6000/history, unexpected delete revision %+v", deleted)
+	}
+	if updated.Operation != "update" || updated.Before == nil || updated.After == nil ||
+		updated.Before.FullName != "History test network" || updated.After.FullName != "History test network patched" {
+		t.Errorf("ERROR in GET /networks/T6000/history, unexpected update revision %+v", updated)
+	}
+	if inserted.Operation != "insert" || inserted.Before != nil || inserted.After == nil ||
+		len(inserted.After.IPRanges) != 1 || inserted.After.IPRanges[0] != "10.160.0.0/24" {
+		t.Errorf("ERROR in GET /networks/T6000/history, unexpected insert revision %+v", inserted)
+	}
+	if !(deleted.Version > updated.Version && updated.Version > inserted.Version) || inserted.Timestamp == 0 {
+		t.Errorf("ERROR in GET /networks/T6000/history, unexpected versions %d, %d, %d",
+			deleted.Version, updated.Version, inserted.Version)
+	}
+
+	code, history = getHistory("?limit=1&offset=1")
+	if code != http.StatusOK || len(history.Revisions) != 1 || history.Revisions[0].Revision != updated.Revision {
+		t.Errorf("ERROR in GET /networks/T6000/history with an offset, expected the update got %d/%+v", code, history)
+	}
+
+	if code, _ = getHistory("?limit=abc"); code != http.StatusBadRequest {
+		t.Errorf("ERROR in GET /networks/T6000/history with a bad limit, expected 400 got %d", code)
+	}
+	if code, _ = getHistory("?offset=-1"); code != http.StatusBadRequest {
+		t.Errorf("ERROR in GET /networks/T6000/history with a negative offset, expected 400 got %d", code)
+	}
+	if hw := doNWReq("GET", nwURLBase+"/networks/T6999/history", "", "", ""); hw.Code != http.StatusNotFound {
+		t.Errorf("ERROR in GET /networks/T6999/history, expected 404 got %d", hw.Code)
+	}
+}
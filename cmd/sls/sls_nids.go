@@ -0,0 +1,103 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+//  /nids GET API
+
+func doNIDsGet(w http.ResponseWriter, r *http.Request) {
+	m, err := datastore.GetNIDMap()
+	if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+
+	ba, err := json.Marshal(m)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of NID map failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /nids/allocate POST API
+
+func doNIDsAllocatePost(w http.ResponseWriter, r *http.Request) {
+	var jdata sls_common.NIDAllocationRequest
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR reading request body:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "error reading REST request")
+		return
+	}
+	err = json.Unmarshal(body, &jdata)
+	if err != nil {
+		log.Println("ERROR unmarshalling request body:", err)
+		sendJsonRsp(w, http.StatusBadRequest, "error decoding JSON")
+		return
+	}
+
+	allocation, err := datastore.AllocateNIDs(jdata)
+	if errors.Is(err, datastore.InvalidNIDAllocation) {
+		log.Println("ERROR, invalid NID allocation request:", err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println("ERROR allocating NIDs:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to allocate NIDs")
+		return
+	}
+	if allocation == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", jdata.Xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(allocation)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of NID allocation failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
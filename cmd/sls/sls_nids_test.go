@@ -0,0 +1,170 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type NIDTestSuite struct {
+	apiTestSuite
+}
+
+func (suite *NIDTestSuite) SetupSuite() {
+	suite.apiTestSuite.SetupSuite()
+
+	// Reservations outlive a test run
+	_, err := database.DB.Exec("DELETE FROM nid_reservations")
+	suite.NoError(err)
+}
+
+func (suite *NIDTestSuite) TearDownSuite() {
+	hwDBClear()
+}
+
+func (suite *NIDTestSuite) getNIDMap() sls_common.NIDMap {
+	response := suite.do("GET", "/nids", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	var m sls_common.NIDMap
+	suite.NoError(json.Unmarshal(response.Body.Bytes(), &m))
+	return m
+}
+
+func (suite *NIDTestSuite) allocate(body string, expectedStatus int) sls_common.NIDAllocation {
+	response := suite.do("POST", "/nids/allocate", body)
+	suite.Equal(expectedStatus, response.Code, "Request: %s Response: %s", body, response.Body.String())
+
+	var allocation sls_common.NIDAllocation
+	if expectedStatus == http.StatusOK {
+		suite.NoError(json.Unmarshal(response.Body.Bytes(), &allocation))
+	}
+	return allocation
+}
+
+func (suite *NIDTestSuite) TestAllocate() {
+	payload := `[
+		{"Parent":"","Xname":"x5400","Type":"comptype_cabinet","TypeString":"Cabinet","Class":"Mountain"},
+		{"Parent":"x5400","Xname":"x5400c0","Type":"comptype_chassis","TypeString":"Chassis","Class":"Mountain"},
+		{"Parent":"x5400c0s10b0","Xname":"x5400c0s10b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"Role":"Compute"}},
+		{"Parent":"x5400c0s1b0","Xname":"x5400c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"Role":"Compute"}},
+		{"Parent":"x5400c0s0b0","Xname":"x5400c0s0b0n1","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"Role":"Compute"}},
+		{"Parent":"x5400c0s0b0","Xname":"x5400c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain"},
+		{"Parent":"x5400c1s0b0","Xname":"x5400c1s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":3,"Role":"Compute"}},
+		{"Parent":"x5401c0s0b0","Xname":"x5401c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":1,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// The map shows the gaps and the nodes still waiting for a NID in topology order
+	m := suite.getNIDMap()
+	suite.Equal([]sls_common.NodeNID{{NID: 1, Xname: "x5401c0s0b0n0"}, {NID: 3, Xname: "x5400c1s0b0n0"}}, m.NIDs)
+	suite.Equal([]sls_common.NIDRange{{First: 2, Last: 2}}, m.Gaps)
+	suite.Empty(m.Duplicates)
+	suite.Equal([]string{"x5400c0s0b0n0", "x5400c0s0b0n1", "x5400c0s1b0n0", "x5400c0s10b0n0"}, m.Unassigned)
+
+	// A count reserves free NIDs, so they aren't handed out again
+	allocation := suite.allocate(`{"Count":3}`, http.StatusOK)
+	suite.Equal([]sls_common.NodeNID{{NID: 2}, {NID: 4}, {NID: 5}}, allocation.NIDs)
+	suite.Equal(int64(0), allocation.Version)
+	suite.NotEmpty(allocation.ReservedUntil)
+
+	allocation = suite.allocate(`{"Count":2}`, http.StatusOK)
+	suite.Equal([]sls_common.NodeNID{{NID: 6}, {NID: 7}}, allocation.NIDs)
+
+	allocation = suite.allocate(`{"Count":2,"StartingNID":1000}`, http.StatusOK)
+	suite.Equal([]sls_common.NodeNID{{NID: 1000}, {NID: 1001}}, allocation.NIDs)
+
+	suite.allocate(`{}`, http.StatusBadRequest)
+	suite.allocate(`{"Count":1,"Xname":"x5400"}`, http.StatusBadRequest)
+	suite.allocate(`{"Count":-1}`, http.StatusBadRequest)
+	suite.allocate(`{"Xname":"x5400c0s0b0n0"}`, http.StatusBadRequest)
+	suite.allocate(`{"Xname":"x5499"}`, http.StatusNotFound)
+
+	// A chassis gets NIDs for all of its nodes in topology order, past the reserved ones
+	allocation = suite.allocate(`{"Xname":"x5400c0"}`, http.StatusOK)
+	suite.Equal([]sls_common.NodeNID{
+		{NID: 8, Xname: "x5400c0s0b0n0"},
+		{NID: 9, Xname: "x5400c0s0b0n1"},
+		{NID: 10, Xname: "x5400c0s1b0n0"},
+		{NID: 11, Xname: "x5400c0s10b0n0"},
+	}, allocation.NIDs)
+	suite.NotEqual(int64(0), allocation.Version)
+
+	response = suite.do("GET", "/hardware/x5400c0s0b0n1", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Contains(response.Body.String(), `"NID":9`)
+	suite.Contains(response.Body.String(), `"Role":"Compute"`)
+
+	// Nothing is left to allocate
+	allocation = suite.allocate(`{"Xname":"x5400"}`, http.StatusOK)
+	suite.Empty(allocation.NIDs)
+	suite.Equal(int64(0), allocation.Version)
+
+	m = suite.getNIDMap()
+	suite.Len(m.NIDs, 6)
+	suite.Equal([]sls_common.NIDRange{{First: 2, Last: 2}, {First: 4, Last: 7}}, m.Gaps)
+	suite.Empty(m.Unassigned)
+}
+
+func (suite *NIDTestSuite) TestDuplicates() {
+	payload := `[
+		{"Parent":"x5500c0s0b0","Xname":"x5500c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5500,"Role":"Compute"}},
+		{"Parent":"x5500c0s0b0","Xname":"x5500c0s0b0n1","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5501,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	taken := `{"Parent":"x5500c0s1b0","Xname":"x5500c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5500,"Role":"Compute"}}`
+
+	response = suite.do("POST", "/hardware", taken)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("PUT", "/hardware/x5500c0s1b0n0", taken)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("PUT", "/hardware/x5500c0s0b0n1", `{"Parent":"x5500c0s0b0","Xname":"x5500c0s0b0n1","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5500,"Role":"Compute"}}`)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/hardware/x5500c0s1b0n0", "")
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+
+	// Keeping its own NID is fine
+	response = suite.do("PUT", "/hardware/x5500c0s0b0n0", `{"Parent":"x5500c0s0b0","Xname":"x5500c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"Mountain","ExtraProperties":{"NID":5500,"Role":"Application"}}`)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	for _, xname := range []string{"x5500c0s0b0n0", "x5500c0s0b0n1"} {
+		response = suite.do("DELETE", "/hardware/"+xname, "")
+		suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	}
+}
+
+func TestNIDTestSuite(t *testing.T) {
+	suite.Run(t, new(NIDTestSuite))
+}
@@ -0,0 +1,90 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/gorilla/mux"
+)
+
+func sendPowerTopology(w http.ResponseWriter, r *http.Request, direction string) {
+	vars := mux.Vars(r)
+	xname := base.NormalizeHMSCompID(vars["xname"])
+
+	if !base.IsHMSCompIDValid(xname) {
+		log.Printf("ERROR, invalid xname in request URL: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusBadRequest, "invalid xname")
+		return
+	}
+
+	var types []sls_common.HMSStringType
+	for _, hmsType := range r.URL.Query()["type"] {
+		types = append(types, sls_common.HMSStringType(hmsType))
+	}
+
+	topology, err := datastore.GetPowerTopology(xname, direction, types)
+	if errors.Is(err, datastore.UnknownType) || errors.Is(err, datastore.UnsupportedType) {
+		log.Println("ERROR, invalid type in request URL:", err)
+		sendJsonRsp(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		log.Println("ERROR, DB query failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "failed to query DB")
+		return
+	}
+	if topology == nil {
+		log.Printf("ERROR, requested component not found in DB: '%s'\n", xname)
+		sendJsonRsp(w, http.StatusNotFound, "no such component not in DB")
+		return
+	}
+
+	ba, err := json.Marshal(topology)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of power topology failed:", err)
+		sendJsonRsp(w, http.StatusInternalServerError, "JSON marshal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /power/{xname}/upstream GET API
+
+func doPowerUpstreamGet(w http.ResponseWriter, r *http.Request) {
+	sendPowerTopology(w, r, datastore.PowerUpstream)
+}
+
+//  /power/{xname}/downstream GET API
+
+func doPowerDownstreamGet(w http.ResponseWriter, r *http.Request) {
+	sendPowerTopology(w, r, datastore.PowerDownstream)
+}
@@ -0,0 +1,111 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type PowerTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PowerTestSuite) SetupSuite() {
+	if router == nil {
+		routes = generateRoutes()
+		router = newRouter(routes)
+	}
+
+	dbInit()
+	hwDBClear()
+}
+
+func (suite *PowerTestSuite) TearDownSuite() {
+	hwDBClear()
+}
+
+func (suite *PowerTestSuite) getTopology(xname string, direction string, query string,
+	expectedStatus int) sls_common.PowerTopology {
+	req, reqerr := http.NewRequest("GET", nwURLBase+"/power/"+xname+"/"+direction+query, nil)
+	suite.NoError(reqerr, "creating http GET request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(expectedStatus, response.Code, "Response: %s", response.Body.String())
+
+	var topology sls_common.PowerTopology
+	if expectedStatus == http.StatusOK {
+		suite.NoError(json.Unmarshal(response.Body.Bytes(), &topology))
+	}
+	return topology
+}
+
+func (suite *PowerTestSuite) TestTopology() {
+	payload := `[
+		{"Parent":"x5800","Xname":"x5800m0","Type":"comptype_cab_pdu_controller","TypeString":"CabinetPDUController","Class":"River"},
+		{"Parent":"x5800m0","Xname":"x5800m0p0","Type":"comptype_cab_pdu","TypeString":"CabinetPDU","Class":"River"},
+		{"Parent":"x5800m0p0","Xname":"x5800m0p0v1","Type":"comptype_cab_pdu_pwr_connector","TypeString":"CabinetPDUPowerConnector","Class":"River"},
+		{"Parent":"x5800c0s1","Xname":"x5800c0s1v1","Type":"comptype_compmod_power_connector","TypeString":"NodePowerConnector","Class":"River","ExtraProperties":{"PoweredBy":["x5800m0p0v1"]}},
+		{"Parent":"x5800c0s1","Xname":"x5800c0s1b0","Type":"comptype_ncard","TypeString":"NodeBMC","Class":"River"},
+		{"Parent":"x5800c0s1b0","Xname":"x5800c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":5800,"Role":"Compute"}}
+	]`
+	req, reqerr := http.NewRequest("POST", hwURLBase+"/bulk", bytes.NewBufferString(payload))
+	suite.NoError(reqerr, "creating http POST request")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// Maintenance on the PDU takes the node down
+	topology := suite.getTopology("x5800m0p0", "downstream", "?type=comptype_node&type=comptype_compmod", http.StatusOK)
+	suite.Equal("x5800m0p0", topology.Xname)
+	suite.Equal("downstream", topology.Direction)
+	suite.Equal([]sls_common.PowerHop{
+		{Xname: "x5800c0s1", Type: sls_common.ComputeModule, Depth: 3, PoweredBy: []string{"x5800c0s1v1"}},
+		{Xname: "x5800c0s1b0n0", Type: sls_common.Node, Depth: 5, PoweredBy: []string{"x5800c0s1b0"}},
+	}, topology.Hardware)
+
+	// And the node can be followed back to it
+	topology = suite.getTopology("x5800c0s1b0n0", "upstream", "", http.StatusOK)
+	var chain []string
+	for _, hop := range topology.Hardware {
+		chain = append(chain, hop.Xname)
+	}
+	suite.Equal([]string{"x5800c0s1b0", "x5800c0s1", "x5800c0s1v1", "x5800m0p0v1", "x5800m0p0", "x5800m0"}, chain)
+
+	topology = suite.getTopology("x5800m0", "upstream", "", http.StatusOK)
+	suite.Empty(topology.Hardware)
+
+	suite.getTopology("x5800m0p0", "downstream", "?type=comptype_all", http.StatusBadRequest)
+	suite.getTopology("foo", "upstream", "", http.StatusBadRequest)
+	suite.getTopology("x5899m0", "downstream", "", http.StatusNotFound)
+}
+
+func TestPowerTestSuite(t *testing.T) {
+	suite.Run(t, new(PowerTestSuite))
+}
@@ -0,0 +1,107 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+//  /restore POST API
+
+func doRestorePost(w http.ResponseWriter, r *http.Request) {
+	var request sls_common.RestoreRequest
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR: Unable to read request body:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to read request body",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		log.Println("ERROR: Unable to unmarshal restore request:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Unable to unmarshal restore request",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	result, err := datastore.RestoreVersion(request)
+	if errors.Is(err, datastore.InvalidAsOf) {
+		log.Println("ERROR: Invalid restore request:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if errors.Is(err, datastore.VersionUnavailable) {
+		log.Println("ERROR: Unable to restore:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			err.Error(),
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to restore:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to restore",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Restored version %d as version %d, hardware %+v, networks %+v", result.RestoredVersion,
+		result.Version, result.Hardware, result.Networks)
+
+	ba, err := json.Marshal(result)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of restore result failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
@@ -0,0 +1,188 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type RestoreTestSuite struct {
+	apiTestSuite
+}
+
+func (suite *RestoreTestSuite) TearDownSuite() {
+	req, _ := http.NewRequest("DELETE", nwURLBase+"/networks/T6100", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	hwDBClear()
+}
+
+func (suite *RestoreTestSuite) getHardware(query string) map[string]sls_common.GenericHardware {
+	response := suite.do("GET", "/hardware"+query, "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	var hardware []sls_common.GenericHardware
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &hardware))
+
+	byXname := make(map[string]sls_common.GenericHardware)
+	for _, h := range hardware {
+		byXname[h.Xname] = h
+	}
+	return byXname
+}
+
+func (suite *RestoreTestSuite) getNID(hardware sls_common.GenericHardware) interface{} {
+	properties, ok := hardware.ExtraPropertiesRaw.(map[string]interface{})
+	suite.Require().True(ok, "%s has no ExtraProperties", hardware.Xname)
+	return properties["NID"]
+}
+
+func (suite *RestoreTestSuite) TestRestore() {
+	payload := `[
+		{"Parent":"x6100c0s0b0","Xname":"x6100c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6100,"Role":"Compute"}},
+		{"Parent":"x6100c0s1b0","Xname":"x6100c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6101,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("POST", "/networks",
+		`{"Name":"T6100","FullName":"Restore test network","IPRanges":["10.161.0.0/24"],"Type":"ethernet"}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/hardware", "")
+	etag := response.Header().Get("ETag")
+	unquoted, err := strconv.Unquote(etag)
+	suite.Require().NoError(err, "ETag %s", etag)
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	suite.Require().NoError(err)
+
+	// Renumber one node, replace the other and drop the network
+	response = suite.do("PATCH", "/hardware/x6100c0s0b0n0", `{"ExtraProperties":{"NID":6110}}`)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/hardware/x6100c0s1b0n0", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("POST", "/hardware",
+		`{"Parent":"x6100c0s2b0","Xname":"x6100c0s2b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6101,"Role":"Compute"}}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/networks/T6100", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	// Reading as of the version from before the changes
+	asOfVersion := "?asOfVersion=" + unquoted
+	hardware := suite.getHardware(asOfVersion)
+	suite.Contains(hardware, "x6100c0s1b0n0")
+	suite.NotContains(hardware, "x6100c0s2b0n0")
+	suite.EqualValues(6100, suite.getNID(hardware["x6100c0s0b0n0"]))
+
+	response = suite.do("GET", "/hardware"+asOfVersion, "")
+	suite.Equal(etag, response.Header().Get("ETag"))
+
+	response = suite.do("GET", "/networks"+asOfVersion, "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var networks []sls_common.Network
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &networks))
+	var found bool
+	for _, network := range networks {
+		found = found || network.Name == "T6100"
+	}
+	suite.True(found, "T6100 is missing as of version %d", version)
+
+	response = suite.do("GET", "/dumpstate"+asOfVersion, "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var state sls_common.SLSState
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &state))
+	suite.Contains(state.Hardware, "x6100c0s1b0n0")
+	suite.NotContains(state.Hardware, "x6100c0s2b0n0")
+	suite.Contains(state.Networks, "T6100")
+
+	// A time after the latest version is the current state
+	hardware = suite.getHardware("?asOf=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	suite.Contains(hardware, "x6100c0s2b0n0")
+	suite.EqualValues(6110, suite.getNID(hardware["x6100c0s0b0n0"]))
+
+	for query, status := range map[string]int{
+		"?asOfVersion=abc":                         http.StatusBadRequest,
+		"?asOf=yesterday":                          http.StatusBadRequest,
+		"?asOfVersion=1&asOf=2021-01-01T00:00:00Z": http.StatusBadRequest,
+		"?asOfVersion=0":                           http.StatusNotFound,
+		"?asOfVersion=" + strconv.Itoa(1<<30):      http.StatusNotFound,
+		"?asOf=1970-01-01T00:00:00Z":               http.StatusNotFound,
+	} {
+		for _, url := range []string{"/hardware", "/networks", "/dumpstate"} {
+			response = suite.do("GET", url+query, "")
+			suite.Equal(status, response.Code, "GET %s%s: %s", url, query, response.Body.String())
+		}
+	}
+
+	// Restoring brings it all back as a new version
+	response = suite.do("POST", "/restore", `{"Version":`+unquoted+`}`)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var result sls_common.RestoreResult
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &result))
+	suite.Equal(version, result.RestoredVersion)
+	suite.Greater(result.Version, version)
+	suite.Equal(sls_common.RestoreCounts{Inserted: 1, Updated: 1, Deleted: 1}, result.Hardware)
+	suite.EqualValues(1, result.Networks.Inserted)
+	suite.Zero(result.Networks.Deleted)
+
+	hardware = suite.getHardware("")
+	suite.Contains(hardware, "x6100c0s1b0n0")
+	suite.NotContains(hardware, "x6100c0s2b0n0")
+	suite.EqualValues(6100, suite.getNID(hardware["x6100c0s0b0n0"]))
+	response = suite.do("GET", "/networks/T6100", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/hardware/x6100c0s0b0n0/history?limit=1", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var history sls_common.HardwareHistory
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &history))
+	suite.Require().Len(history.Revisions, 1)
+	suite.Equal(result.Version, history.Revisions[0].Version)
+	suite.Equal("restore:"+unquoted, history.Revisions[0].UpdatedEntity)
+
+	// The NIDs came back with the nodes, 6101 belongs to x6100c0s1b0n0 again
+	response = suite.do("GET", "/nids", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.Contains(response.Body.String(), `"x6100c0s1b0n0"`)
+	suite.NotContains(response.Body.String(), `"x6100c0s2b0n0"`)
+
+	for body, status := range map[string]int{
+		`{}`:                     http.StatusBadRequest,
+		`{"Version":"abc"}`:      http.StatusBadRequest,
+		`{"AsOf":"yesterday"}`:   http.StatusBadRequest,
+		`{"Version":1073741824}`: http.StatusNotFound,
+	} {
+		response = suite.do("POST", "/restore", body)
+		suite.Equal(status, response.Code, "POST /restore %s: %s", body, response.Body.String())
+	}
+}
+
+func TestRestoreTestSuite(t *testing.T) {
+	suite.Run(t, new(RestoreTestSuite))
+}
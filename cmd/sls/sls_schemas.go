@@ -0,0 +1,85 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/gorilla/mux"
+)
+
+//  /schemas GET API
+
+func doSchemasGet(w http.ResponseWriter, r *http.Request) {
+	ba, err := json.Marshal(datastore.Schemas())
+	if err != nil {
+		log.Println("ERROR: JSON marshal of schemas failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
+
+//  /schemas/{type} GET API
+
+func doSchemaGet(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["type"]
+
+	s, err := datastore.GetSchema(name)
+	if errors.Is(err, datastore.UnknownSchema) {
+		log.Printf("ERROR: No schema for '%s'\n", name)
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No schema for "+name+", it is neither a hardware type nor part of a network",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	ba, err := json.Marshal(s)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of schema failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
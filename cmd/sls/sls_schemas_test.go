@@ -0,0 +1,111 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Cray-HPE/hms-sls/internal/schema"
+)
+
+func TestDoSchemasGet(t *testing.T) {
+	schemaRouter := newRouter(generateRoutes())
+
+	req, rerr := http.NewRequest("GET", "http://localhost:8080"+API_SCHEMAS, nil)
+	if rerr != nil {
+		t.Fatal("ERROR setting up /schemas request:", rerr)
+	}
+	rr := httptest.NewRecorder()
+	schemaRouter.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ERROR in /schemas GET request, bad status: %d\n", rr.Code)
+	}
+
+	var schemas map[string]schema.Schema
+	jerr := json.Unmarshal(rr.Body.Bytes(), &schemas)
+	if jerr != nil {
+		t.Fatal("ERROR unmarshaling /schemas GET data:", jerr)
+	}
+	for _, name := range []string{"comptype_node", "comptype_mgmt_switch_connector", "comptype_chassis",
+		"Network", "NetworkExtraProperties", "IPV4Subnet", "IPReservation"} {
+		if schemas[name].Schema != schema.Draft {
+			t.Errorf("ERROR, /schemas has no schema for %s", name)
+		}
+	}
+	for _, name := range []string{"comptype_all", "comptype_partition", "INVALID"} {
+		if _, ok := schemas[name]; ok {
+			t.Errorf("ERROR, /schemas has a schema for %s, which can't be stored", name)
+		}
+	}
+}
+
+func TestDoSchemaGet(t *testing.T) {
+	schemaRouter := newRouter(generateRoutes())
+
+	tests := []struct {
+		name           string
+		expectedStatus int
+	}{
+		{"comptype_node", http.StatusOK},
+		{"comptype_cabinet", http.StatusOK},
+		{"Network", http.StatusOK},
+		{"IPReservation", http.StatusOK},
+		{"comptype_all", http.StatusNotFound},
+		{"comptype_bogus", http.StatusNotFound},
+		{"GenericHardware", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, rerr := http.NewRequest("GET", "http://localhost:8080"+API_SCHEMAS+"/"+test.name, nil)
+		if rerr != nil {
+			t.Fatal("ERROR setting up /schemas request:", rerr)
+		}
+		rr := httptest.NewRecorder()
+		schemaRouter.ServeHTTP(rr, req)
+		if rr.Code != test.expectedStatus {
+			t.Errorf("ERROR in /schemas/%s GET request, expected %d status, got: %d\n",
+				test.name, test.expectedStatus, rr.Code)
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+
+		if rr.Header().Get("Content-Type") != "application/schema+json" {
+			t.Errorf("ERROR in /schemas/%s GET request, bad Content-Type: %s\n",
+				test.name, rr.Header().Get("Content-Type"))
+		}
+
+		var s schema.Schema
+		jerr := json.Unmarshal(rr.Body.Bytes(), &s)
+		if jerr != nil {
+			t.Error("ERROR unmarshaling /schemas GET data:", jerr)
+		}
+		if s.Title != test.name {
+			t.Errorf("ERROR in /schemas/%s GET request, got the schema for %s\n", test.name, s.Title)
+		}
+	}
+}
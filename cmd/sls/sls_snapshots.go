@@ -0,0 +1,212 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/gorilla/mux"
+)
+
+// Send a snapshot, a list of them or the state in one as JSON.
+
+func sendSnapshotJSON(w http.ResponseWriter, r *http.Request, code int, value interface{}) {
+	ba, err := json.Marshal(value)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of snapshots failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(ba)
+}
+
+//  /snapshots GET API
+
+func doSnapshotsGet(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := datastore.GetSnapshots()
+	if err != nil {
+		log.Println("ERROR: Unable to get snapshots:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get snapshots",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	// Marshal an empty array rather than null.
+	if snapshots == nil {
+		snapshots = []sls_common.Snapshot{}
+	}
+
+	sendSnapshotJSON(w, r, http.StatusOK, snapshots)
+}
+
+//  /snapshots POST API
+
+func doSnapshotPost(w http.ResponseWriter, r *http.Request) {
+	var request sls_common.Snapshot
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR: Unable to read request body:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to read request body",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		log.Println("ERROR: Unable to unmarshal snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Unable to unmarshal snapshot",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	snapshot, err := datastore.CreateSnapshot(request.Name, request.Comment)
+	if errors.Is(err, datastore.InvalidSnapshot) {
+		log.Println("ERROR: Invalid snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err == database.AlreadySuch {
+		pdet := base.NewProblemDetails("about: blank",
+			"Conflict",
+			"Snapshot already exists",
+			r.URL.Path, http.StatusConflict)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to create snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to create snapshot",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Created snapshot %s of version %d", snapshot.Name, snapshot.Version)
+	sendSnapshotJSON(w, r, http.StatusCreated, snapshot)
+}
+
+//  /snapshots/{name} GET API
+
+func doSnapshotGet(w http.ResponseWriter, r *http.Request) {
+	state, err := datastore.GetSnapshot(mux.Vars(r)["name"])
+	if err != nil {
+		log.Println("ERROR: Unable to get snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get snapshot",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if state == nil {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such snapshot",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	sendSnapshotJSON(w, r, http.StatusOK, state)
+}
+
+//  /snapshots/{name} DELETE API
+
+func doSnapshotDelete(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	err := datastore.DeleteSnapshot(name)
+	if err == database.NoSuch {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such snapshot",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to delete snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to delete snapshot",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Deleted snapshot %s", name)
+	w.WriteHeader(http.StatusOK)
+}
+
+//  /snapshots/{name}/restore POST API
+
+func doSnapshotRestorePost(w http.ResponseWriter, r *http.Request) {
+	result, err := datastore.RestoreSnapshot(mux.Vars(r)["name"])
+	if err == database.NoSuch {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such snapshot",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to restore snapshot:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to restore snapshot",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Restored snapshot %s of version %d as version %d", result.Snapshot.Name,
+		result.Snapshot.Version, result.Version)
+	sendSnapshotJSON(w, r, http.StatusOK, result)
+}
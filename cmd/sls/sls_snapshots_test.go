@@ -0,0 +1,138 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotsTestSuite struct {
+	apiTestSuite
+}
+
+func (suite *SnapshotsTestSuite) SetupSuite() {
+	suite.apiTestSuite.SetupSuite()
+
+	// Left over from an earlier run.
+	suite.do("DELETE", "/snapshots/test-6500", "")
+}
+
+func (suite *SnapshotsTestSuite) TearDownSuite() {
+	suite.do("DELETE", "/snapshots/test-6500", "")
+	suite.do("DELETE", "/networks/T6500", "")
+	hwDBClear()
+}
+
+func (suite *SnapshotsTestSuite) TestSnapshots() {
+	payload := `[
+		{"Parent":"x6500c0s0b0","Xname":"x6500c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6500,"Role":"Compute"}},
+		{"Parent":"x6500c0s1b0","Xname":"x6500c0s1b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6501,"Role":"Compute"}}
+	]`
+	response := suite.do("POST", "/hardware/bulk", payload)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("POST", "/networks",
+		`{"Name":"T6500","FullName":"Snapshot test network","IPRanges":["10.165.0.0/24"],"Type":"ethernet"}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("POST", "/snapshots", `{"Name":"test-6500","Comment":"Before the changes"}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	var snapshot sls_common.Snapshot
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &snapshot))
+	suite.Equal("test-6500", snapshot.Name)
+	suite.Equal("Before the changes", snapshot.Comment)
+	suite.NotZero(snapshot.Version)
+	suite.NotZero(snapshot.Timestamp)
+
+	response = suite.do("POST", "/snapshots", `{"Name":"test-6500"}`)
+	suite.Equal(http.StatusConflict, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/snapshots", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var snapshots []sls_common.Snapshot
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &snapshots))
+	suite.Contains(snapshots, snapshot)
+
+	response = suite.do("GET", "/snapshots/test-6500", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var state sls_common.SLSState
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &state))
+	suite.Contains(state.Hardware, "x6500c0s0b0n0")
+	suite.Contains(state.Hardware, "x6500c0s1b0n0")
+	suite.Contains(state.Networks, "T6500")
+
+	response = suite.do("PATCH", "/hardware/x6500c0s0b0n0", `{"ExtraProperties":{"NID":6510}}`)
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/hardware/x6500c0s1b0n0", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("DELETE", "/networks/T6500", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("POST", "/snapshots/test-6500/restore", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var result sls_common.SnapshotRestoreResult
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &result))
+	suite.Equal(snapshot, result.Snapshot)
+	suite.Greater(result.Version, snapshot.Version)
+
+	response = suite.do("GET", "/hardware/x6500c0s0b0n0", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var node sls_common.GenericHardware
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &node))
+	suite.Equal(map[string]interface{}{"NID": 6500.0, "Role": "Compute"}, node.ExtraPropertiesRaw)
+
+	response = suite.do("GET", "/hardware/x6500c0s1b0n0", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("GET", "/networks/T6500", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("DELETE", "/snapshots/test-6500", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+
+	for _, request := range []struct {
+		method string
+		url    string
+		body   string
+		status int
+	}{
+		{"POST", "/snapshots", `{"Name":""}`, http.StatusBadRequest},
+		{"POST", "/snapshots", `{"Name":"-6500"}`, http.StatusBadRequest},
+		{"POST", "/snapshots", `{"Name":"test 6500"}`, http.StatusBadRequest},
+		{"POST", "/snapshots", `{"Name":"loadstate-6500"}`, http.StatusBadRequest},
+		{"POST", "/snapshots", `{"Name":`, http.StatusBadRequest},
+		{"GET", "/snapshots/test-6500", "", http.StatusNotFound},
+		{"DELETE", "/snapshots/test-6500", "", http.StatusNotFound},
+		{"POST", "/snapshots/test-6500/restore", "", http.StatusNotFound},
+	} {
+		response = suite.do(request.method, request.url, request.body)
+		suite.Equal(request.status, response.Code, "%s %s: %s", request.method, request.url, response.Body.String())
+	}
+}
+
+func TestSnapshotsSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotsTestSuite))
+}
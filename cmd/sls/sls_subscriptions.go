@@ -0,0 +1,241 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/gorilla/mux"
+)
+
+// Send a subscription or a list of them or of dead letters as JSON.
+
+func sendSubscriptionJSON(w http.ResponseWriter, r *http.Request, code int, value interface{}) {
+	ba, err := json.Marshal(value)
+	if err != nil {
+		log.Println("ERROR: JSON marshal of subscriptions failed:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"JSON marshal error",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(ba)
+}
+
+// Get the ID of the subscription a request is for.  ok is false if it is
+// invalid, a problem was sent back then.
+
+func getSubscriptionID(w http.ResponseWriter, r *http.Request) (id int64, ok bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		log.Println("ERROR: Invalid subscription ID:", mux.Vars(r)["id"])
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Subscription ID must be a number",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	return id, true
+}
+
+//  /subscriptions GET API
+
+func doSubscriptionsGet(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := datastore.GetSubscriptions()
+	if err != nil {
+		log.Println("ERROR: Unable to get subscriptions:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get subscriptions",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	// Marshal an empty array rather than null.
+	if subscriptions == nil {
+		subscriptions = []sls_common.Subscription{}
+	}
+
+	sendSubscriptionJSON(w, r, http.StatusOK, subscriptions)
+}
+
+//  /subscriptions POST API
+
+func doSubscriptionPost(w http.ResponseWriter, r *http.Request) {
+	var subscription sls_common.Subscription
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("ERROR: Unable to read request body:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to read request body",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal(body, &subscription)
+	if err != nil {
+		log.Println("ERROR: Unable to unmarshal subscription:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			"Unable to unmarshal subscription",
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	subscription, err = datastore.CreateSubscription(subscription)
+	if errors.Is(err, datastore.InvalidSubscription) {
+		log.Println("ERROR: Invalid subscription:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Bad Request",
+			err.Error(),
+			r.URL.Path, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to create subscription:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to create subscription",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Created subscription %d for %s", subscription.ID, subscription.URL)
+
+	// The only time the secret is ever sent back.
+	sendSubscriptionJSON(w, r, http.StatusCreated, subscription)
+}
+
+//  /subscriptions/{id} GET API
+
+func doSubscriptionGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := getSubscriptionID(w, r)
+	if !ok {
+		return
+	}
+
+	subscription, err := datastore.GetSubscription(id)
+	if err != nil {
+		log.Println("ERROR: Unable to get subscription:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get subscription",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if subscription == nil {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such subscription",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	sendSubscriptionJSON(w, r, http.StatusOK, subscription)
+}
+
+//  /subscriptions/{id} DELETE API
+
+func doSubscriptionDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := getSubscriptionID(w, r)
+	if !ok {
+		return
+	}
+
+	err := datastore.DeleteSubscription(id)
+	if err == database.NoSuch {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such subscription",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to delete subscription:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to delete subscription",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	log.Printf("INFO: Deleted subscription %d", id)
+	w.WriteHeader(http.StatusOK)
+}
+
+//  /subscriptions/{id}/deadletters GET API
+
+func doSubscriptionDeadLettersGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := getSubscriptionID(w, r)
+	if !ok {
+		return
+	}
+
+	deadLetters, err := datastore.GetDeadLetters(id)
+	if err == database.NoSuch {
+		pdet := base.NewProblemDetails("about: blank",
+			"Not Found",
+			"No such subscription",
+			r.URL.Path, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	} else if err != nil {
+		log.Println("ERROR: Unable to get dead letters:", err)
+		pdet := base.NewProblemDetails("about: blank",
+			"Internal Server Error",
+			"Unable to get dead letters",
+			r.URL.Path, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	if deadLetters == nil {
+		deadLetters = []sls_common.DeadLetter{}
+	}
+
+	sendSubscriptionJSON(w, r, http.StatusOK, deadLetters)
+}
@@ -0,0 +1,186 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-sls/internal/datastore"
+	"github.com/Cray-HPE/hms-sls/internal/subscriptions"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriptionsTestSuite struct {
+	apiTestSuite
+}
+
+func (suite *SubscriptionsTestSuite) SetupSuite() {
+	suite.apiTestSuite.SetupSuite()
+
+	// The subscribers in these tests are httptest servers.
+	suite.Require().NoError(datastore.SetSubscriptionNetworks("127.0.0.0/8,::1/128"))
+}
+
+func (suite *SubscriptionsTestSuite) TearDownSuite() {
+	hwDBClear()
+	suite.NoError(datastore.SetSubscriptionNetworks(""))
+}
+
+func (suite *SubscriptionsTestSuite) create(body string) sls_common.Subscription {
+	response := suite.do("POST", "/subscriptions", body)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+
+	var subscription sls_common.Subscription
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &subscription))
+	return subscription
+}
+
+func (suite *SubscriptionsTestSuite) TestSubscriptions() {
+	subscription := suite.create(`{"URL":"http://localhost/sls","Filter":{"XnamePrefixes":["x6400"]}}`)
+	suite.NotZero(subscription.ID)
+	suite.NotEmpty(subscription.Secret)
+	suite.Equal([]string{"x6400"}, subscription.Filter.XnamePrefixes)
+	id := strconv.FormatInt(subscription.ID, 10)
+
+	withSecret := suite.create(`{"URL":"https://localhost/sls","Secret":"abc","Documents":true}`)
+	suite.Equal("abc", withSecret.Secret)
+	suite.True(withSecret.Documents)
+	defer suite.do("DELETE", "/subscriptions/"+strconv.FormatInt(withSecret.ID, 10), "")
+
+	// The secret isn't sent again
+	response := suite.do("GET", "/subscriptions/"+id, "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var got sls_common.Subscription
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &got))
+	subscription.Secret = ""
+	suite.Equal(subscription, got)
+
+	response = suite.do("GET", "/subscriptions", "")
+	suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	var all []sls_common.Subscription
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &all))
+	suite.Contains(all, subscription)
+	suite.NotContains(response.Body.String(), `"abc"`)
+
+	response = suite.do("GET", "/subscriptions/"+id+"/deadletters", "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	suite.JSONEq(`[]`, response.Body.String())
+
+	response = suite.do("DELETE", "/subscriptions/"+id, "")
+	suite.Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+	for _, url := range []string{"/subscriptions/" + id, "/subscriptions/" + id + "/deadletters"} {
+		response = suite.do("GET", url, "")
+		suite.Equal(http.StatusNotFound, response.Code, "GET %s: %s", url, response.Body.String())
+	}
+	response = suite.do("DELETE", "/subscriptions/"+id, "")
+	suite.Equal(http.StatusNotFound, response.Code, "Response: %s", response.Body.String())
+
+	response = suite.do("GET", "/subscriptions/abc", "")
+	suite.Equal(http.StatusBadRequest, response.Code, "Response: %s", response.Body.String())
+
+	for _, body := range []string{
+		`{"URL":`,
+		`{}`,
+		`{"URL":"localhost/sls"}`,
+		`{"URL":"ftp://localhost/sls"}`,
+		`{"URL":"http://169.254.169.254/latest/meta-data"}`,
+		`{"URL":"http://localhost/sls","Filter":{"HardwareTypes":["comptype_nonsense"]}}`,
+		`{"URL":"http://localhost/sls","Filter":{"XnamePrefixes":[""]}}`,
+		`{"URL":"http://localhost/sls","Filter":{"Operations":["upsert"]}}`,
+	} {
+		response = suite.do("POST", "/subscriptions", body)
+		suite.Equal(http.StatusBadRequest, response.Code, "POST %s: %s", body, response.Body.String())
+	}
+}
+
+func (suite *SubscriptionsTestSuite) TestNotifications() {
+	notifications := make(chan sls_common.Notification, 10)
+	secret := "x6400 secret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		suite.NoError(err)
+		suite.Equal(subscriptions.Sign(secret, body), r.Header.Get(subscriptions.SignatureHeader))
+
+		var notification sls_common.Notification
+		suite.NoError(json.Unmarshal(body, &notification))
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		notifications <- notification
+	}))
+	defer server.Close()
+
+	subscription := suite.create(`{"URL":"` + server.URL + `/sls","Secret":"` + secret + `",` +
+		`"Filter":{"XnamePrefixes":["x6400"],"Operations":["insert"]}}`)
+	defer suite.do("DELETE", "/subscriptions/"+strconv.FormatInt(subscription.ID, 10), "")
+	gone := suite.create(`{"URL":"` + server.URL + `/gone","Secret":"` + secret + `",` +
+		`"Filter":{"XnamePrefixes":["x6400"]}}`)
+	defer suite.do("DELETE", "/subscriptions/"+strconv.FormatInt(gone.ID, 10), "")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go datastore.RunSubscriptions(stop)
+
+	response := suite.do("POST", "/hardware",
+		`{"Parent":"x6401c0s0b0","Xname":"x6401c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6401,"Role":"Compute"}}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+	response = suite.do("POST", "/hardware",
+		`{"Parent":"x6400c0s0b0","Xname":"x6400c0s0b0n0","Type":"comptype_node","TypeString":"Node","Class":"River","ExtraProperties":{"NID":6400,"Role":"Compute"}}`)
+	suite.Require().Equal(http.StatusCreated, response.Code, "Response: %s", response.Body.String())
+
+	// Only the insert of x6400c0s0b0n0 passes the filter
+	select {
+	case notification := <-notifications:
+		suite.Equal(subscription.ID, notification.SubscriptionID)
+		suite.Require().Len(notification.Events, 1)
+		suite.Equal("x6400c0s0b0n0", notification.Events[0].Name)
+		suite.Equal("insert", notification.Events[0].Operation)
+		suite.Equal(notification.Version, notification.Events[0].Version)
+	case <-time.After(30 * time.Second):
+		suite.FailNow("No notification was sent")
+	}
+
+	// What couldn't be delivered is kept
+	var deadLetters []sls_common.DeadLetter
+	for tries := 0; tries < 30 && len(deadLetters) == 0; tries++ {
+		time.Sleep(time.Second)
+		response = suite.do("GET", "/subscriptions/"+strconv.FormatInt(gone.ID, 10)+"/deadletters", "")
+		suite.Require().Equal(http.StatusOK, response.Code, "Response: %s", response.Body.String())
+		suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &deadLetters))
+	}
+	suite.Require().Len(deadLetters, 1)
+	suite.Contains(deadLetters[0].Error, "410")
+	suite.Equal("x6400c0s0b0n0", deadLetters[0].Notification.Events[0].Name)
+}
+
+func TestSubscriptionsSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionsTestSuite))
+}
@@ -23,7 +23,9 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"reflect"
 	"time"
 
 	compcredentials "github.com/Cray-HPE/hms-compcredentials"
@@ -46,3 +48,42 @@ func setupVault() {
 		}
 	}
 }
+
+// Store all of credentials in Vault, or none of them.  What is there now
+// is read first, and if storing any of them fails that is put back for the
+// ones already stored, deleting them if there was nothing.
+
+func storeCompCreds(credentials []compcredentials.CompCredentials) error {
+	prior := make([]compcredentials.CompCredentials, len(credentials))
+	for i, credential := range credentials {
+		var err error
+		prior[i], err = compCredStore.GetCompCred(credential.Xname)
+		if err != nil {
+			return fmt.Errorf("unable to get credentials for %s: %s", credential.Xname, err)
+		}
+	}
+
+	for i, credential := range credentials {
+		err := compCredStore.StoreCompCred(credential)
+		if err == nil {
+			continue
+		}
+
+		storeErr := fmt.Errorf("unable to store credentials for %s: %s", credential.Xname, err)
+		for j := i; j >= 0; j-- {
+			var restoreErr error
+			if reflect.DeepEqual(prior[j], compcredentials.CompCredentials{}) {
+				restoreErr = compCredStore.SS.Delete(compCredStore.CCPath + "/" + credentials[j].Xname)
+			} else {
+				restoreErr = compCredStore.StoreCompCred(prior[j])
+			}
+			if restoreErr != nil {
+				log.Printf("ERROR: Unable to restore credentials for %s: %s\n", credentials[j].Xname, restoreErr)
+			}
+		}
+
+		return storeErr
+	}
+
+	return nil
+}
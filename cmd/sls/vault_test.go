@@ -0,0 +1,90 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	compcredentials "github.com/Cray-HPE/hms-compcredentials"
+	securestorage "github.com/Cray-HPE/hms-securestorage"
+)
+
+func TestStoreCompCredsRestoresOnFailure(t *testing.T) {
+	savedStore := compCredStore
+	defer func() { compCredStore = savedStore }()
+
+	ss, adapter := securestorage.NewMockAdapter()
+	compCredStore = *compcredentials.NewCompCredStore("secret/hms-creds", ss)
+
+	stored := compcredentials.CompCredentials{Xname: "x0c0s0b0", Username: "root", Password: "old"}
+	adapter.LookupData = []securestorage.MockLookup{
+		{Output: securestorage.OutputLookup{Output: stored}},
+		{Output: securestorage.OutputLookup{Output: compcredentials.CompCredentials{}}},
+	}
+	adapter.StoreData = []securestorage.MockStore{
+		{},
+		{Output: securestorage.OutputStore{Err: errors.New("vault unavailable")}},
+		{},
+	}
+	adapter.DeleteData = []securestorage.MockDelete{{}}
+
+	err := storeCompCreds([]compcredentials.CompCredentials{
+		{Xname: "x0c0s0b0", Username: "root", Password: "new"},
+		{Xname: "x0c0s1b0", Username: "root", Password: "new"},
+	})
+	if err == nil {
+		t.Fatal("Expected an error when a store fails")
+	}
+
+	if adapter.StoreNum != 3 {
+		t.Fatalf("Expected 3 stores, got %d", adapter.StoreNum)
+	}
+	if adapter.DeleteNum != 1 || adapter.DeleteData[0].Input.Key != "secret/hms-creds/x0c0s1b0" {
+		t.Errorf("Expected credentials that weren't there before to be deleted, got %+v", adapter.DeleteData)
+	}
+	restored, ok := adapter.StoreData[2].Input.Value.(compcredentials.CompCredentials)
+	if !ok || restored != stored {
+		t.Errorf("Expected the prior credentials to be restored, got %+v", adapter.StoreData[2].Input.Value)
+	}
+}
+
+func TestStoreCompCredsLookupFailure(t *testing.T) {
+	savedStore := compCredStore
+	defer func() { compCredStore = savedStore }()
+
+	ss, adapter := securestorage.NewMockAdapter()
+	compCredStore = *compcredentials.NewCompCredStore("secret/hms-creds", ss)
+
+	adapter.LookupData = []securestorage.MockLookup{
+		{Output: securestorage.OutputLookup{Err: errors.New("vault unavailable")}},
+	}
+
+	err := storeCompCreds([]compcredentials.CompCredentials{{Xname: "x0c0s0b0"}})
+	if err == nil {
+		t.Fatal("Expected an error when a lookup fails")
+	}
+	if adapter.StoreNum != 0 {
+		t.Errorf("Expected nothing to be stored, got %d stores", adapter.StoreNum)
+	}
+}
@@ -0,0 +1,175 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// versionsChannel is where migration 9 announces every version once it is committed.
+const versionsChannel = "sls_versions"
+
+// versionsPingInterval is how often the connection listening for versions is checked while it is quiet.
+const versionsPingInterval = 90 * time.Second
+
+// GetEvents calls add with every change made in the versions after afterVersion up to throughVersion, in the order
+// they were made. Each event includes what the hardware or network was changed to only if documents is set.
+func GetEvents(afterVersion int64, throughVersion int64, documents bool,
+	add func(event sls_common.Event) error) (err error) {
+	q := "SELECT \n" +
+		"    revision, \n" +
+		"    revisions.version, \n" +
+		"    timestamp, \n" +
+		"    updated_entity, \n" +
+		"    operation, \n" +
+		"    entity_type, \n" +
+		"    entity_name, \n" +
+		"    CASE WHEN $3 THEN after END \n" +
+		"FROM \n" +
+		"    revisions \n" +
+		"INNER JOIN \n" +
+		"    version_history \n" +
+		"ON revisions.version = version_history.version \n" +
+		"WHERE \n" +
+		"    revisions.version > $1 \n" +
+		"    AND revisions.version <= $2 \n" +
+		"ORDER BY \n" +
+		"    revisions.version, \n" +
+		"    revision "
+
+	rows, queryErr := DB.Query(q, afterVersion, throughVersion, documents)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query events: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event sls_common.Event
+		var timestamp time.Time
+		var updatedEntity sql.NullString
+		var after []byte
+
+		scanErr := rows.Scan(&event.Revision,
+			&event.Version,
+			&timestamp,
+			&updatedEntity,
+			&event.Operation,
+			&event.EntityType,
+			&event.Name,
+			&after)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan event row: %s", scanErr)
+			return
+		}
+
+		event.Timestamp = timestamp.Unix()
+		event.TimestampTime = timestamp.String()
+		event.UpdatedEntity = updatedEntity.String
+
+		// A nil pointer in Document would still be marshalled, only set it when there is something.
+		if after != nil && event.EntityType == revisionEntityHardware {
+			event.Document, err = revisionGenericHardware(after)
+		} else if after != nil {
+			event.Document, err = revisionNetwork(after)
+		}
+		if err != nil {
+			return
+		}
+
+		err = add(event)
+		if err != nil {
+			return
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read events: %s", err)
+	}
+
+	return
+}
+
+/*
+ListenForVersions returns a channel that receives every version committed
+from now on.  It receives 0 whenever the connection listening for versions
+was lost, as some may have been missed while it was, and is closed once stop
+is.
+*/
+func ListenForVersions(stop <-chan struct{}) (<-chan int64, error) {
+	listener := pq.NewListener(getConnectionString(), 10*time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("WARNING: Version listener: %s", err)
+			}
+		})
+
+	listenErr := listener.Listen(versionsChannel)
+	if listenErr != nil {
+		_ = listener.Close()
+		return nil, errors.Errorf("unable to listen for versions: %s", listenErr)
+	}
+
+	versions := make(chan int64)
+	go func() {
+		defer close(versions)
+		defer listener.Close()
+
+		for {
+			var version int64
+
+			select {
+			case <-stop:
+				return
+			case notification := <-listener.Notify:
+				if notification != nil {
+					var parseErr error
+					version, parseErr = strconv.ParseInt(notification.Extra, 10, 64)
+					if parseErr != nil {
+						log.Printf("WARNING: Ignoring version notification %q: %s", notification.Extra, parseErr)
+						continue
+					}
+				}
+			case <-time.After(versionsPingInterval):
+				go listener.Ping()
+				continue
+			}
+
+			select {
+			case versions <- version:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return versions, nil
+}
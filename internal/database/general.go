@@ -42,6 +42,43 @@ var DBInitialized bool
 
 var NoSuch = errors.New("nothing found by that name")
 var AlreadySuch = errors.New("entity already exists by that name")
+var PreconditionFailed = errors.New("entity does not match the given precondition")
+var AliasInUse = errors.New("alias is already used by other hardware")
+var NIDInUse = errors.New("NID is already used by another node")
+
+// Precondition restricts a write to a row whose last_updated_version is one of Versions. Any only requires the row
+// to exist. The zero value places no restriction on the write at all.
+type Precondition struct {
+	IfMatch  bool
+	Any      bool
+	Versions []int64
+}
+
+// Check returns PreconditionFailed if a row that exists (or not) at the given version does not satisfy p.
+func (p Precondition) Check(exists bool, version int64) error {
+	if !p.IfMatch {
+		return nil
+	}
+	if !exists {
+		return PreconditionFailed
+	}
+	if p.Any {
+		return nil
+	}
+	for _, v := range p.Versions {
+		if v == version {
+			return nil
+		}
+	}
+
+	return PreconditionFailed
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx so reads can happen inside or outside of a transaction.
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
 
 func NewDatabase() (err error) {
 	mutex.Lock()
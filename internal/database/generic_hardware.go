@@ -25,17 +25,17 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"strings"
+	"sort"
 	"time"
 
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 	"github.com/lib/pq"
 
 	"github.com/pkg/errors"
 )
 
-func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
+func insertGenericHardware(trans *sql.Tx, hardware sls_common.GenericHardware, version int64) (err error) {
 	q := "INSERT INTO \n" +
 		"    components (xname, \n" +
 		"                parent, \n" +
@@ -57,34 +57,17 @@ func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
 		return err
 	}
 
-	trans, beginErr := DB.Begin()
-	if beginErr != nil {
-		err = errors.Errorf("unable to begin transaction: %s", beginErr)
-		return err
-	}
-
-	version, err := IncrementVersion(trans, hardware.Xname)
-	if err != nil {
-		err = errors.Errorf("insert to version_history failed: %s", err)
-		_ = trans.Rollback()
-		return err
-	}
-
 	result, transErr := trans.Exec(q, hardware.Xname, hardware.Parent, hardware.Type, hardware.Class, string(jsonBytes), version)
 	if transErr != nil {
 		switch transErr.(type) {
 		case *pq.Error:
 			if transErr.(*pq.Error).Code.Name() == "unique_violation" {
 				err = AlreadySuch
-				_ = trans.Rollback()
 				return
 			}
 		}
 
-		// I bet we're getting back the wrong (or no) ID
-		println(transErr)
 		err = errors.Errorf("unable to exec transaction: %s", transErr)
-		_ = trans.Rollback()
 		return
 	}
 
@@ -92,11 +75,209 @@ func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
 	counter, rowsErr := result.RowsAffected()
 	if rowsErr != nil {
 		err = errors.Errorf("insert generic component failed: %s", rowsErr)
-		_ = trans.Rollback()
 		return
 	}
 	if counter < 1 {
 		err = NoSuch
+		return
+	}
+
+	err = setGenericHardwareUniqueProperties(trans, hardware.Xname)
+	if err != nil {
+		return
+	}
+
+	err = syncGenericHardwareLinks(trans, hardware, false, version)
+	return
+}
+
+func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return err
+	}
+
+	version, err := IncrementVersion(trans, hardware.Xname)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = insertGenericHardware(trans, hardware, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// getGenericHardwareVersion locks the row for the given xname (if there is one) and returns its last_updated_version.
+func getGenericHardwareVersion(trans *sql.Tx, xname string) (version int64, exists bool, err error) {
+	q := "SELECT \n" +
+		"    last_updated_version \n" +
+		"FROM \n" +
+		"    components \n" +
+		"WHERE \n" +
+		"    xname = $1 \n" +
+		"FOR UPDATE "
+
+	scanErr := trans.QueryRow(q, xname).Scan(&version)
+	if scanErr == sql.ErrNoRows {
+		return
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to scan generic hardware version: %s", scanErr)
+		return
+	}
+
+	exists = true
+	return
+}
+
+// SetGenericHardware inserts the given hardware, or updates it if it already exists, and returns the version it was
+// written in. The stored row is locked and checked against precondition before anything is written, all in one
+// transaction.
+func SetGenericHardware(hardware sls_common.GenericHardware, precondition Precondition) (version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	currentVersion, exists, err := getGenericHardwareVersion(trans, hardware.Xname)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = precondition.Check(exists, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, hardware.Xname)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	if exists {
+		err = updateGenericHardware(trans, hardware, version)
+	} else {
+		err = insertGenericHardware(trans, hardware, version)
+	}
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// BulkResult is what happened to one object of a bulk write. Attempted is false for objects that were never reached
+// because an atomic write had already failed.
+type BulkResult struct {
+	Attempted bool
+	Created   bool
+	Err       error
+}
+
+// SetGenericHardwareBulk inserts or updates every given object in a single transaction with a single version bump.
+// When atomic is set the first object that fails rolls back the whole transaction. Otherwise each object is written
+// under its own savepoint so a failure only rolls back that object, and everything else is still committed. The
+// results line up with hardware, version is 0 if nothing was committed.
+func SetGenericHardwareBulk(hardware []sls_common.GenericHardware, atomic bool) (results []BulkResult, version int64,
+	err error) {
+	results = make([]BulkResult, len(hardware))
+	if len(hardware) == 0 {
+		return
+	}
+
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	version, err = IncrementVersion(trans, "bulk:hardware")
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	written := 0
+	for i, thisHardware := range hardware {
+		results[i].Attempted = true
+
+		if !atomic {
+			_, err = trans.Exec("SAVEPOINT bulk_item")
+			if err != nil {
+				err = errors.Errorf("unable to create savepoint: %s", err)
+				version = 0
+				_ = trans.Rollback()
+				return
+			}
+		}
+
+		var exists bool
+		_, exists, results[i].Err = getGenericHardwareVersion(trans, thisHardware.Xname)
+		if results[i].Err == nil {
+			if exists {
+				results[i].Err = updateGenericHardware(trans, thisHardware, version)
+			} else {
+				results[i].Err = insertGenericHardware(trans, thisHardware, version)
+				results[i].Created = results[i].Err == nil
+			}
+		}
+
+		if results[i].Err == nil {
+			written++
+			if !atomic {
+				_, err = trans.Exec("RELEASE SAVEPOINT bulk_item")
+				if err != nil {
+					err = errors.Errorf("unable to release savepoint: %s", err)
+					version = 0
+					_ = trans.Rollback()
+					return
+				}
+			}
+		} else if atomic {
+			err = errors.Wrapf(results[i].Err, "unable to write %s", thisHardware.Xname)
+			version = 0
+			_ = trans.Rollback()
+			return
+		} else {
+			_, err = trans.Exec("ROLLBACK TO SAVEPOINT bulk_item")
+			if err != nil {
+				err = errors.Errorf("unable to roll back to savepoint: %s", err)
+				version = 0
+				_ = trans.Rollback()
+				return
+			}
+		}
+	}
+
+	// Don't leave an empty version behind if none of the objects made it.
+	if written == 0 {
+		version = 0
 		_ = trans.Rollback()
 		return
 	}
@@ -104,6 +285,7 @@ func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
 	commitErr := trans.Commit()
 	if commitErr != nil {
 		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		version = 0
 		return
 	}
 
@@ -111,19 +293,41 @@ func InsertGenericHardware(hardware sls_common.GenericHardware) (err error) {
 }
 
 func DeleteGenericHardware(hardware sls_common.GenericHardware) (err error) {
+	return DeleteGenericHardwareIfMatch(hardware, Precondition{})
+}
+
+// DeleteGenericHardwareIfMatch deletes the given hardware as long as it satisfies precondition.
+func DeleteGenericHardwareIfMatch(hardware sls_common.GenericHardware, precondition Precondition) (err error) {
 	q := "DELETE \n" +
 		"FROM \n" +
 		"    components \n" +
 		"WHERE \n" +
 		"    xname = $1 "
 
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
 	}
 
-	_, err = IncrementVersion(trans, hardware.Xname)
+	currentVersion, exists, err := getGenericHardwareVersion(trans, hardware.Xname)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+	if !exists {
+		err = NoSuch
+		_ = trans.Rollback()
+		return
+	}
+
+	err = precondition.Check(exists, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err := IncrementVersion(trans, hardware.Xname)
 	if err != nil {
 		err = errors.Errorf("insert to version_history failed: %s", err)
 		_ = trans.Rollback()
@@ -150,6 +354,173 @@ func DeleteGenericHardware(hardware sls_common.GenericHardware) (err error) {
 		return
 	}
 
+	err = removeGenericHardwareReferences(trans, []string{hardware.Xname}, referenceProperties, nil, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// referenceProperties are the ExtraProperties that hold the xnames of other hardware.
+var referenceProperties = []string{"PoweredBy", "NodeNics", "Peers"}
+
+// getGenericHardwareReferences returns where the ExtraProperties of any hardware refer to one of xnames.
+func getGenericHardwareReferences(db querier, xnames []string) (references []sls_common.HardwareReference, err error) {
+	q := "SELECT \n" +
+		"    components.xname, \n" +
+		"    property, \n" +
+		"    target \n" +
+		"FROM \n" +
+		"    components, \n" +
+		"    unnest($2::text[]) AS property, \n" +
+		"    jsonb_array_elements_text(CASE jsonb_typeof(extra_properties -> property) \n" +
+		"        WHEN 'array' THEN extra_properties -> property \n" +
+		"        WHEN 'string' THEN jsonb_build_array(extra_properties -> property) \n" +
+		"        ELSE '[]' END) AS target \n" +
+		"WHERE \n" +
+		"    lower(target) = ANY($1::text[]) \n" +
+		"ORDER BY \n" +
+		"    components.xname, property, target"
+
+	rows, queryErr := db.Query(q, pq.Array(xnames), pq.Array(referenceProperties))
+	if queryErr != nil {
+		err = errors.Errorf("unable to query references: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reference sls_common.HardwareReference
+		scanErr := rows.Scan(&reference.Xname, &reference.Property, &reference.Target)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan reference row: %s", scanErr)
+			return
+		}
+
+		references = append(references, reference)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		err = errors.Errorf("unable to read references: %s", rowsErr)
+	}
+
+	return
+}
+
+// deleteGenericHardwareTree deletes xname and everything below it, returning the deleted xnames with children before
+// their parents.
+func deleteGenericHardwareTree(trans *sql.Tx, xname string) (deleted []string, err error) {
+	q := treeQuery +
+		"DELETE \n" +
+		"FROM \n" +
+		"    components \n" +
+		"USING \n" +
+		"    tree \n" +
+		"WHERE \n" +
+		"    components.xname = tree.xname \n" +
+		"RETURNING \n" +
+		"    components.xname, \n" +
+		"    tree.depth"
+
+	rows, queryErr := trans.Query(q, xname, -1)
+	if queryErr != nil {
+		err = errors.Errorf("unable to delete hardware tree: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	depths := make(map[string]int)
+	for rows.Next() {
+		var thisXname string
+		var depth int
+		scanErr := rows.Scan(&thisXname, &depth)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan deleted row: %s", scanErr)
+			return
+		}
+
+		deleted = append(deleted, thisXname)
+		depths[thisXname] = depth
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		err = errors.Errorf("unable to delete hardware tree: %s", rowsErr)
+		return
+	}
+
+	sort.Slice(deleted, func(i, j int) bool {
+		if depths[deleted[i]] != depths[deleted[j]] {
+			return depths[deleted[i]] > depths[deleted[j]]
+		}
+		return deleted[i] < deleted[j]
+	})
+
+	return
+}
+
+// DeleteGenericHardwareTree deletes xname and everything below it in a single transaction as long as xname satisfies
+// precondition. It returns the deleted xnames, children before their parents, and the references the remaining
+// hardware had to them, which are removed along with them. With dryRun the transaction is rolled back, so the result is exactly what would happen.
+func DeleteGenericHardwareTree(xname string, precondition Precondition, dryRun bool) (deleted []string,
+	references []sls_common.HardwareReference, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	currentVersion, exists, err := getGenericHardwareVersion(trans, xname)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+	if !exists {
+		err = NoSuch
+		_ = trans.Rollback()
+		return
+	}
+
+	err = precondition.Check(exists, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	var version int64
+	if !dryRun {
+		version, err = IncrementVersion(trans, xname)
+		if err != nil {
+			err = errors.Errorf("insert to version_history failed: %s", err)
+			_ = trans.Rollback()
+			return
+		}
+	}
+
+	deleted, err = deleteGenericHardwareTree(trans, xname)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// Whatever still refers to the deleted hardware has those references removed.
+	references, err = getGenericHardwareReferences(trans, deleted)
+	if err != nil || dryRun {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = removeGenericHardwareReferences(trans, deleted, referenceProperties, nil, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
 	commitErr := trans.Commit()
 	if commitErr != nil {
 		err = errors.Errorf("unable to commit transaction: %s", commitErr)
@@ -161,9 +532,9 @@ func DeleteGenericHardware(hardware sls_common.GenericHardware) (err error) {
 
 func DeleteAllGenericHardware() (err error) {
 	q := "TRUNCATE " +
-		"    components "
+		"    components CASCADE "
 
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -191,7 +562,7 @@ func DeleteAllGenericHardware() (err error) {
 	return
 }
 
-func UpdateGenericHardware(hardware sls_common.GenericHardware) (err error) {
+func updateGenericHardware(trans *sql.Tx, hardware sls_common.GenericHardware, version int64) (err error) {
 	q := "UPDATE components \n" +
 		"SET \n" +
 		"    parent           = $2, \n" +
@@ -208,7 +579,34 @@ func UpdateGenericHardware(hardware sls_common.GenericHardware) (err error) {
 		return
 	}
 
-	trans, beginErr := DB.Begin()
+	result, transErr := trans.Exec(q, hardware.Xname, hardware.Parent, hardware.Type, hardware.Class, string(jsonBytes), version)
+	if transErr != nil {
+		err = errors.Errorf("unable to exec transaction: %s", transErr)
+		return
+	}
+
+	var counter int64
+	counter, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		err = errors.Errorf("update generic component failed: %s", rowsErr)
+		return
+	}
+	if counter < 1 {
+		err = NoSuch
+		return
+	}
+
+	err = setGenericHardwareUniqueProperties(trans, hardware.Xname)
+	if err != nil {
+		return
+	}
+
+	err = syncGenericHardwareLinks(trans, hardware, true, version)
+	return
+}
+
+func UpdateGenericHardware(hardware sls_common.GenericHardware) (err error) {
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -221,22 +619,72 @@ func UpdateGenericHardware(hardware sls_common.GenericHardware) (err error) {
 		return err
 	}
 
-	result, transErr := trans.Exec(q, hardware.Xname, hardware.Parent, hardware.Type, hardware.Class, string(jsonBytes), version)
-	if transErr != nil {
-		err = errors.Errorf("unable to exec transaction: %s", transErr)
+	err = updateGenericHardware(trans, hardware, version)
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
 
-	var counter int64
-	counter, rowsErr := result.RowsAffected()
-	if rowsErr != nil {
-		err = errors.Errorf("update generic component failed: %s", rowsErr)
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// PatchGenericHardware locks the given xname, hands it to patchFunc and writes back whatever patchFunc returns. The
+// read, the version bump and the write all happen in one transaction so concurrent writers can't interleave with it.
+func PatchGenericHardware(xname string, precondition Precondition,
+	patchFunc func(hardware sls_common.GenericHardware) (sls_common.GenericHardware, error)) (
+	hardware sls_common.GenericHardware, version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	current, currentVersion, err := getGenericHardwareFromXname(trans, xname, true)
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
-	if counter < 1 {
-		err = NoSuch
+
+	err = precondition.Check(true, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	patched, err := patchFunc(current)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// The xname is the key of the row, it can't be changed by a patch.
+	if patched.Xname != current.Xname {
+		err = errors.Errorf("patch can't change the xname of %s to %s", current.Xname, patched.Xname)
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, patched.Xname)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	err = updateGenericHardware(trans, patched, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	hardware, _, err = getGenericHardwareFromXname(trans, xname, false)
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
@@ -250,7 +698,7 @@ func UpdateGenericHardware(hardware sls_common.GenericHardware) (err error) {
 	return
 }
 
-func getChildrenForXname(xname string) (children []string, err error) {
+func getChildrenForXname(db querier, xname string) (children []string, err error) {
 	// Now we find all the children for this object and add them to the base object.
 	parentQ := "SELECT \n" +
 		"    xname \n" +
@@ -258,11 +706,12 @@ func getChildrenForXname(xname string) (children []string, err error) {
 		"    components \n" +
 		"WHERE \n" +
 		"    parent = $1 "
-	childrenRows, parentErr := DB.Query(parentQ, xname)
+	childrenRows, parentErr := db.Query(parentQ, xname)
 	if parentErr != nil {
 		err = errors.Errorf("unable to query children: %s", parentErr)
 		return
 	}
+	defer childrenRows.Close()
 
 	for childrenRows.Next() {
 		var thisChildXname string
@@ -280,6 +729,10 @@ func getChildrenForXname(xname string) (children []string, err error) {
 }
 
 func GetAllGenericHardware() (hardware []sls_common.GenericHardware, err error) {
+	return getAllGenericHardware(DB)
+}
+
+func getAllGenericHardware(db querier) (hardware []sls_common.GenericHardware, err error) {
 	// First, get the base object and all its associated data
 	baseQ := "SELECT \n" +
 		"    xname, \n" +
@@ -293,11 +746,12 @@ func GetAllGenericHardware() (hardware []sls_common.GenericHardware, err error)
 		"INNER JOIN \n" +
 		"    version_history \n" +
 		"ON components.last_updated_version = version_history.version"
-	baseRows, baseErr := DB.Query(baseQ)
+	baseRows, baseErr := db.Query(baseQ)
 	if baseErr != nil {
 		err = errors.Errorf("unable to query generic hardware: %s", baseErr)
 		return
 	}
+	defer baseRows.Close()
 
 	for baseRows.Next() {
 		var thisGenericHardware sls_common.GenericHardware
@@ -326,21 +780,23 @@ func GetAllGenericHardware() (hardware []sls_common.GenericHardware, err error)
 			return
 		}
 
-		var children []string
-		children, err = getChildrenForXname(thisGenericHardware.Xname)
+		hardware = append(hardware, thisGenericHardware)
+	}
+
+	// A transaction can only run one query at a time, so the children are found once all the rows are read.
+	baseRows.Close()
+	for i := range hardware {
+		hardware[i].Children, err = getChildrenForXname(db, hardware[i].Xname)
 		if err != nil {
 			return
 		}
-
-		thisGenericHardware.Children = children
-
-		hardware = append(hardware, thisGenericHardware)
 	}
 
 	return
 }
 
-func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardware, err error) {
+func getGenericHardwareFromXname(db querier, xname string, forUpdate bool) (hardware sls_common.GenericHardware,
+	version int64, err error) {
 	// First, get the base object and all its associated data
 	baseQ := "SELECT \n" +
 		"    xname, \n" +
@@ -348,7 +804,8 @@ func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardw
 		"    comp_type, \n" +
 		"    comp_class, \n" +
 		"    timestamp, \n" +
-		"    extra_properties \n" +
+		"    extra_properties, \n" +
+		"    last_updated_version \n" +
 		"FROM \n" +
 		"    components \n" +
 		"INNER JOIN \n" +
@@ -356,7 +813,10 @@ func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardw
 		"ON components.last_updated_version = version_history.version \n" +
 		"WHERE \n" +
 		"    xname = $1 "
-	baseRow := DB.QueryRow(baseQ, xname)
+	if forUpdate {
+		baseQ = baseQ + "\nFOR UPDATE OF components "
+	}
+	baseRow := db.QueryRow(baseQ, xname)
 
 	var extraPropertiesBytes []byte
 	var lastUpdated time.Time
@@ -365,7 +825,8 @@ func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardw
 		&hardware.Type,
 		&hardware.Class,
 		&lastUpdated,
-		&extraPropertiesBytes)
+		&extraPropertiesBytes,
+		&version)
 	if baseErr == sql.ErrNoRows {
 		err = NoSuch
 		return
@@ -384,7 +845,7 @@ func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardw
 		return
 	}
 
-	children, err := getChildrenForXname(hardware.Xname)
+	children, err := getChildrenForXname(db, hardware.Xname)
 	if err != nil {
 		return
 	}
@@ -394,69 +855,317 @@ func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardw
 	return
 }
 
-func GetGenericHardwareForExtraProperties(properties map[string]interface{}) (hardware []sls_common.GenericHardware,
-	err error) {
-	return SearchGenericHardware(nil, properties)
+func GetGenericHardwareFromXname(xname string) (hardware sls_common.GenericHardware, err error) {
+	hardware, _, err = getGenericHardwareFromXname(DB, xname, false)
+	return
 }
 
-func SearchGenericHardware(conditions map[string]string, properties map[string]interface{}) (
-	hardware []sls_common.GenericHardware, err error) {
-	if len(conditions) == 0 && len(properties) == 0 {
-		err = errors.Errorf("no conditions/properties with which to search")
+// GetGenericHardwareAndVersion returns the hardware for the given xname along with the version it was last updated
+// in.
+func GetGenericHardwareAndVersion(xname string) (hardware sls_common.GenericHardware, version int64, err error) {
+	return getGenericHardwareFromXname(DB, xname, false)
+}
+
+// uniqueProperty is an ExtraProperty that no two pieces of hardware may share. Its values are copied to table, where
+// column is the primary key, so the database itself keeps every value with a single xname.
+type uniqueProperty struct {
+	table  string
+	column string
+
+	// values selects the values of the property, along with their xname, for the hardware with the xname in $1 or for
+	// all hardware if $1 is empty.
+	values string
+
+	inUse    error
+	conflict string
+}
+
+var uniqueProperties = []uniqueProperty{
+	{
+		table:  "component_aliases",
+		column: "alias",
+		values: "SELECT DISTINCT lower(alias), xname \n" +
+			"FROM components, \n" +
+			"    jsonb_array_elements_text(CASE jsonb_typeof(extra_properties -> 'Aliases') \n" +
+			"        WHEN 'array' THEN extra_properties -> 'Aliases' ELSE '[]' END) AS alias \n" +
+			"WHERE ($1::text = '' OR xname = $1::text) \n",
+		inUse:    AliasInUse,
+		conflict: "%s is an alias of both %s and %s",
+	},
+	{
+		table:  "component_nids",
+		column: "nid",
+		values: "SELECT (extra_properties ->> 'NID')::bigint, xname \n" +
+			"FROM components \n" +
+			"WHERE comp_type = 'comptype_node' \n" +
+			"    AND " + hasNID + " \n" +
+			"    AND ($1::text = '' OR xname = $1::text) \n",
+		inUse:    NIDInUse,
+		conflict: "NID %s is used by both %s and %s",
+	},
+}
+
+// setGenericHardwareUnique brings the table of property in line with the hardware with the given xname, or with all
+// hardware if xname is empty. It fails with the inUse error of property if that would give a value to more than one
+// xname.
+func setGenericHardwareUnique(trans *sql.Tx, xname string, property uniqueProperty) (err error) {
+	valuesQ := "WITH vals(value, xname) AS ( \n" +
+		property.values +
+		") \n"
+
+	var conflictQ string
+	if xname == "" {
+		conflictQ = valuesQ +
+			"SELECT \n" +
+			"    value, \n" +
+			"    min(xname), \n" +
+			"    max(xname) \n" +
+			"FROM \n" +
+			"    vals \n" +
+			"GROUP BY \n" +
+			"    value \n" +
+			"HAVING \n" +
+			"    count(*) > 1 \n" +
+			"ORDER BY \n" +
+			"    value \n" +
+			"LIMIT 1"
+	} else {
+		conflictQ = valuesQ +
+			"SELECT \n" +
+			"    vals.value, \n" +
+			"    " + property.table + ".xname, \n" +
+			"    vals.xname \n" +
+			"FROM \n" +
+			"    vals \n" +
+			"INNER JOIN \n" +
+			"    " + property.table + " \n" +
+			"ON vals.value = " + property.table + "." + property.column + " \n" +
+			"WHERE \n" +
+			"    " + property.table + ".xname <> vals.xname \n" +
+			"ORDER BY \n" +
+			"    vals.value \n" +
+			"LIMIT 1"
+	}
+
+	var value, owner, other string
+	conflictErr := trans.QueryRow(conflictQ, xname).Scan(&value, &owner, &other)
+	if conflictErr == nil {
+		err = errors.Wrapf(property.inUse, property.conflict, value, owner, other)
+		return
+	} else if conflictErr != sql.ErrNoRows {
+		err = errors.Errorf("unable to check %s: %s", property.table, conflictErr)
+		return
+	}
+
+	deleteQ := "DELETE \n" +
+		"FROM \n" +
+		"    " + property.table + " \n" +
+		"WHERE \n" +
+		"    ($1::text = '' OR xname = $1::text) "
+
+	_, transErr := trans.Exec(deleteQ, xname)
+	if transErr != nil {
+		err = errors.Errorf("unable to delete from %s: %s", property.table, transErr)
+		return
+	}
+
+	insertQ := valuesQ +
+		"INSERT INTO \n" +
+		"    " + property.table + " (" + property.column + ", xname) \n" +
+		"SELECT \n" +
+		"    value, \n" +
+		"    xname \n" +
+		"FROM \n" +
+		"    vals "
+
+	_, transErr = trans.Exec(insertQ, xname)
+	if transErr != nil {
+		switch transErr.(type) {
+		case *pq.Error:
+			// Somebody else took one of the values since we checked.
+			if transErr.(*pq.Error).Code.Name() == "unique_violation" {
+				err = errors.Wrapf(property.inUse, "%s", transErr.(*pq.Error).Detail)
+				return
+			}
+		}
+
+		err = errors.Errorf("unable to insert into %s: %s", property.table, transErr)
 		return
 	}
 
+	return
+}
+
+// setGenericHardwareUniqueProperties calls setGenericHardwareUnique for every one of uniqueProperties.
+func setGenericHardwareUniqueProperties(trans *sql.Tx, xname string) (err error) {
+	for _, property := range uniqueProperties {
+		err = setGenericHardwareUnique(trans, xname, property)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// GetGenericHardwareForAlias returns the hardware that has the given alias, ignoring case.
+func GetGenericHardwareForAlias(alias string) (hardware sls_common.GenericHardware, err error) {
 	q := "SELECT \n" +
-		"    xname, \n" +
+		"    xname \n" +
+		"FROM \n" +
+		"    component_aliases \n" +
+		"WHERE \n" +
+		"    alias = lower($1) "
+
+	var xname string
+	scanErr := DB.QueryRow(q, alias).Scan(&xname)
+	if scanErr == sql.ErrNoRows {
+		err = NoSuch
+		return
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to scan alias row: %s", scanErr)
+		return
+	}
+
+	hardware, _, err = getGenericHardwareFromXname(DB, xname, false)
+	return
+}
+
+// treeQuery defines tree as the xname in $1 and everything below it, at most $2 levels down unless $2 is negative,
+// along with how far below $1 each of them is. It follows the parent column, so it only takes a single query.
+const treeQuery = "WITH RECURSIVE tree AS ( \n" +
+	"    SELECT xname, 0 AS depth, ARRAY[xname] AS path \n" +
+	"    FROM components \n" +
+	"    WHERE xname = $1 \n" +
+	"  UNION ALL \n" +
+	"    SELECT components.xname, tree.depth + 1, tree.path || components.xname \n" +
+	"    FROM components \n" +
+	"    INNER JOIN tree ON components.parent = tree.xname \n" +
+	"    WHERE ($2 < 0 OR tree.depth < $2) \n" +
+	"      AND NOT components.xname = ANY(tree.path) \n" +
+	") \n"
+
+// GetGenericHardwareTree returns the hardware for xname followed by everything below it, at most depth levels down
+// when depth isn't negative. The hardware is ordered by depth and then xname, so parents always come before their
+// children.
+func GetGenericHardwareTree(xname string, depth int) (hardware []sls_common.GenericHardware, err error) {
+	q := treeQuery +
+		"SELECT \n" +
+		"    components.xname, \n" +
 		"    parent, \n" +
 		"    comp_type, \n" +
 		"    comp_class, \n" +
 		"    timestamp, \n" +
-		"    extra_properties \n" +
+		"    extra_properties, \n" +
+		"    ARRAY(SELECT child.xname FROM components child WHERE child.parent = components.xname) \n" +
 		"FROM \n" +
-		"    components  \n" +
+		"    tree \n" +
+		"INNER JOIN \n" +
+		"    components \n" +
+		"ON tree.xname = components.xname \n" +
 		"INNER JOIN \n" +
 		"    version_history \n" +
 		"ON components.last_updated_version = version_history.version \n" +
-		"WHERE \n     "
+		"ORDER BY \n" +
+		"    tree.depth, components.xname"
 
-	// Build the conditions for the regular columns.
-	index := 0
-	for key, value := range conditions {
-		if index != 0 {
-			q = q + "  AND"
-		}
+	rows, queryErr := DB.Query(q, xname, depth)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query hardware tree: %s", queryErr)
+		return
+	}
+	defer rows.Close()
 
-		q = q + fmt.Sprintf(" %s = '%s' \n", key, value)
+	for rows.Next() {
+		var thisGenericHardware sls_common.GenericHardware
+		var lastUpdated time.Time
+		var extraPropertiesBytes []byte
+		var children pq.StringArray
 
-		index++
-	}
+		scanErr := rows.Scan(&thisGenericHardware.Xname,
+			&thisGenericHardware.Parent,
+			&thisGenericHardware.Type,
+			&thisGenericHardware.Class,
+			&lastUpdated,
+			&extraPropertiesBytes,
+			&children)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan hardware tree row: %s", scanErr)
+			return
+		}
+
+		thisGenericHardware.LastUpdated = lastUpdated.Unix()
+		thisGenericHardware.LastUpdatedTime = lastUpdated.String()
+		thisGenericHardware.TypeString = sls_common.HMSStringTypeToHMSType(thisGenericHardware.Type)
 
-	// Build the conditions for the extra properties JSON column.
-	for key, value := range properties {
-		if index != 0 {
-			q = q + "  AND"
+		unmarshalErr := json.Unmarshal(extraPropertiesBytes, &thisGenericHardware.ExtraPropertiesRaw)
+		if unmarshalErr != nil {
+			err = errors.Errorf("unable to unmarshal extended properties: %s", unmarshalErr)
+			return
 		}
 
-		// Some day I want to come back around and make this work with infinite levels of depth, but for now just
-		// investigate the type of the value interface. If it's a string then use one syntax, if it's an array use
-		// another. The rational being that nested types need different syntax to query.
-		valueString, ok := value.(string)
-		if ok {
-			q = q + fmt.Sprintf(" extra_properties ->> '%s' = '%s' \n", key, valueString)
-		} else if valueArray, ok := value.([]string); ok {
-			q = q + fmt.Sprintf(" extra_properties -> '%s' ?| array['%v'] \n", key,
-				strings.Join(valueArray, "','"))
+		if len(children) != 0 {
+			thisGenericHardware.Children = children
 		}
 
-		index++
+		hardware = append(hardware, thisGenericHardware)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		err = errors.Errorf("unable to read hardware tree: %s", rowsErr)
+		return
+	}
+
+	if len(hardware) == 0 {
+		err = NoSuch
+	}
+
+	return
+}
+
+func GetGenericHardwareForExtraProperties(properties map[string]interface{}) (hardware []sls_common.GenericHardware,
+	err error) {
+	return SearchGenericHardware(nil, properties)
+}
+
+func SearchGenericHardware(conditions map[string]string, properties map[string]interface{}) (
+	hardware []sls_common.GenericHardware, err error) {
+	filter, err := ConditionsFilter(conditions, properties)
+	if err != nil {
+		return
 	}
 
-	rows, queryErr := DB.Query(q)
+	return SearchGenericHardwareFilter(filter)
+}
+
+// SearchGenericHardwareFilter returns all the hardware matching the given filter. Fields are xname, parent, type and
+// class, anything else is a key of ExtraProperties.
+func SearchGenericHardwareFilter(filter search.Expr) (hardware []sls_common.GenericHardware, err error) {
+	where, args, err := buildWhere(filter, hardwareSearchColumns)
+	if err != nil {
+		return
+	}
+
+	q := "SELECT \n" +
+		"    xname, \n" +
+		"    parent, \n" +
+		"    comp_type, \n" +
+		"    comp_class, \n" +
+		"    timestamp, \n" +
+		"    extra_properties \n" +
+		"FROM \n" +
+		"    components  \n" +
+		"INNER JOIN \n" +
+		"    version_history \n" +
+		"ON components.last_updated_version = version_history.version \n" +
+		"WHERE \n" +
+		"    " + where
+
+	rows, queryErr := DB.Query(q, args...)
 	if queryErr != nil {
 		err = errors.Errorf("unable to query extra properties: %s", queryErr)
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		newGenericHardware := sls_common.GenericHardware{}
@@ -485,7 +1194,7 @@ func SearchGenericHardware(conditions map[string]string, properties map[string]i
 		}
 
 		var children []string
-		children, err = getChildrenForXname(newGenericHardware.Xname)
+		children, err = getChildrenForXname(DB, newGenericHardware.Xname)
 		if err != nil {
 			return
 		}
@@ -499,7 +1208,7 @@ func SearchGenericHardware(conditions map[string]string, properties map[string]i
 }
 
 func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error) {
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -512,14 +1221,31 @@ func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error
 		return err
 	}
 
+	err = replaceAllGenericHardware(trans, hardware, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// Now finally we can commit the entire transaction. Assuming this works, we're done here.
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// replaceAllGenericHardware replaces all components with hardware as part of trans, which made version.
+func replaceAllGenericHardware(trans *sql.Tx, hardware []sls_common.GenericHardware, version int64) (err error) {
 	// Start by deleting all the components currently there.
 	q := "TRUNCATE " +
-		"    components "
+		"    components CASCADE "
 
 	_, transErr := trans.Exec(q)
 	if transErr != nil {
 		err = errors.Errorf("unable to exec transaction: %s", transErr)
-		_ = trans.Rollback()
 		return
 	}
 
@@ -528,7 +1254,6 @@ func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error
 		"xname", "parent", "comp_type", "comp_class", "last_updated_version", "extra_properties"))
 	if prepareErr != nil {
 		err = errors.Errorf("unable to prepare statement: %s", prepareErr)
-		_ = trans.Rollback()
 		return
 	}
 
@@ -536,7 +1261,6 @@ func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error
 		jsonBytes, jsonErr := json.Marshal(component.ExtraPropertiesRaw)
 		if jsonErr != nil {
 			err = errors.Errorf("unable to marshal ExtendedProperties: %s", jsonErr)
-			_ = trans.Rollback()
 			return
 		}
 
@@ -544,7 +1268,6 @@ func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error
 			version, string(jsonBytes))
 		if execErr != nil {
 			err = errors.Errorf("unable to exec statement: %s", execErr)
-			_ = trans.Rollback()
 			return
 		}
 	}
@@ -552,23 +1275,19 @@ func ReplaceAllGenericHardware(hardware []sls_common.GenericHardware) (err error
 	_, statementErr := statement.Exec()
 	if statementErr != nil {
 		err = errors.Errorf("unable to exec statement: %s", statementErr)
-		_ = trans.Rollback()
 		return
 	}
 
 	statementErr = statement.Close()
 	if statementErr != nil {
 		err = errors.Errorf("unable to close statement: %s", statementErr)
-		_ = trans.Rollback()
 		return
 	}
 
-	// Now finally we can commit the entire transaction. Assuming this works, we're done here.
-	commitErr := trans.Commit()
-	if commitErr != nil {
-		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+	err = setGenericHardwareUniqueProperties(trans, "")
+	if err != nil {
 		return
 	}
 
-	return
+	return linkGenericHardware(trans, "", version)
 }
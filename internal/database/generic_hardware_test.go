@@ -24,6 +24,7 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/Cray-HPE/hms-sls/pkg/sls-common"
 	"strconv"
 	"testing"
@@ -162,6 +163,101 @@ func (suite *GenericHardwareTestSuite) TestRGetGenericHardware_HappyPath() {
 	suite.NoError(err)
 }
 
+func (suite *GenericHardwareTestSuite) TestAliases_HappyPath() {
+	genericHardware := sls_common.GenericHardware{
+		Parent: "x2c0s0b0",
+		Xname:  "x2c0s0b0n0",
+		Type:   sls_common.Node,
+		Class:  sls_common.ClassRiver,
+		ExtraPropertiesRaw: sls_common.ComptypeNode{
+			NID:     14,
+			Role:    "Management",
+			Aliases: []string{"ncn-a001"},
+		},
+	}
+
+	err := InsertGenericHardware(genericHardware)
+	suite.NoError(err)
+
+	returnedHardware, err := GetGenericHardwareForAlias("NCN-A001")
+	suite.NoError(err)
+	suite.Equal(genericHardware.Xname, returnedHardware.Xname)
+
+	// Nobody else can have the same alias
+	otherHardware := genericHardware
+	otherHardware.Xname = "x2c0s0b0n1"
+
+	err = InsertGenericHardware(otherHardware)
+	suite.True(errors.Is(err, AliasInUse), "err: %s", err)
+
+	_, err = GetGenericHardwareFromXname(otherHardware.Xname)
+	suite.Equal(NoSuch, err)
+
+	// Until it has been given up
+	genericHardware.ExtraPropertiesRaw = sls_common.ComptypeNode{NID: 14, Role: "Management"}
+	err = UpdateGenericHardware(genericHardware)
+	suite.NoError(err)
+
+	err = InsertGenericHardware(otherHardware)
+	suite.NoError(err)
+
+	returnedHardware, err = GetGenericHardwareForAlias("ncn-a001")
+	suite.NoError(err)
+	suite.Equal(otherHardware.Xname, returnedHardware.Xname)
+
+	err = DeleteGenericHardware(otherHardware)
+	suite.NoError(err)
+
+	_, err = GetGenericHardwareForAlias("ncn-a001")
+	suite.Equal(NoSuch, err)
+
+	err = DeleteGenericHardware(genericHardware)
+	suite.NoError(err)
+}
+
+func (suite *GenericHardwareTestSuite) TestNIDs_HappyPath() {
+	genericHardware := sls_common.GenericHardware{
+		Parent: "x2c0s1b0",
+		Xname:  "x2c0s1b0n0",
+		Type:   sls_common.Node,
+		Class:  sls_common.ClassRiver,
+		ExtraPropertiesRaw: sls_common.ComptypeNode{
+			NID:  92001,
+			Role: "Compute",
+		},
+	}
+
+	err := InsertGenericHardware(genericHardware)
+	suite.NoError(err)
+
+	// Nobody else can have the same NID
+	otherHardware := genericHardware
+	otherHardware.Xname = "x2c0s1b0n1"
+
+	err = InsertGenericHardware(otherHardware)
+	suite.True(errors.Is(err, NIDInUse), "err: %s", err)
+
+	// But it can be given the next free one
+	otherHardware.ExtraPropertiesRaw = sls_common.ComptypeNode{Role: "Compute"}
+	err = InsertGenericHardware(otherHardware)
+	suite.NoError(err)
+
+	assigned, version, err := AssignGenericHardwareNIDs([]string{genericHardware.Xname, otherHardware.Xname}, 92001)
+	suite.NoError(err)
+	suite.NotEqual(int64(0), version)
+	suite.Equal([]sls_common.NodeNID{{NID: 92002, Xname: otherHardware.Xname}}, assigned)
+
+	nids, err := GetGenericHardwareNIDs()
+	suite.NoError(err)
+	suite.Contains(nids, sls_common.NodeNID{NID: 92002, Xname: otherHardware.Xname})
+
+	err = DeleteGenericHardware(otherHardware)
+	suite.NoError(err)
+
+	err = DeleteGenericHardware(genericHardware)
+	suite.NoError(err)
+}
+
 func TestGenericHardwareSuite(t *testing.T) {
 	suite.Run(t, new(GenericHardwareTestSuite))
 }
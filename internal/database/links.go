@@ -0,0 +1,215 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// linkProperties are the distinct properties in sls_common.ExtraPropertiesLinks.
+var linkProperties []string
+
+// linkableQuery is a WITH clause for linkable(comp_type, property), the rows of sls_common.ExtraPropertiesLinks.
+var linkableQuery string
+
+func init() {
+	var rows []string
+	seen := make(map[string]bool)
+	for hmsType, property := range sls_common.ExtraPropertiesLinks {
+		rows = append(rows, "('"+string(hmsType)+"', '"+property+"')")
+		if !seen[property] {
+			seen[property] = true
+			linkProperties = append(linkProperties, property)
+		}
+	}
+	sort.Strings(rows)
+	sort.Strings(linkProperties)
+
+	linkableQuery = "linkable(comp_type, property) AS ( \n" +
+		"    VALUES " + strings.Join(rows, ", ") + " \n" +
+		") \n"
+}
+
+// removeGenericHardwareReferences takes the xnames in targets out of the given properties of all hardware except the
+// hardware in keep. Every object that changes is marked as updated in version.
+func removeGenericHardwareReferences(trans *sql.Tx, targets []string, properties []string, keep []string,
+	version int64) (err error) {
+	q := "UPDATE components \n" +
+		"SET \n" +
+		"    extra_properties = jsonb_set(extra_properties, ARRAY[$2::text], coalesce(( \n" +
+		"        SELECT jsonb_agg(element ORDER BY ordinal) \n" +
+		"        FROM jsonb_array_elements(extra_properties -> $2::text) WITH ORDINALITY AS elements(element, ordinal) \n" +
+		"        WHERE NOT coalesce(lower(element #>> '{}') = ANY($1::text[]), false)), '[]')), \n" +
+		"    last_updated_version = $4 \n" +
+		"WHERE \n" +
+		"    jsonb_typeof(extra_properties -> $2::text) = 'array' \n" +
+		"    AND NOT xname = ANY($3::text[]) \n" +
+		"    AND EXISTS ( \n" +
+		"        SELECT 1 \n" +
+		"        FROM jsonb_array_elements_text(extra_properties -> $2::text) AS target \n" +
+		"        WHERE lower(target) = ANY($1::text[])) "
+
+	if keep == nil {
+		keep = []string{}
+	}
+
+	for _, property := range properties {
+		_, transErr := trans.Exec(q, pq.Array(targets), property, pq.Array(keep), version)
+		if transErr != nil {
+			err = errors.Errorf("unable to remove references from %s: %s", property, transErr)
+			return
+		}
+	}
+
+	return
+}
+
+// linkGenericHardware adds every link that only goes one way to the other side, for the links from and to the
+// hardware with the given xname or for all links if xname is empty. Every object that changes is marked as updated in
+// version.
+func linkGenericHardware(trans *sql.Tx, xname string, version int64) (err error) {
+	// Only the links of the hardware and of what lists it can be missing their other side, so with an xname the links
+	// come from just those rows, which the primary key and the extra_properties index find.
+	filter := ""
+	if xname != "" {
+		filter = "    WHERE \n" +
+			"        components.xname = $1::text \n"
+		for _, property := range linkProperties {
+			filter += "        OR components.extra_properties @> jsonb_build_object('" + property +
+				"', jsonb_build_array($1::text)) \n"
+		}
+	}
+
+	q := "WITH " + linkableQuery +
+		", links(xname, target) AS ( \n" +
+		"    SELECT \n" +
+		"        components.xname, \n" +
+		"        lower(target) \n" +
+		"    FROM \n" +
+		"        components \n" +
+		"    INNER JOIN \n" +
+		"        linkable \n" +
+		"    ON components.comp_type = linkable.comp_type, \n" +
+		"        jsonb_array_elements_text(CASE jsonb_typeof(extra_properties -> linkable.property) \n" +
+		"            WHEN 'array' THEN extra_properties -> linkable.property ELSE '[]' END) AS target \n" +
+		filter +
+		"), missing(xname, peers) AS ( \n" +
+		"    SELECT \n" +
+		"        links.target, \n" +
+		"        jsonb_agg(DISTINCT links.xname) \n" +
+		"    FROM \n" +
+		"        links \n" +
+		"    WHERE \n" +
+		"        ($1::text = '' OR links.xname = $1::text OR links.target = $1::text) \n" +
+		"        AND links.target <> links.xname \n" +
+		"        AND NOT EXISTS ( \n" +
+		"            SELECT 1 \n" +
+		"            FROM links AS back \n" +
+		"            WHERE back.xname = links.target AND back.target = links.xname) \n" +
+		"    GROUP BY \n" +
+		"        links.target \n" +
+		") \n" +
+		"UPDATE components \n" +
+		"SET \n" +
+		"    extra_properties = jsonb_set( \n" +
+		"        CASE jsonb_typeof(extra_properties) WHEN 'object' THEN extra_properties ELSE '{}' END, \n" +
+		"        ARRAY[linkable.property], \n" +
+		"        CASE jsonb_typeof(extra_properties -> linkable.property) \n" +
+		"            WHEN 'array' THEN extra_properties -> linkable.property ELSE '[]' END || missing.peers), \n" +
+		"    last_updated_version = $2 \n" +
+		"FROM \n" +
+		"    missing, \n" +
+		"    linkable \n" +
+		"WHERE \n" +
+		"    components.xname = missing.xname \n" +
+		"    AND components.comp_type = linkable.comp_type "
+
+	_, transErr := trans.Exec(q, xname, version)
+	if transErr != nil {
+		err = errors.Errorf("unable to link hardware: %s", transErr)
+		return
+	}
+
+	return
+}
+
+// syncGenericHardwareLinks brings the other side of the links of hardware, which has just been written, in line with
+// it. With replace the links of hardware are all it has, so it is also taken out of the peers it no longer lists.
+// Otherwise hardware picks up the links that already point at it.
+func syncGenericHardwareLinks(trans *sql.Tx, hardware sls_common.GenericHardware, replace bool,
+	version int64) (err error) {
+	if _, ok := sls_common.ExtraPropertiesLinks[hardware.Type]; ok && replace {
+		keep := append(sls_common.LinkedXnames(hardware), hardware.Xname)
+		err = removeGenericHardwareReferences(trans, []string{hardware.Xname}, linkProperties, keep, version)
+		if err != nil {
+			return
+		}
+	}
+
+	err = linkGenericHardware(trans, hardware.Xname, version)
+	return
+}
+
+// GetMissingXnames returns the xnames, ignoring case, that no hardware has.
+func GetMissingXnames(xnames []string) (missing []string, err error) {
+	q := "SELECT \n" +
+		"    wanted.xname \n" +
+		"FROM \n" +
+		"    unnest($1::text[]) AS wanted(xname) \n" +
+		"WHERE \n" +
+		"    NOT EXISTS ( \n" +
+		"        SELECT 1 \n" +
+		"        FROM components \n" +
+		"        WHERE components.xname = lower(wanted.xname)) \n" +
+		"ORDER BY \n" +
+		"    wanted.xname"
+
+	rows, queryErr := DB.Query(q, pq.Array(xnames))
+	if queryErr != nil {
+		err = errors.Errorf("unable to query xnames: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var xname string
+		scanErr := rows.Scan(&xname)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan xname row: %s", scanErr)
+			return
+		}
+
+		missing = append(missing, xname)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		err = errors.Errorf("unable to read xnames: %s", rowsErr)
+	}
+
+	return
+}
@@ -0,0 +1,250 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/pkg/errors"
+)
+
+// GetState returns all hardware and networks and the version they are at, all read from the same snapshot of the
+// database so none of them can be from a later version than the others.
+func GetState() (version int64, hardware []sls_common.GenericHardware, networks []sls_common.Network, err error) {
+	trans, beginErr := DB.BeginTx(context.Background(),
+		&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+	defer func() { _ = trans.Rollback() }()
+
+	scanErr := trans.QueryRow("SELECT max(version) FROM version_history").Scan(&version)
+	if scanErr != nil {
+		err = errors.Errorf("unable to query current version: %s", scanErr)
+		return
+	}
+
+	hardware, err = getAllGenericHardware(trans)
+	if err != nil {
+		return
+	}
+	networks, err = getAllNetworks(trans)
+	return
+}
+
+// StateSnapshot is a snapshot ReplaceState and UpsertState take of everything as it was before they write, in the same
+// transaction. It is called Prefix followed by the version it is of, and only the newest Keep snapshots called Prefix
+// something are kept, or all of them if Keep is 0.
+type StateSnapshot struct {
+	Prefix  string
+	Comment string
+	Keep    int
+}
+
+// take takes snapshot as part of trans, which has to have been begun with beginVersion and not made a version yet.
+// Nothing is taken if snapshot is nil.
+func (snapshot *StateSnapshot) take(trans *sql.Tx) (err error) {
+	if snapshot == nil {
+		return
+	}
+
+	// Nothing else can make a version while trans holds versionLock, so this is the version insertSnapshot stores.
+	var current int64
+	scanErr := trans.QueryRow("SELECT max(version) FROM version_history").Scan(&current)
+	if scanErr != nil {
+		err = errors.Errorf("unable to query current version: %s", scanErr)
+		return
+	}
+
+	name := snapshot.Prefix + strconv.FormatInt(current, 10)
+	_, err = insertSnapshot(trans, name, snapshot.Comment)
+	if err == AlreadySuch {
+		err = errors.Wrapf(AlreadySuch, "snapshot %s", name)
+		return
+	} else if err != nil {
+		return
+	}
+
+	if snapshot.Keep > 0 {
+		err = deleteOldSnapshots(trans, snapshot.Prefix, snapshot.Keep)
+	}
+	return
+}
+
+// checkVersion returns PreconditionFailed unless ifVersion is 0 or the current version as seen by trans.
+func checkVersion(trans *sql.Tx, ifVersion int64) (err error) {
+	if ifVersion == 0 {
+		return
+	}
+
+	var current int64
+	scanErr := trans.QueryRow("SELECT max(version) FROM version_history").Scan(&current)
+	if scanErr != nil {
+		err = errors.Errorf("unable to query current version: %s", scanErr)
+		return
+	}
+	if current != ifVersion {
+		err = errors.Wrapf(PreconditionFailed, "current version is %d, not %d", current, ifVersion)
+	}
+
+	return
+}
+
+// ReplaceState replaces all hardware and networks in a single transaction with a single version bump made for reason.
+// If ifVersion isn't 0 nothing is replaced unless that is still the current version, otherwise PreconditionFailed is
+// returned. Unless snapshot is nil, what is replaced is kept as that snapshot first.
+func ReplaceState(hardware []sls_common.GenericHardware, networks []sls_common.Network, reason string,
+	ifVersion int64, snapshot *StateSnapshot) (version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	// Hold off other writers, including ones that only add a version, until this is done.
+	_, transErr := trans.Exec("LOCK TABLE components, network, version_history IN SHARE ROW EXCLUSIVE MODE")
+	if transErr != nil {
+		err = errors.Errorf("unable to lock tables: %s", transErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	err = checkVersion(trans, ifVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = snapshot.take(trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, reason)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	err = replaceAllGenericHardware(trans, hardware, version)
+	if err == nil {
+		err = replaceAllNetworks(trans, networks, version)
+	}
+	if err != nil {
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		version = 0
+		return
+	}
+
+	return
+}
+
+// UpsertState inserts or updates all of hardware and networks in a single transaction with a single version bump,
+// leaving everything else alone. The first object that fails rolls back the whole transaction. If ifVersion isn't 0
+// nothing is written unless that is still the current version, otherwise PreconditionFailed is returned. Unless
+// snapshot is nil, everything is kept as that snapshot first.
+func UpsertState(hardware []sls_common.GenericHardware, networks []sls_common.Network, ifVersion int64,
+	snapshot *StateSnapshot) (version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	err = checkVersion(trans, ifVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = snapshot.take(trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, "loadstate:upsert")
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	for _, thisHardware := range hardware {
+		_, exists, hardwareErr := getGenericHardwareVersion(trans, thisHardware.Xname)
+		if hardwareErr == nil {
+			if exists {
+				hardwareErr = updateGenericHardware(trans, thisHardware, version)
+			} else {
+				hardwareErr = insertGenericHardware(trans, thisHardware, version)
+			}
+		}
+		if hardwareErr != nil {
+			err = errors.Wrapf(hardwareErr, "unable to write %s", thisHardware.Xname)
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+	}
+
+	for _, network := range networks {
+		_, exists, networkErr := getNetworkVersion(trans, network.Name)
+		if networkErr == nil {
+			if exists {
+				networkErr = updateNetwork(trans, network, version)
+			} else {
+				networkErr = insertNetwork(trans, network, version)
+			}
+		}
+		if networkErr != nil {
+			err = errors.Wrapf(networkErr, "unable to write %s", network.Name)
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		version = 0
+		return
+	}
+
+	return
+}
@@ -25,16 +25,15 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"strings"
 	"time"
 
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
-func InsertNetwork(network sls_common.Network) (err error) {
+func insertNetwork(trans *sql.Tx, network sls_common.Network, version int64) (err error) {
 	q := "INSERT INTO \n" +
 		"    network (name, \n" +
 		"             full_name, \n" +
@@ -56,19 +55,6 @@ func InsertNetwork(network sls_common.Network) (err error) {
 		return
 	}
 
-	trans, beginErr := DB.Begin()
-	if beginErr != nil {
-		err = errors.Errorf("unable to begin transaction: %s", beginErr)
-		return
-	}
-
-	version, err := IncrementVersion(trans, network.Name)
-	if err != nil {
-		err = errors.Errorf("insert to version_history failed: %s", err)
-		_ = trans.Rollback()
-		return err
-	}
-
 	result, transErr := trans.Exec(q, network.Name, network.FullName, pq.Array(network.IPRanges), network.Type, string(jsonBytes), version)
 	if transErr != nil {
 		switch transErr.(type) {
@@ -87,11 +73,101 @@ func InsertNetwork(network sls_common.Network) (err error) {
 	counter, rowsErr := result.RowsAffected()
 	if rowsErr != nil {
 		err = errors.Errorf("insert network failed: %s", rowsErr)
-		_ = trans.Rollback()
 		return
 	}
 	if counter < 1 {
 		err = NoSuch
+		return
+	}
+
+	return
+}
+
+func InsertNetwork(network sls_common.Network) (err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	version, err := IncrementVersion(trans, network.Name)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = insertNetwork(trans, network, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// getNetworkVersion locks the row for the given network (if there is one) and returns its last_updated_version.
+func getNetworkVersion(trans *sql.Tx, name string) (version int64, exists bool, err error) {
+	q := "SELECT \n" +
+		"    last_updated_version \n" +
+		"FROM \n" +
+		"    network \n" +
+		"WHERE \n" +
+		"    name = $1 \n" +
+		"FOR UPDATE "
+
+	scanErr := trans.QueryRow(q, name).Scan(&version)
+	if scanErr == sql.ErrNoRows {
+		return
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to scan network version: %s", scanErr)
+		return
+	}
+
+	exists = true
+	return
+}
+
+// SetNetwork inserts the given network, or updates it if it already exists, and returns the version it was written
+// in. The stored row is locked and checked against precondition before anything is written, all in one transaction.
+func SetNetwork(network sls_common.Network, precondition Precondition) (version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	currentVersion, exists, err := getNetworkVersion(trans, network.Name)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = precondition.Check(exists, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, network.Name)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	if exists {
+		err = updateNetwork(trans, network, version)
+	} else {
+		err = insertNetwork(trans, network, version)
+	}
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
@@ -106,18 +182,40 @@ func InsertNetwork(network sls_common.Network) (err error) {
 }
 
 func DeleteNetwork(networkName string) (err error) {
+	return DeleteNetworkIfMatch(networkName, Precondition{})
+}
+
+// DeleteNetworkIfMatch deletes the given network as long as it satisfies precondition.
+func DeleteNetworkIfMatch(networkName string, precondition Precondition) (err error) {
 	q := "DELETE \n" +
 		"FROM \n" +
 		"    network \n" +
 		"WHERE \n" +
 		"    name = $1 "
 
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
 	}
 
+	currentVersion, exists, err := getNetworkVersion(trans, networkName)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+	if !exists {
+		err = NoSuch
+		_ = trans.Rollback()
+		return
+	}
+
+	err = precondition.Check(exists, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
 	_, err = IncrementVersion(trans, networkName)
 	if err != nil {
 		err = errors.Errorf("insert to version_history failed: %s", err)
@@ -158,7 +256,7 @@ func DeleteAllNetworks() (err error) {
 	q := "TRUNCATE " +
 		"    network "
 
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -186,7 +284,7 @@ func DeleteAllNetworks() (err error) {
 	return
 }
 
-func UpdateNetwork(network sls_common.Network) (err error) {
+func updateNetwork(trans *sql.Tx, network sls_common.Network, version int64) (err error) {
 	q := "UPDATE network \n" +
 		"SET \n" +
 		"    full_name        = $2, \n" +
@@ -203,7 +301,28 @@ func UpdateNetwork(network sls_common.Network) (err error) {
 		return
 	}
 
-	trans, beginErr := DB.Begin()
+	result, transErr := trans.Exec(q, network.Name, network.FullName, pq.Array(network.IPRanges), network.Type, string(jsonBytes), version)
+	if transErr != nil {
+		err = errors.Errorf("unable to exec transaction: %s", transErr)
+		return
+	}
+
+	var counter int64
+	counter, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		err = errors.Errorf("update network failed: %s", rowsErr)
+		return
+	}
+	if counter < 1 {
+		err = NoSuch
+		return
+	}
+
+	return
+}
+
+func UpdateNetwork(network sls_common.Network) (err error) {
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -216,21 +335,72 @@ func UpdateNetwork(network sls_common.Network) (err error) {
 		return err
 	}
 
-	result, transErr := trans.Exec(q, network.Name, network.FullName, pq.Array(network.IPRanges), network.Type, string(jsonBytes), version)
-	if transErr != nil {
-		err = errors.Errorf("unable to exec transaction: %s", transErr)
+	err = updateNetwork(trans, network, version)
+	if err != nil {
+		_ = trans.Rollback()
 		return
 	}
 
-	var counter int64
-	counter, rowsErr := result.RowsAffected()
-	if rowsErr != nil {
-		err = errors.Errorf("update network failed: %s", rowsErr)
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// PatchNetwork locks the named network, hands it to patchFunc and writes back whatever patchFunc returns. The read,
+// the version bump and the write all happen in one transaction so concurrent writers can't interleave with it.
+func PatchNetwork(name string, precondition Precondition,
+	patchFunc func(network sls_common.Network) (sls_common.Network, error)) (
+	network sls_common.Network, version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	current, currentVersion, err := getNetworkForName(trans, name, true)
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
-	if counter < 1 {
-		err = NoSuch
+
+	err = precondition.Check(true, currentVersion)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	patched, err := patchFunc(current)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// The name is the key of the row, it can't be changed by a patch.
+	if patched.Name != current.Name {
+		err = errors.Errorf("patch can't change the name of %s to %s", current.Name, patched.Name)
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, patched.Name)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	err = updateNetwork(trans, patched, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	network, _, err = getNetworkForName(trans, name, false)
+	if err != nil {
 		_ = trans.Rollback()
 		return
 	}
@@ -245,6 +415,10 @@ func UpdateNetwork(network sls_common.Network) (err error) {
 }
 
 func GetAllNetworks() (networks []sls_common.Network, err error) {
+	return getAllNetworks(DB)
+}
+
+func getAllNetworks(db querier) (networks []sls_common.Network, err error) {
 	q := "SELECT \n" +
 		"    name, \n" +
 		"    full_name, \n" +
@@ -258,11 +432,12 @@ func GetAllNetworks() (networks []sls_common.Network, err error) {
 		"    version_history \n" +
 		"ON network.last_updated_version = version_history.version \n"
 
-	rows, rowsErr := DB.Query(q)
+	rows, rowsErr := db.Query(q)
 	if rowsErr != nil {
 		err = errors.Errorf("unable to query network: %s", rowsErr)
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var thisNetwork sls_common.Network
@@ -295,14 +470,16 @@ func GetAllNetworks() (networks []sls_common.Network, err error) {
 	return
 }
 
-func GetNetworkForName(name string) (network sls_common.Network, err error) {
+func getNetworkForName(db querier, name string, forUpdate bool) (network sls_common.Network, version int64,
+	err error) {
 	q := "SELECT \n" +
 		"    name, \n" +
 		"    full_name, \n" +
 		"    ip_ranges, \n" +
 		"    type, \n" +
 		"    timestamp, \n" +
-		"    extra_properties \n" +
+		"    extra_properties, \n" +
+		"    last_updated_version \n" +
 		"FROM \n" +
 		"    network  \n" +
 		"INNER JOIN \n" +
@@ -310,8 +487,11 @@ func GetNetworkForName(name string) (network sls_common.Network, err error) {
 		"ON network.last_updated_version = version_history.version \n" +
 		"WHERE \n" +
 		"    name = $1 "
+	if forUpdate {
+		q = q + "\nFOR UPDATE OF network "
+	}
 
-	row := DB.QueryRow(q, name)
+	row := db.QueryRow(q, name)
 
 	var extraPropertiesBytes []byte
 	var lastUpdated time.Time
@@ -320,7 +500,8 @@ func GetNetworkForName(name string) (network sls_common.Network, err error) {
 		pq.Array(&network.IPRanges),
 		&network.Type,
 		&lastUpdated,
-		&extraPropertiesBytes)
+		&extraPropertiesBytes,
+		&version)
 	network.LastUpdated = lastUpdated.Unix()
 	network.LastUpdatedTime = lastUpdated.String()
 	if baseErr == sql.ErrNoRows {
@@ -338,6 +519,16 @@ func GetNetworkForName(name string) (network sls_common.Network, err error) {
 	return
 }
 
+func GetNetworkForName(name string) (network sls_common.Network, err error) {
+	network, _, err = getNetworkForName(DB, name, false)
+	return
+}
+
+// GetNetworkAndVersion returns the named network along with the version it was last updated in.
+func GetNetworkAndVersion(name string) (network sls_common.Network, version int64, err error) {
+	return getNetworkForName(DB, name, false)
+}
+
 func GetNetworksContainingIP(addr string) (networks []sls_common.Network, err error) {
 	return SearchNetworks(map[string]string{
 		"ip_ranges": addr,
@@ -345,8 +536,19 @@ func GetNetworksContainingIP(addr string) (networks []sls_common.Network, err er
 }
 
 func SearchNetworks(conditions map[string]string, properties map[string]interface{}) (networks []sls_common.Network, err error) {
-	if len(conditions) == 0 && len(properties) == 0 {
-		err = errors.Errorf("no properties with which to search")
+	filter, err := ConditionsFilter(conditions, properties)
+	if err != nil {
+		return
+	}
+
+	return SearchNetworksFilter(filter)
+}
+
+// SearchNetworksFilter returns all the networks matching the given filter, or NoSuch if there aren't any. Fields are
+// name, full_name, type and ip_address, anything else is a key of ExtraProperties.
+func SearchNetworksFilter(filter search.Expr) (networks []sls_common.Network, err error) {
+	where, args, err := buildWhere(filter, networkSearchColumns)
+	if err != nil {
 		return
 	}
 
@@ -362,52 +564,15 @@ func SearchNetworks(conditions map[string]string, properties map[string]interfac
 		"INNER JOIN \n" +
 		"    version_history \n" +
 		"ON network.last_updated_version = version_history.version \n" +
-		"WHERE \n     "
-
-	// Now build up the WHERE clause with the given conditions.
-	index := 0
-	for key, value := range conditions {
-		if index != 0 {
-			q = q + "  AND"
-		}
-
-		if key == "ip_ranges" {
-			q = q + fmt.Sprintf(" '%s' <<= ANY(ip_ranges) \n", value)
-		} else {
-			q = q + fmt.Sprintf(" %s = '%s' \n", key, value)
-		}
-
-		index++
-	}
-
-	// Build the conditions for the extra properties JSON column.
-	for key, value := range properties {
-		if index != 0 {
-			q = q + "  AND"
-		}
-
-		// Some day I want to come back around and make this work with infinite levels of depth, but for now just
-		// investigate the type of the value interface. If it's a string then use one syntax, if it's an array use
-		// another. The rational being that nested types need different syntax to query.
-		valueString, ok := value.(string)
-		if ok {
-			q = q + fmt.Sprintf(" extra_properties ->> '%s' = '%s' \n", key, valueString)
-		} else if valueArray, ok := value.([]string); ok {
-			q = q + fmt.Sprintf(" extra_properties -> '%s' ?| array['%v'] \n", key,
-				strings.Join(valueArray, "','"))
-		} else {
-			err = fmt.Errorf("Unable to query on parameter %s: %v", key, value)
-			return
-		}
-
-		index++
-	}
+		"WHERE \n" +
+		"    " + where
 
-	rows, rowsErr := DB.Query(q)
+	rows, rowsErr := DB.Query(q, args...)
 	if rowsErr != nil {
 		err = errors.Errorf("unable to query network: %s", rowsErr)
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var thisNetwork sls_common.Network
@@ -445,7 +610,7 @@ func SearchNetworks(conditions map[string]string, properties map[string]interfac
 }
 
 func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
-	trans, beginErr := DB.Begin()
+	trans, beginErr := beginVersion()
 	if beginErr != nil {
 		err = errors.Errorf("unable to begin transaction: %s", beginErr)
 		return
@@ -458,6 +623,24 @@ func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
 		return err
 	}
 
+	err = replaceAllNetworks(trans, networks, version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// Now finally we can commit the entire transaction. Assuming this works, we're done here.
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
+
+// replaceAllNetworks replaces all networks with networks as part of trans, which made version.
+func replaceAllNetworks(trans *sql.Tx, networks []sls_common.Network, version int64) (err error) {
 	// Start by deleting all the networks currently there.
 	q := "TRUNCATE " +
 		"    network "
@@ -465,7 +648,6 @@ func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
 	_, transErr := trans.Exec(q)
 	if transErr != nil {
 		err = errors.Errorf("unable to exec transaction: %s", transErr)
-		_ = trans.Rollback()
 		return
 	}
 
@@ -474,7 +656,6 @@ func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
 		"name", "full_name", "ip_ranges", "type", "last_updated_version", "extra_properties"))
 	if prepareErr != nil {
 		err = errors.Errorf("unable to prepare statement: %s", prepareErr)
-		_ = trans.Rollback()
 		return
 	}
 
@@ -489,7 +670,6 @@ func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
 			version, string(jsonBytes))
 		if execErr != nil {
 			err = errors.Errorf("unable to exec statement: %s", execErr)
-			_ = trans.Rollback()
 			return
 		}
 	}
@@ -497,22 +677,12 @@ func ReplaceAllNetworks(networks []sls_common.Network) (err error) {
 	_, statementErr := statement.Exec()
 	if statementErr != nil {
 		err = errors.Errorf("unable to exec statement: %s", statementErr)
-		_ = trans.Rollback()
 		return
 	}
 
 	statementErr = statement.Close()
 	if statementErr != nil {
 		err = errors.Errorf("unable to close statement: %s", statementErr)
-		_ = trans.Rollback()
-		return
-	}
-
-	// Now finally we can commit the entire transaction. Assuming this works, we're done here.
-	commitErr := trans.Commit()
-	if commitErr != nil {
-		err = errors.Errorf("unable to commit transaction: %s", commitErr)
-		return
 	}
 
 	return
@@ -0,0 +1,246 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/pkg/errors"
+)
+
+// hasNID is true for a component whose ExtraProperties have a NID that can be handed out, a positive whole number
+// small enough for a bigint.
+const hasNID = "coalesce(jsonb_typeof(extra_properties -> 'NID') = 'number' \n" +
+	"    AND extra_properties ->> 'NID' ~ '^[1-9][0-9]{0,17}$', false)"
+
+// GetGenericHardwareNIDs returns the NID of every node ordered by NID and xname. Nodes without a NID have NID 0.
+func GetGenericHardwareNIDs() (nids []sls_common.NodeNID, err error) {
+	q := "SELECT \n" +
+		"    xname, \n" +
+		"    CASE WHEN " + hasNID + " \n" +
+		"        THEN (extra_properties ->> 'NID')::bigint ELSE 0 END AS nid \n" +
+		"FROM \n" +
+		"    components \n" +
+		"WHERE \n" +
+		"    comp_type = 'comptype_node' \n" +
+		"ORDER BY \n" +
+		"    nid, xname"
+
+	rows, queryErr := DB.Query(q)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query NIDs: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nid sls_common.NodeNID
+		scanErr := rows.Scan(&nid.Xname, &nid.NID)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan NID row: %s", scanErr)
+			return
+		}
+
+		nids = append(nids, nid)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		err = errors.Errorf("unable to read NIDs: %s", rowsErr)
+	}
+
+	return
+}
+
+// nextFreeNID returns the lowest NID no lower than start that isn't in component_nids or reserved.
+func nextFreeNID(trans *sql.Tx, start int) (nid int, err error) {
+	q := "SELECT \n" +
+		"    min(candidate) \n" +
+		"FROM ( \n" +
+		"    SELECT $1::bigint AS candidate \n" +
+		"  UNION ALL \n" +
+		"    SELECT nid + 1 FROM component_nids WHERE nid >= $1 \n" +
+		"  UNION ALL \n" +
+		"    SELECT nid + 1 FROM nid_reservations WHERE nid >= $1 AND expires > NOW() \n" +
+		") AS candidates \n" +
+		"WHERE \n" +
+		"    NOT EXISTS (SELECT 1 FROM component_nids WHERE nid = candidate) \n" +
+		"    AND NOT EXISTS (SELECT 1 FROM nid_reservations WHERE nid = candidate AND expires > NOW())"
+
+	scanErr := trans.QueryRow(q, start).Scan(&nid)
+	if scanErr != nil {
+		err = errors.Errorf("unable to find a free NID: %s", scanErr)
+	}
+
+	return
+}
+
+// AssignGenericHardwareNIDs gives each node with one of the given xnames that doesn't have a NID yet the lowest free
+// NID no lower than start, in the order given. It all happens in one transaction with a single version, and no other
+// NIDs can be written until it is done. Nodes that are gone or have a NID by then are skipped. The version is 0 if
+// nothing was assigned.
+func AssignGenericHardwareNIDs(xnames []string, start int) (assigned []sls_common.NodeNID, version int64, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	_, lockErr := trans.Exec("LOCK TABLE component_nids IN SHARE ROW EXCLUSIVE MODE")
+	if lockErr != nil {
+		err = errors.Errorf("unable to lock NIDs: %s", lockErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	version, err = IncrementVersion(trans, "assign NIDs")
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	q := "UPDATE components \n" +
+		"SET \n" +
+		"    extra_properties = jsonb_set(CASE jsonb_typeof(extra_properties) \n" +
+		"        WHEN 'object' THEN extra_properties ELSE '{}' END, '{NID}', to_jsonb($2::bigint)), \n" +
+		"    last_updated_version = $3 \n" +
+		"WHERE \n" +
+		"    xname = $1 \n" +
+		"    AND comp_type = 'comptype_node' \n" +
+		"    AND NOT " + hasNID
+
+	nid := start
+	for _, xname := range xnames {
+		nid, err = nextFreeNID(trans, nid)
+		if err != nil {
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+
+		result, transErr := trans.Exec(q, xname, nid, version)
+		if transErr != nil {
+			err = errors.Errorf("unable to assign NID to %s: %s", xname, transErr)
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+
+		counter, rowsErr := result.RowsAffected()
+		if rowsErr != nil {
+			err = errors.Errorf("assign NID failed: %s", rowsErr)
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+		if counter < 1 {
+			continue
+		}
+
+		err = setGenericHardwareUniqueProperties(trans, xname)
+		if err != nil {
+			version = 0
+			_ = trans.Rollback()
+			return
+		}
+
+		assigned = append(assigned, sls_common.NodeNID{NID: nid, Xname: xname})
+	}
+
+	// Don't leave an empty version behind if every node already had a NID.
+	if len(assigned) == 0 {
+		version = 0
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		version = 0
+		return
+	}
+
+	return
+}
+
+// ReserveNIDs reserves the lowest count free NIDs no lower than start until expires, so nothing else is handed them
+// in the meantime. Expired reservations are dropped first. Like AssignGenericHardwareNIDs, no other NIDs can be
+// handed out until it is done.
+func ReserveNIDs(count int, start int, expires time.Time) (reserved []sls_common.NodeNID, err error) {
+	trans, beginErr := DB.Begin()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	_, lockErr := trans.Exec("LOCK TABLE component_nids IN SHARE ROW EXCLUSIVE MODE")
+	if lockErr != nil {
+		err = errors.Errorf("unable to lock NIDs: %s", lockErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	_, transErr := trans.Exec("DELETE FROM nid_reservations WHERE expires <= NOW()")
+	if transErr != nil {
+		err = errors.Errorf("unable to drop expired NID reservations: %s", transErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	q := "INSERT INTO \n" +
+		"    nid_reservations (nid, expires) \n" +
+		"VALUES \n" +
+		"    ($1, $2)"
+
+	nid := start
+	for len(reserved) < count {
+		nid, err = nextFreeNID(trans, nid)
+		if err != nil {
+			reserved = nil
+			_ = trans.Rollback()
+			return
+		}
+
+		_, transErr = trans.Exec(q, nid, expires)
+		if transErr != nil {
+			err = errors.Errorf("unable to reserve NID %d: %s", nid, transErr)
+			reserved = nil
+			_ = trans.Rollback()
+			return
+		}
+
+		reserved = append(reserved, sls_common.NodeNID{NID: nid})
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		reserved = nil
+		return
+	}
+
+	return
+}
@@ -0,0 +1,205 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/pkg/errors"
+)
+
+// restoreColumn is a column of a table and how to get its value from the document r of a revision.
+type restoreColumn struct {
+	column string
+	value  string
+}
+
+// restoreTable describes how to bring one table back to the documents of its revisions.
+type restoreTable struct {
+	entityType string
+	table      string
+	key        string
+	columns    []restoreColumn
+}
+
+var restoreHardware = restoreTable{
+	entityType: revisionEntityHardware,
+	table:      "components",
+	key:        "xname",
+	columns: []restoreColumn{
+		{"parent", "r.document ->> 'Parent'"},
+		{"comp_type", "r.document ->> 'Type'"},
+		{"comp_class", "r.document ->> 'Class'"},
+		{"extra_properties", "r.document -> 'ExtraProperties'"},
+	},
+}
+
+var restoreNetworks = restoreTable{
+	entityType: revisionEntityNetwork,
+	table:      "network",
+	key:        "name",
+	columns: []restoreColumn{
+		{"full_name", "r.document ->> 'FullName'"},
+		{"ip_ranges", "ARRAY(SELECT jsonb_array_elements_text(r.document -> 'IPRanges'))::inet[]"},
+		{"type", "r.document ->> 'Type'"},
+		{"extra_properties", "r.document -> 'ExtraProperties'"},
+	},
+}
+
+// restore brings table back to how it was at version, writing every row that changes in newVersion.
+func (t restoreTable) restore(trans *sql.Tx, version int64, newVersion int64) (counts sls_common.RestoreCounts,
+	err error) {
+	restoreTable := "restore_" + t.table
+
+	_, transErr := trans.Exec("CREATE TEMPORARY TABLE " + restoreTable + " ( \n" +
+		"    name     VARCHAR NOT NULL PRIMARY KEY, \n" +
+		"    document JSONB   NOT NULL \n" +
+		") ON COMMIT DROP ")
+	if transErr != nil {
+		err = errors.Errorf("unable to create %s: %s", restoreTable, transErr)
+		return
+	}
+
+	_, transErr = trans.Exec("INSERT INTO \n"+
+		"    "+restoreTable+" (name, document) \n"+
+		"SELECT \n"+
+		"    name, \n"+
+		"    document \n"+
+		"FROM ( \n"+
+		revisionStatesQuery(t.entityType, t.table, t.key)+"\n"+
+		") AS states ", version)
+	if transErr != nil {
+		err = errors.Errorf("unable to find %s as of version %d: %s", t.table, version, transErr)
+		return
+	}
+
+	var sets, columns, values string
+	for _, column := range t.columns {
+		sets += "    " + column.column + " = " + column.value + ", \n"
+		columns += column.column + ", "
+		values += column.value + ", "
+	}
+
+	deleteQ := "DELETE \n" +
+		"FROM \n" +
+		"    " + t.table + " \n" +
+		"WHERE \n" +
+		"    " + t.key + " NOT IN (SELECT name FROM " + restoreTable + ") "
+	updateQ := "UPDATE " + t.table + " \n" +
+		"SET \n" +
+		sets +
+		"    last_updated_version = $1 \n" +
+		"FROM \n" +
+		"    " + restoreTable + " AS r \n" +
+		"WHERE \n" +
+		"    " + t.table + "." + t.key + " = r.name \n" +
+		"    AND " + t.table + "_revision_document(" + t.table + ") IS DISTINCT FROM r.document "
+	insertQ := "INSERT INTO \n" +
+		"    " + t.table + " (" + t.key + ", " + columns + "last_updated_version) \n" +
+		"SELECT \n" +
+		"    r.name, " + values + "$1 \n" +
+		"FROM \n" +
+		"    " + restoreTable + " AS r \n" +
+		"WHERE \n" +
+		"    r.name NOT IN (SELECT " + t.key + " FROM " + t.table + ") "
+
+	for _, step := range []struct {
+		q     string
+		args  []interface{}
+		count *int64
+	}{
+		{deleteQ, nil, &counts.Deleted},
+		{updateQ, []interface{}{newVersion}, &counts.Updated},
+		{insertQ, []interface{}{newVersion}, &counts.Inserted},
+	} {
+		result, transErr := trans.Exec(step.q, step.args...)
+		if transErr != nil {
+			err = errors.Errorf("unable to restore %s: %s", t.table, transErr)
+			return
+		}
+
+		*step.count, err = result.RowsAffected()
+		if err != nil {
+			err = errors.Errorf("unable to restore %s: %s", t.table, err)
+			return
+		}
+	}
+
+	return
+}
+
+// RestoreVersion brings all hardware and networks back to how they were at version, which has to be in
+// GetVersionRange, all in one transaction. The restore is recorded as a new version, and every object it changes as
+// a revision in that version.
+func RestoreVersion(version int64) (result sls_common.RestoreResult, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	// Nothing else can be written until the restore is done.
+	_, transErr := trans.Exec("LOCK TABLE components, network IN SHARE ROW EXCLUSIVE MODE")
+	if transErr != nil {
+		err = errors.Errorf("unable to lock tables: %s", transErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	result.RestoredVersion = version
+	result.Version, err = IncrementVersion(trans, fmt.Sprintf("restore:%d", version))
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	result.Hardware, err = restoreHardware.restore(trans, version, result.Version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	result.Networks, err = restoreNetworks.restore(trans, version, result.Version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	// The hardware was consistent at version, so the aliases and NIDs are too.
+	err = setGenericHardwareUniqueProperties(trans, "")
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,388 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/pkg/errors"
+)
+
+// The entity_type of the revisions of hardware and of networks.
+const (
+	revisionEntityHardware = "hardware"
+	revisionEntityNetwork  = "network"
+)
+
+// revisionsStart is the updated_entity of the version revisions are complete from, see migration 8.
+const revisionsStart = "revisions start"
+
+// revisionStatesQuery returns a query for the name, document and version of every entityType object as of the
+// version given as $1. An object is as the latest revision of it up to that version left it, or as the first revision
+// of it after that version found it. Objects in table with no revisions at all haven't changed since revisions start.
+func revisionStatesQuery(entityType string, table string, key string) string {
+	return "SELECT \n" +
+		"    name, \n" +
+		"    document, \n" +
+		"    version \n" +
+		"FROM ( \n" +
+		"    SELECT DISTINCT ON (entity_name) \n" +
+		"        entity_name AS name, \n" +
+		"        CASE WHEN version <= $1 THEN after ELSE before END AS document, \n" +
+		"        CASE WHEN version <= $1 THEN version END AS version \n" +
+		"    FROM \n" +
+		"        revisions \n" +
+		"    WHERE \n" +
+		"        entity_type = '" + entityType + "' \n" +
+		"    ORDER BY \n" +
+		"        entity_name, \n" +
+		"        version <= $1 DESC, \n" +
+		"        CASE WHEN version <= $1 THEN -revision ELSE revision END \n" +
+		") AS states \n" +
+		"WHERE \n" +
+		"    document IS NOT NULL \n" +
+		"UNION ALL \n" +
+		"SELECT \n" +
+		"    " + key + ", \n" +
+		"    " + table + "_revision_document(" + table + "), \n" +
+		"    last_updated_version \n" +
+		"FROM \n" +
+		"    " + table + " \n" +
+		"WHERE \n" +
+		"    NOT EXISTS (SELECT 1 FROM revisions \n" +
+		"                WHERE entity_type = '" + entityType + "' AND entity_name = " + table + "." + key + ") "
+}
+
+// getRevisionStates calls add with the document of every entityType object as of version, ordered by name, along
+// with when it was last updated if that is known.
+func getRevisionStates(entityType string, table string, key string, version int64,
+	add func(document []byte, lastUpdated sql.NullTime) error) (err error) {
+	q := "SELECT \n" +
+		"    states.document, \n" +
+		"    version_history.timestamp \n" +
+		"FROM ( \n" +
+		revisionStatesQuery(entityType, table, key) + "\n" +
+		") AS states \n" +
+		"LEFT JOIN \n" +
+		"    version_history \n" +
+		"ON states.version = version_history.version \n" +
+		"ORDER BY \n" +
+		"    states.name "
+
+	rows, queryErr := DB.Query(q, version)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query %s as of version %d: %s", table, version, queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var document []byte
+		var lastUpdated sql.NullTime
+
+		scanErr := rows.Scan(&document, &lastUpdated)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan %s row: %s", table, scanErr)
+			return
+		}
+
+		err = add(document, lastUpdated)
+		if err != nil {
+			return
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read %s: %s", table, err)
+	}
+
+	return
+}
+
+// GetVersionRange returns the oldest version SLS can be seen as of, and the current version.
+func GetVersionRange() (start int64, current int64, err error) {
+	q := "SELECT \n" +
+		"    COALESCE(max(version) FILTER (WHERE updated_entity = $1), 1), \n" +
+		"    max(version) \n" +
+		"FROM \n" +
+		"    version_history "
+
+	scanErr := DB.QueryRow(q, revisionsStart).Scan(&start, &current)
+	if scanErr != nil {
+		err = errors.Errorf("unable to query version range: %s", scanErr)
+	}
+
+	return
+}
+
+// GetVersionAsOf returns the version SLS was at at the given time, or NoSuch if that was before the first version.
+func GetVersionAsOf(asOf time.Time) (version int64, err error) {
+	q := "SELECT \n" +
+		"    max(version) \n" +
+		"FROM \n" +
+		"    version_history \n" +
+		"WHERE \n" +
+		"    timestamp <= $1 "
+
+	var found sql.NullInt64
+	scanErr := DB.QueryRow(q, asOf).Scan(&found)
+	if scanErr != nil {
+		err = errors.Errorf("unable to query version as of %s: %s", asOf, scanErr)
+		return
+	}
+	if !found.Valid {
+		err = NoSuch
+		return
+	}
+
+	version = found.Int64
+	return
+}
+
+// GetAllGenericHardwareAsOf returns all hardware as it was at the given version, which has to be in GetVersionRange.
+func GetAllGenericHardwareAsOf(version int64) (hardware []sls_common.GenericHardware, err error) {
+	err = getRevisionStates(revisionEntityHardware, "components", "xname", version,
+		func(document []byte, lastUpdated sql.NullTime) error {
+			thisGenericHardware, err := revisionGenericHardware(document)
+			if err != nil {
+				return err
+			}
+			if lastUpdated.Valid {
+				thisGenericHardware.LastUpdated = lastUpdated.Time.Unix()
+				thisGenericHardware.LastUpdatedTime = lastUpdated.Time.String()
+			}
+
+			hardware = append(hardware, *thisGenericHardware)
+			return nil
+		})
+	if err != nil {
+		return
+	}
+
+	setChildren(hardware)
+	return
+}
+
+// setChildren sets the Children of all of hardware to the rest of it that has it as Parent.
+func setChildren(hardware []sls_common.GenericHardware) {
+	children := make(map[string][]string)
+	for _, thisGenericHardware := range hardware {
+		children[thisGenericHardware.Parent] = append(children[thisGenericHardware.Parent], thisGenericHardware.Xname)
+	}
+	for i := range hardware {
+		hardware[i].Children = children[hardware[i].Xname]
+		sort.Strings(hardware[i].Children)
+	}
+}
+
+// GetAllNetworksAsOf returns all networks as they were at the given version, which has to be in GetVersionRange.
+func GetAllNetworksAsOf(version int64) (networks []sls_common.Network, err error) {
+	err = getRevisionStates(revisionEntityNetwork, "network", "name", version,
+		func(document []byte, lastUpdated sql.NullTime) error {
+			thisNetwork, err := revisionNetwork(document)
+			if err != nil {
+				return err
+			}
+			if lastUpdated.Valid {
+				thisNetwork.LastUpdated = lastUpdated.Time.Unix()
+				thisNetwork.LastUpdatedTime = lastUpdated.Time.String()
+			}
+
+			networks = append(networks, *thisNetwork)
+			return nil
+		})
+
+	return
+}
+
+// getRevisions calls add with the revisions of the named entity of entityType, newest first, skipping offset of them
+// and stopping after limit unless limit is 0. It returns how many revisions there are in all.
+func getRevisions(entityType string, name string, limit int, offset int,
+	add func(info sls_common.RevisionInfo, before []byte, after []byte) error) (total int64, err error) {
+	countQ := "SELECT \n" +
+		"    count(*) \n" +
+		"FROM \n" +
+		"    revisions \n" +
+		"WHERE \n" +
+		"    entity_type = $1 \n" +
+		"    AND entity_name = $2 "
+
+	scanErr := DB.QueryRow(countQ, entityType, name).Scan(&total)
+	if scanErr != nil {
+		err = errors.Errorf("unable to count revisions: %s", scanErr)
+		return
+	}
+
+	q := "SELECT \n" +
+		"    revision, \n" +
+		"    revisions.version, \n" +
+		"    timestamp, \n" +
+		"    updated_entity, \n" +
+		"    operation, \n" +
+		"    before, \n" +
+		"    after \n" +
+		"FROM \n" +
+		"    revisions \n" +
+		"INNER JOIN \n" +
+		"    version_history \n" +
+		"ON revisions.version = version_history.version \n" +
+		"WHERE \n" +
+		"    entity_type = $1 \n" +
+		"    AND entity_name = $2 \n" +
+		"ORDER BY \n" +
+		"    revision DESC \n" +
+		"LIMIT $3 \n" +
+		"OFFSET $4 "
+
+	// LIMIT NULL is no limit at all.
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	rows, queryErr := DB.Query(q, entityType, name, limitArg, offset)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query revisions: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var info sls_common.RevisionInfo
+		var timestamp time.Time
+		var updatedEntity sql.NullString
+		var before, after []byte
+
+		scanErr := rows.Scan(&info.Revision,
+			&info.Version,
+			&timestamp,
+			&updatedEntity,
+			&info.Operation,
+			&before,
+			&after)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan revision row: %s", scanErr)
+			return
+		}
+
+		info.Timestamp = timestamp.Unix()
+		info.TimestampTime = timestamp.String()
+		info.UpdatedEntity = updatedEntity.String
+
+		err = add(info, before, after)
+		if err != nil {
+			return
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read revisions: %s", err)
+	}
+
+	return
+}
+
+// revisionGenericHardware unmarshals hardware recorded in a revision, or returns nil if nothing was.
+func revisionGenericHardware(document []byte) (*sls_common.GenericHardware, error) {
+	if document == nil {
+		return nil, nil
+	}
+
+	var hardware sls_common.GenericHardware
+	err := json.Unmarshal(document, &hardware)
+	if err != nil {
+		return nil, errors.Errorf("unable to unmarshal hardware revision: %s", err)
+	}
+	hardware.TypeString = sls_common.HMSStringTypeToHMSType(hardware.Type)
+
+	return &hardware, nil
+}
+
+// revisionNetwork unmarshals a network recorded in a revision, or returns nil if nothing was.
+func revisionNetwork(document []byte) (*sls_common.Network, error) {
+	if document == nil {
+		return nil, nil
+	}
+
+	var network sls_common.Network
+	err := json.Unmarshal(document, &network)
+	if err != nil {
+		return nil, errors.Errorf("unable to unmarshal network revision: %s", err)
+	}
+
+	return &network, nil
+}
+
+// GetGenericHardwareRevisions returns a page of the changes made to the hardware with xname, newest first, along
+// with how many changes there are in all. A limit of 0 returns all of them.
+func GetGenericHardwareRevisions(xname string, limit int, offset int) (revisions []sls_common.HardwareRevision,
+	total int64, err error) {
+	revisions = []sls_common.HardwareRevision{}
+	total, err = getRevisions(revisionEntityHardware, xname, limit, offset,
+		func(info sls_common.RevisionInfo, before []byte, after []byte) (err error) {
+			revision := sls_common.HardwareRevision{RevisionInfo: info}
+			revision.Before, err = revisionGenericHardware(before)
+			if err != nil {
+				return
+			}
+			revision.After, err = revisionGenericHardware(after)
+			if err != nil {
+				return
+			}
+
+			revisions = append(revisions, revision)
+			return
+		})
+
+	return
+}
+
+// GetNetworkRevisions returns a page of the changes made to the named network, newest first, along with how many
+// changes there are in all. A limit of 0 returns all of them.
+func GetNetworkRevisions(name string, limit int, offset int) (revisions []sls_common.NetworkRevision,
+	total int64, err error) {
+	revisions = []sls_common.NetworkRevision{}
+	total, err = getRevisions(revisionEntityNetwork, name, limit, offset,
+		func(info sls_common.RevisionInfo, before []byte, after []byte) (err error) {
+			revision := sls_common.NetworkRevision{RevisionInfo: info}
+			revision.Before, err = revisionNetwork(before)
+			if err != nil {
+				return
+			}
+			revision.After, err = revisionNetwork(after)
+			if err != nil {
+				return
+			}
+
+			revisions = append(revisions, revision)
+			return
+		})
+
+	return
+}
@@ -0,0 +1,297 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Cray-HPE/hms-sls/internal/search"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Maps the field names a search filter may use onto the columns they are stored in. Anything else is a key of
+// ExtraProperties.
+var hardwareSearchColumns = map[string]string{
+	"xname":      "xname",
+	"parent":     "parent",
+	"type":       "comp_type",
+	"comp_type":  "comp_type",
+	"class":      "comp_class",
+	"comp_class": "comp_class",
+}
+
+var networkSearchColumns = map[string]string{
+	"name":       "name",
+	"full_name":  "full_name",
+	"type":       "type",
+	"ip_address": "ip_ranges",
+	"ip_ranges":  "ip_ranges",
+}
+
+// ConditionsFilter turns the column conditions and ExtraProperties values of the original search API into a filter.
+// A property with a list of values matches any of them.
+func ConditionsFilter(conditions map[string]string, properties map[string]interface{}) (search.Expr, error) {
+	filter := search.And{}
+	for column, value := range conditions {
+		filter = append(filter, search.Comparison{Field: column, Op: search.Equal, Values: []string{value}})
+	}
+
+	for key, value := range properties {
+		field := search.ExtraPropertiesPrefix + key
+		if valueString, ok := value.(string); ok {
+			filter = append(filter, search.Comparison{Field: field, Op: search.Equal, Values: []string{valueString}})
+		} else if valueArray, ok := value.([]string); ok {
+			filter = append(filter, search.Comparison{Field: field, Op: search.In, Values: valueArray})
+		} else {
+			return nil, fmt.Errorf("%w: unable to query on parameter %s: %v", search.InvalidFilter, key, value)
+		}
+	}
+
+	return filter, nil
+}
+
+// whereBuilder compiles a search filter into a WHERE clause. Every value ends up as a bind parameter in args, only
+// column names from columns and fixed SQL make it into the clause itself.
+type whereBuilder struct {
+	columns map[string]string
+	args    []interface{}
+}
+
+func (b *whereBuilder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *whereBuilder) build(filter search.Expr) (string, error) {
+	switch expr := filter.(type) {
+	case search.And:
+		return b.join(expr, " AND ", "TRUE")
+	case search.Or:
+		return b.join(expr, " OR ", "FALSE")
+	case search.Not:
+		clause, err := b.build(expr.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + clause, nil
+	case search.Comparison:
+		clause, err := b.comparison(expr)
+		if err != nil {
+			return "", err
+		}
+		// A missing column or property is NULL, which would make NOT drop the row as well.
+		return "COALESCE(" + clause + ", FALSE)", nil
+	}
+
+	return "", errors.Errorf("unknown search expression: %v", filter)
+}
+
+func (b *whereBuilder) join(exprs []search.Expr, operator string, empty string) (string, error) {
+	if len(exprs) == 0 {
+		return empty, nil
+	}
+
+	clauses := make([]string, len(exprs))
+	for i, expr := range exprs {
+		clause, err := b.build(expr)
+		if err != nil {
+			return "", err
+		}
+		clauses[i] = clause
+	}
+
+	return "(" + strings.Join(clauses, operator) + ")", nil
+}
+
+func (b *whereBuilder) comparison(comparison search.Comparison) (string, error) {
+	if len(comparison.Values) == 0 {
+		return "", fmt.Errorf("%w: no value to compare %s with", search.InvalidFilter, comparison.Field)
+	}
+
+	column, isColumn := b.columns[strings.ToLower(comparison.Field)]
+	if isColumn && column == "ip_ranges" {
+		return b.ipComparison(comparison)
+	} else if isColumn {
+		return b.valueComparison(column, comparison), nil
+	}
+
+	path, err := search.ParsePath(strings.TrimPrefix(comparison.Field, search.ExtraPropertiesPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	return b.pathComparison(path, comparison), nil
+}
+
+// valueComparison compares a text expression, such as a column, with the values of the comparison.
+func (b *whereBuilder) valueComparison(value string, comparison search.Comparison) string {
+	switch comparison.Op {
+	case search.In:
+		return fmt.Sprintf("%s = ANY(%s::text[])", value, b.bind(pq.Array(comparison.Values)))
+	case search.Like:
+		return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, value, b.bind(search.GlobToLike(comparison.Values[0])))
+	default:
+		return fmt.Sprintf("%s %s %s", value, comparison.Op, b.bind(comparison.Values[0]))
+	}
+}
+
+// ipComparison matches networks with an IP range containing the given address.
+func (b *whereBuilder) ipComparison(comparison search.Comparison) (string, error) {
+	if comparison.Op != search.Equal && comparison.Op != search.In {
+		return "", fmt.Errorf("%w: %s only supports = and IN", search.InvalidFilter, comparison.Field)
+	}
+
+	clauses := make([]string, len(comparison.Values))
+	for i, value := range comparison.Values {
+		_, _, cidrErr := net.ParseCIDR(value)
+		if net.ParseIP(value) == nil && cidrErr != nil {
+			return "", fmt.Errorf("%w: %s is not an IP address", search.InvalidFilter, value)
+		}
+		clauses[i] = fmt.Sprintf("%s::inet <<= ANY(ip_ranges)", b.bind(value))
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", nil
+}
+
+// pathComparison compares the values at path in ExtraProperties. Keys and indexes are looked up with #>, every []
+// step adds a jsonb_array_elements to an EXISTS so any element can match. Equality is also checked with containment,
+// which is implied by the comparison but can use the GIN index on extra_properties.
+func (b *whereBuilder) pathComparison(path search.Path, comparison search.Comparison) string {
+	current := "extra_properties"
+	var keys []string
+	var elements []string
+
+	lookup := func() string {
+		if len(keys) == 0 {
+			return current
+		}
+		value := fmt.Sprintf("%s #> %s::text[]", current, b.bind(pq.Array(keys)))
+		keys = nil
+		return value
+	}
+
+	for _, step := range path {
+		if !step.Each {
+			keys = append(keys, step.Key)
+			continue
+		}
+
+		array := lookup()
+		current = fmt.Sprintf("element%d", len(elements))
+		elements = append(elements, fmt.Sprintf(
+			"jsonb_array_elements(CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE '[]' END) AS %[2]s",
+			array, current))
+	}
+
+	clause := b.propertyComparison(lookup(), comparison)
+	if len(elements) != 0 {
+		clause = fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", strings.Join(elements, ", "), clause)
+	}
+
+	if comparison.Op == search.Equal || comparison.Op == search.In {
+		clause = fmt.Sprintf("(%s AND %s)", b.containment(path, comparison.Values), clause)
+	}
+
+	return clause
+}
+
+// containment matches rows whose ExtraProperties contain any of the JSON values the given strings could have been
+// at path, either directly or as an element of an array.
+func (b *whereBuilder) containment(path search.Path, values []string) string {
+	var clauses []string
+	for _, value := range values {
+		candidates := []interface{}{value}
+		if number, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) &&
+			!math.IsInf(number, 0) {
+			candidates = append(candidates, json.RawMessage(value))
+		}
+		if value == "true" || value == "false" {
+			candidates = append(candidates, value == "true")
+		}
+
+		for _, candidate := range candidates {
+			for _, leaf := range []interface{}{candidate, []interface{}{candidate}} {
+				document := leaf
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i].Each || path[i].Index {
+						document = []interface{}{document}
+					} else {
+						document = map[string]interface{}{path[i].Key: document}
+					}
+				}
+
+				documentJSON, _ := json.Marshal(document)
+				clauses = append(clauses, fmt.Sprintf("extra_properties @> %s::jsonb", b.bind(string(documentJSON))))
+			}
+		}
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")"
+}
+
+// propertyComparison compares a JSON value. A scalar is compared directly, an array matches if any of its elements
+// does. Ordering comparisons against a number compare numerically and only match numbers.
+func (b *whereBuilder) propertyComparison(property string, comparison search.Comparison) string {
+	if comparison.Op != search.Equal && comparison.Op != search.In && comparison.Op != search.Like {
+		number, err := strconv.ParseFloat(comparison.Values[0], 64)
+		if err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+			return fmt.Sprintf("CASE WHEN jsonb_typeof(%[1]s) = 'number' THEN (%[1]s #>> '{}')::numeric END %[2]s %[3]s::numeric",
+				property, comparison.Op, b.bind(comparison.Values[0]))
+		}
+
+		scalar := fmt.Sprintf("CASE WHEN jsonb_typeof(%[1]s) NOT IN ('array', 'object') THEN %[1]s #>> '{}' END",
+			property)
+		return b.valueComparison(scalar, comparison)
+	}
+
+	scalar := fmt.Sprintf("CASE WHEN jsonb_typeof(%[1]s) NOT IN ('array', 'object') THEN %[1]s #>> '{}' END", property)
+	elements := fmt.Sprintf("jsonb_array_elements_text(CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE '[]' END)",
+		property)
+
+	return fmt.Sprintf("(%s OR EXISTS (SELECT 1 FROM %s AS element WHERE %s))",
+		b.valueComparison(scalar, comparison), elements, b.valueComparison("element", comparison))
+}
+
+// buildWhere compiles filter into a WHERE clause and its arguments. An empty filter is an error, searches always
+// need something to search for.
+func buildWhere(filter search.Expr, columns map[string]string) (where string, args []interface{}, err error) {
+	comparisons := 0
+	if filter != nil {
+		search.Walk(filter, func(*search.Comparison) { comparisons++ })
+	}
+	if comparisons == 0 {
+		err = errors.Errorf("no conditions/properties with which to search")
+		return
+	}
+
+	b := whereBuilder{columns: columns}
+	where, err = b.build(filter)
+	args = b.args
+	return
+}
@@ -0,0 +1,113 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/hms-sls/internal/search"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SearchTestSuite) TestBuildWhere_Parameterized() {
+	filter, err := search.Parse("xname = \"x3000' OR '1'='1\" AND NID >= 1000 AND NOT Role IN (Compute, Application)")
+	suite.NoError(err)
+
+	where, args, err := buildWhere(filter, hardwareSearchColumns)
+	suite.NoError(err)
+
+	// None of the values make it into the clause.
+	for _, value := range []string{"x3000", "1000", "NID", "Role", "Compute"} {
+		suite.False(strings.Contains(where, value), "%s in %s", value, where)
+	}
+	suite.Len(args, 10)
+	suite.Equal("x3000' OR '1'='1", args[0])
+	suite.Equal(pq.Array([]string{"NID"}), args[1])
+	suite.Equal("1000", args[2])
+}
+
+func (suite *SearchTestSuite) TestBuildWhere_Path() {
+	filter, err := search.Parse("Subnets[].IPReservations[].Name = ncn-m001 AND Networks.cn.HMN.VLan = 3001")
+	suite.NoError(err)
+
+	where, args, err := buildWhere(filter, networkSearchColumns)
+	suite.NoError(err)
+
+	suite.Contains(where, "jsonb_array_elements(CASE WHEN jsonb_typeof(extra_properties #> $1::text[]) = 'array'")
+	suite.Contains(where, "AS element0, jsonb_array_elements(CASE WHEN jsonb_typeof(element0 #> $2::text[])")
+	suite.Contains(where, "AS element1 WHERE")
+	suite.Contains(where, "extra_properties @> ")
+	suite.Contains(args, pq.Array([]string{"Subnets"}))
+	suite.Contains(args, pq.Array([]string{"IPReservations"}))
+	suite.Contains(args, pq.Array([]string{"Name"}))
+	suite.Contains(args, pq.Array([]string{"Networks", "cn", "HMN", "VLan"}))
+
+	// Equality is also checked by containment so the GIN index can be used, for every type the value could have.
+	suite.Contains(args, `{"Subnets":[{"IPReservations":[{"Name":"ncn-m001"}]}]}`)
+	suite.Contains(args, `{"Subnets":[{"IPReservations":[{"Name":["ncn-m001"]}]}]}`)
+	suite.Contains(args, `{"Networks":{"cn":{"HMN":{"VLan":"3001"}}}}`)
+	suite.Contains(args, `{"Networks":{"cn":{"HMN":{"VLan":3001}}}}`)
+
+	_, _, err = buildWhere(search.Comparison{Field: "Subnets[x].Name", Op: search.Equal, Values: []string{"foo"}},
+		networkSearchColumns)
+	suite.True(errors.Is(err, search.InvalidFilter), "%v", err)
+}
+
+func (suite *SearchTestSuite) TestBuildWhere_Errors() {
+	_, _, err := buildWhere(nil, hardwareSearchColumns)
+	suite.Error(err)
+
+	_, _, err = buildWhere(search.And{search.Or{}}, hardwareSearchColumns)
+	suite.Error(err)
+
+	_, _, err = buildWhere(search.Comparison{Field: "ip_address", Op: search.Equal, Values: []string{"foo"}},
+		networkSearchColumns)
+	suite.True(errors.Is(err, search.InvalidFilter), "%v", err)
+
+	_, _, err = buildWhere(search.Comparison{Field: "ip_address", Op: search.Less, Values: []string{"10.0.0.1"}},
+		networkSearchColumns)
+	suite.True(errors.Is(err, search.InvalidFilter), "%v", err)
+}
+
+func (suite *SearchTestSuite) TestConditionsFilter() {
+	filter, err := ConditionsFilter(map[string]string{"comp_type": "comptype_node"},
+		map[string]interface{}{"SubRole": []string{"Master", "Storage"}})
+	suite.NoError(err)
+	suite.Equal(search.And{
+		search.Comparison{Field: "comp_type", Op: search.Equal, Values: []string{"comptype_node"}},
+		search.Comparison{Field: "extra_properties.SubRole", Op: search.In, Values: []string{"Master", "Storage"}},
+	}, filter)
+
+	_, err = ConditionsFilter(nil, map[string]interface{}{"NID": 1000})
+	suite.Error(err)
+}
+
+func TestSearchSuite(t *testing.T) {
+	suite.Run(t, new(SearchTestSuite))
+}
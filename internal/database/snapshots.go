@@ -0,0 +1,297 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// snapshotColumns are what scanSnapshot reads, in order.
+const snapshotColumns = "    name, \n" +
+	"    comment, \n" +
+	"    version, \n" +
+	"    timestamp"
+
+func scanSnapshot(row rowScanner) (snapshot sls_common.Snapshot, err error) {
+	var timestamp time.Time
+
+	err = row.Scan(&snapshot.Name,
+		&snapshot.Comment,
+		&snapshot.Version,
+		&timestamp)
+	if err != nil {
+		return
+	}
+
+	snapshot.Timestamp = timestamp.Unix()
+	snapshot.TimestampTime = timestamp.String()
+	return
+}
+
+// InsertSnapshot copies all hardware and networks as they are now into a snapshot called name, or returns
+// AlreadySuch if there is one.
+func InsertSnapshot(name string, comment string) (snapshot sls_common.Snapshot, err error) {
+	return insertSnapshot(DB, name, comment)
+}
+
+func insertSnapshot(db querier, name string, comment string) (snapshot sls_common.Snapshot, err error) {
+	q := "INSERT INTO \n" +
+		"    snapshots (name, comment, version, state) \n" +
+		"SELECT \n" +
+		"    $1, \n" +
+		"    $2, \n" +
+		"    (SELECT max(version) FROM version_history), \n" +
+		"    jsonb_build_object( \n" +
+		"        'Hardware', COALESCE((SELECT jsonb_object_agg(xname, components_revision_document(components)) \n" +
+		"                              FROM components), '{}'), \n" +
+		"        'Networks', COALESCE((SELECT jsonb_object_agg(name, network_revision_document(network)) \n" +
+		"                              FROM network), '{}')) \n" +
+		"RETURNING \n" +
+		snapshotColumns + " "
+
+	snapshot, scanErr := scanSnapshot(db.QueryRow(q, name, comment))
+	if pqErr, ok := scanErr.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+		err = AlreadySuch
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to insert snapshot: %s", scanErr)
+	}
+
+	return
+}
+
+// deleteOldSnapshots deletes all but the newest keep snapshots with names starting with prefix as part of trans.
+func deleteOldSnapshots(trans *sql.Tx, prefix string, keep int) (err error) {
+	q := "DELETE \n" +
+		"FROM \n" +
+		"    snapshots \n" +
+		"WHERE \n" +
+		"    left(name, length($1)) = $1 \n" +
+		"    AND name NOT IN ( \n" +
+		"        SELECT \n" +
+		"            name \n" +
+		"        FROM \n" +
+		"            snapshots \n" +
+		"        WHERE \n" +
+		"            left(name, length($1)) = $1 \n" +
+		"        ORDER BY \n" +
+		"            version DESC, \n" +
+		"            timestamp DESC \n" +
+		"        LIMIT $2) "
+
+	_, execErr := trans.Exec(q, prefix, keep)
+	if execErr != nil {
+		err = errors.Errorf("unable to delete old snapshots: %s", execErr)
+	}
+
+	return
+}
+
+// GetSnapshots returns every snapshot, oldest first.
+func GetSnapshots() (snapshots []sls_common.Snapshot, err error) {
+	q := "SELECT \n" +
+		snapshotColumns + " \n" +
+		"FROM \n" +
+		"    snapshots \n" +
+		"ORDER BY \n" +
+		"    timestamp, \n" +
+		"    name "
+
+	rows, queryErr := DB.Query(q)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query snapshots: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		snapshot, scanErr := scanSnapshot(rows)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan snapshot row: %s", scanErr)
+			return
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read snapshots: %s", err)
+	}
+
+	return
+}
+
+// getSnapshotState returns the snapshot called name and its hardware and networks, or NoSuch.
+func getSnapshotState(q querier, name string, lock string) (snapshot sls_common.Snapshot,
+	hardware []sls_common.GenericHardware, networks []sls_common.Network, err error) {
+	query := "SELECT \n" +
+		snapshotColumns + ", \n" +
+		"    state -> 'Hardware', \n" +
+		"    state -> 'Networks' \n" +
+		"FROM \n" +
+		"    snapshots \n" +
+		"WHERE \n" +
+		"    name = $1 " +
+		lock
+
+	var timestamp time.Time
+	var hardwareDocuments, networkDocuments []byte
+
+	scanErr := q.QueryRow(query, name).Scan(&snapshot.Name,
+		&snapshot.Comment,
+		&snapshot.Version,
+		&timestamp,
+		&hardwareDocuments,
+		&networkDocuments)
+	if scanErr == sql.ErrNoRows {
+		err = NoSuch
+		return
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to scan snapshot: %s", scanErr)
+		return
+	}
+
+	snapshot.Timestamp = timestamp.Unix()
+	snapshot.TimestampTime = timestamp.String()
+
+	var documents map[string]json.RawMessage
+	err = json.Unmarshal(hardwareDocuments, &documents)
+	if err != nil {
+		err = errors.Errorf("unable to unmarshal snapshot hardware: %s", err)
+		return
+	}
+	for _, document := range documents {
+		thisGenericHardware, hardwareErr := revisionGenericHardware(document)
+		if hardwareErr != nil {
+			err = hardwareErr
+			return
+		}
+		hardware = append(hardware, *thisGenericHardware)
+	}
+	setChildren(hardware)
+
+	documents = nil
+	err = json.Unmarshal(networkDocuments, &documents)
+	if err != nil {
+		err = errors.Errorf("unable to unmarshal snapshot networks: %s", err)
+		return
+	}
+	for _, document := range documents {
+		thisNetwork, networkErr := revisionNetwork(document)
+		if networkErr != nil {
+			err = networkErr
+			return
+		}
+		networks = append(networks, *thisNetwork)
+	}
+
+	return
+}
+
+// GetSnapshot returns the snapshot called name with its hardware and networks, or NoSuch.
+func GetSnapshot(name string) (sls_common.Snapshot, []sls_common.GenericHardware, []sls_common.Network, error) {
+	return getSnapshotState(DB, name, "")
+}
+
+// DeleteSnapshot deletes the snapshot called name, or returns NoSuch.
+func DeleteSnapshot(name string) (err error) {
+	q := "DELETE \n" +
+		"FROM \n" +
+		"    snapshots \n" +
+		"WHERE \n" +
+		"    name = $1 "
+
+	result, execErr := DB.Exec(q, name)
+	if execErr != nil {
+		err = errors.Errorf("unable to delete snapshot: %s", execErr)
+		return
+	}
+
+	counter, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		err = errors.Errorf("unable to get rows affected: %s", rowsErr)
+		return
+	}
+	if counter == 0 {
+		err = NoSuch
+	}
+
+	return
+}
+
+// RestoreSnapshot replaces all hardware and networks with the ones in the snapshot called name in a single
+// transaction, or returns NoSuch.
+func RestoreSnapshot(name string) (result sls_common.SnapshotRestoreResult, err error) {
+	trans, beginErr := beginVersion()
+	if beginErr != nil {
+		err = errors.Errorf("unable to begin transaction: %s", beginErr)
+		return
+	}
+
+	_, transErr := trans.Exec("LOCK TABLE components, network IN SHARE ROW EXCLUSIVE MODE")
+	if transErr != nil {
+		err = errors.Errorf("unable to lock tables: %s", transErr)
+		_ = trans.Rollback()
+		return
+	}
+
+	snapshot, hardware, networks, err := getSnapshotState(trans, name, "FOR SHARE")
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+	result.Snapshot = snapshot
+
+	result.Version, err = IncrementVersion(trans, "snapshot:"+name)
+	if err != nil {
+		err = errors.Errorf("insert to version_history failed: %s", err)
+		_ = trans.Rollback()
+		return
+	}
+
+	err = replaceAllGenericHardware(trans, hardware, result.Version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	err = replaceAllNetworks(trans, networks, result.Version)
+	if err != nil {
+		_ = trans.Rollback()
+		return
+	}
+
+	commitErr := trans.Commit()
+	if commitErr != nil {
+		err = errors.Errorf("unable to commit transaction: %s", commitErr)
+		return
+	}
+
+	return
+}
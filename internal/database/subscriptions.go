@@ -0,0 +1,315 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/pkg/errors"
+)
+
+// subscriptionColumns are what scanSubscription reads, in order.
+const subscriptionColumns = "    id, \n" +
+	"    url, \n" +
+	"    secret, \n" +
+	"    filter, \n" +
+	"    documents, \n" +
+	"    created, \n" +
+	"    last_version \n"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row rowScanner) (subscription sls_common.Subscription, err error) {
+	var filter []byte
+	var created time.Time
+
+	err = row.Scan(&subscription.ID,
+		&subscription.URL,
+		&subscription.Secret,
+		&filter,
+		&subscription.Documents,
+		&created,
+		&subscription.Version)
+	if err != nil {
+		return
+	}
+
+	subscription.Created = created.Unix()
+	subscription.CreatedTime = created.String()
+
+	err = json.Unmarshal(filter, &subscription.Filter)
+	if err != nil {
+		err = errors.Errorf("unable to unmarshal subscription filter: %s", err)
+	}
+
+	return
+}
+
+// InsertSubscription stores subscription, to be notified of the changes made after the current version.
+func InsertSubscription(subscription sls_common.Subscription) (sls_common.Subscription, error) {
+	q := "INSERT INTO \n" +
+		"    subscriptions (url, secret, filter, documents, last_version) \n" +
+		"SELECT \n" +
+		"    $1, $2, $3, $4, max(version) \n" +
+		"FROM \n" +
+		"    version_history \n" +
+		"RETURNING \n" +
+		subscriptionColumns
+
+	filter, err := json.Marshal(subscription.Filter)
+	if err != nil {
+		return subscription, errors.Errorf("unable to marshal subscription filter: %s", err)
+	}
+
+	inserted, scanErr := scanSubscription(DB.QueryRow(q, subscription.URL, subscription.Secret, filter,
+		subscription.Documents))
+	if scanErr != nil {
+		return subscription, errors.Errorf("unable to insert subscription: %s", scanErr)
+	}
+
+	return inserted, nil
+}
+
+// GetSubscriptions returns every subscription, ordered by ID.
+func GetSubscriptions() (subscriptions []sls_common.Subscription, err error) {
+	q := "SELECT \n" +
+		subscriptionColumns +
+		"FROM \n" +
+		"    subscriptions \n" +
+		"ORDER BY \n" +
+		"    id "
+
+	rows, queryErr := DB.Query(q)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query subscriptions: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscription, scanErr := scanSubscription(rows)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan subscription row: %s", scanErr)
+			return
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read subscriptions: %s", err)
+	}
+
+	return
+}
+
+// GetSubscription returns the subscription with the given ID, or NoSuch.
+func GetSubscription(id int64) (subscription sls_common.Subscription, err error) {
+	q := "SELECT \n" +
+		subscriptionColumns +
+		"FROM \n" +
+		"    subscriptions \n" +
+		"WHERE \n" +
+		"    id = $1 "
+
+	subscription, scanErr := scanSubscription(DB.QueryRow(q, id))
+	if scanErr == sql.ErrNoRows {
+		err = NoSuch
+	} else if scanErr != nil {
+		err = errors.Errorf("unable to scan subscription: %s", scanErr)
+	}
+
+	return
+}
+
+// DeleteSubscription deletes the subscription with the given ID and its dead letters, or returns NoSuch.
+func DeleteSubscription(id int64) (err error) {
+	q := "DELETE \n" +
+		"FROM \n" +
+		"    subscriptions \n" +
+		"WHERE \n" +
+		"    id = $1 "
+
+	result, execErr := DB.Exec(q, id)
+	if execErr != nil {
+		err = errors.Errorf("unable to delete subscription: %s", execErr)
+		return
+	}
+
+	counter, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		err = errors.Errorf("unable to get rows affected: %s", rowsErr)
+		return
+	}
+	if counter == 0 {
+		err = NoSuch
+	}
+
+	return
+}
+
+/*
+LeaseSubscription claims the subscription with the given ID for lease, so no
+other instance of SLS delivers to it meanwhile.  It returns nil if another
+instance holds it or it was deleted.
+*/
+func LeaseSubscription(id int64, lease time.Duration) (*sls_common.Subscription, error) {
+	q := "UPDATE \n" +
+		"    subscriptions \n" +
+		"SET \n" +
+		"    leased_until = NOW() + $2 * INTERVAL '1 second' \n" +
+		"WHERE \n" +
+		"    id = $1 \n" +
+		"    AND (leased_until IS NULL OR leased_until < NOW()) \n" +
+		"RETURNING \n" +
+		subscriptionColumns
+
+	subscription, scanErr := scanSubscription(DB.QueryRow(q, id, lease.Seconds()))
+	if scanErr == sql.ErrNoRows {
+		return nil, nil
+	} else if scanErr != nil {
+		return nil, errors.Errorf("unable to lease subscription: %s", scanErr)
+	}
+
+	return &subscription, nil
+}
+
+// AdvanceSubscription records that the leased subscription with the given ID was notified up to version, and extends
+// its lease.
+func AdvanceSubscription(id int64, version int64, lease time.Duration) (err error) {
+	q := "UPDATE \n" +
+		"    subscriptions \n" +
+		"SET \n" +
+		"    last_version = $2, \n" +
+		"    leased_until = NOW() + $3 * INTERVAL '1 second' \n" +
+		"WHERE \n" +
+		"    id = $1 "
+
+	_, execErr := DB.Exec(q, id, version, lease.Seconds())
+	if execErr != nil {
+		err = errors.Errorf("unable to advance subscription: %s", execErr)
+	}
+
+	return
+}
+
+// ReleaseSubscription gives up the lease of the subscription with the given ID.
+func ReleaseSubscription(id int64) (err error) {
+	q := "UPDATE \n" +
+		"    subscriptions \n" +
+		"SET \n" +
+		"    leased_until = NULL \n" +
+		"WHERE \n" +
+		"    id = $1 "
+
+	_, execErr := DB.Exec(q, id)
+	if execErr != nil {
+		err = errors.Errorf("unable to release subscription: %s", execErr)
+	}
+
+	return
+}
+
+// InsertDeadLetter records a notification that could not be delivered, and why.
+func InsertDeadLetter(notification sls_common.Notification, reason string) (err error) {
+	q := "INSERT INTO \n" +
+		"    subscription_dead_letters (subscription_id, version, error, notification) \n" +
+		"VALUES \n" +
+		"    ($1, $2, $3, $4) "
+
+	document, err := json.Marshal(notification)
+	if err != nil {
+		err = errors.Errorf("unable to marshal notification: %s", err)
+		return
+	}
+
+	_, execErr := DB.Exec(q, notification.SubscriptionID, notification.Version, reason, document)
+	if execErr != nil {
+		err = errors.Errorf("unable to insert dead letter: %s", execErr)
+	}
+
+	return
+}
+
+// GetDeadLetters returns the notifications that could not be delivered to the subscription with the given ID, oldest
+// first.
+func GetDeadLetters(id int64) (deadLetters []sls_common.DeadLetter, err error) {
+	q := "SELECT \n" +
+		"    id, \n" +
+		"    timestamp, \n" +
+		"    error, \n" +
+		"    notification \n" +
+		"FROM \n" +
+		"    subscription_dead_letters \n" +
+		"WHERE \n" +
+		"    subscription_id = $1 \n" +
+		"ORDER BY \n" +
+		"    id "
+
+	rows, queryErr := DB.Query(q, id)
+	if queryErr != nil {
+		err = errors.Errorf("unable to query dead letters: %s", queryErr)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deadLetter sls_common.DeadLetter
+		var timestamp time.Time
+		var notification []byte
+
+		scanErr := rows.Scan(&deadLetter.ID,
+			&timestamp,
+			&deadLetter.Error,
+			&notification)
+		if scanErr != nil {
+			err = errors.Errorf("unable to scan dead letter row: %s", scanErr)
+			return
+		}
+
+		deadLetter.Timestamp = timestamp.Unix()
+		deadLetter.TimestampTime = timestamp.String()
+
+		err = json.Unmarshal(notification, &deadLetter.Notification)
+		if err != nil {
+			err = errors.Errorf("unable to unmarshal dead letter: %s", err)
+			return
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = errors.Errorf("unable to read dead letters: %s", err)
+	}
+
+	return
+}
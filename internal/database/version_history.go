@@ -24,13 +24,43 @@ package database
 
 import (
 	"database/sql"
+	"strconv"
 
 	"github.com/pkg/errors"
 )
 
+// versionLock is the advisory lock a transaction making a version holds until it commits or rolls back. Versions are
+// made one at a time so they commit in order, and once a version can be seen so can every version before it.
+const versionLock = 0x534c53
+
+// beginVersion begins a transaction that is going to make a version. It waits for versionLock before doing anything
+// else, so it can't be left holding locks another transaction making a version is waiting on.
+func beginVersion() (*sql.Tx, error) {
+	trans, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = trans.Exec("SELECT pg_advisory_xact_lock($1)", versionLock)
+	if err != nil {
+		_ = trans.Rollback()
+		return nil, err
+	}
+
+	return trans, nil
+}
+
+// IncrementVersion makes a new version as part of trans, which should have been begun with beginVersion.
 func IncrementVersion(trans *sql.Tx, updatedEntity string) (id int64, err error) {
 	var version int64
 
+	// Already held if trans came from beginVersion, taken again so versions stay in order if it didn't.
+	_, lockErr := trans.Exec("SELECT pg_advisory_xact_lock($1)", versionLock)
+	if lockErr != nil {
+		err = errors.Errorf("unable to lock versions: %s", lockErr)
+		return
+	}
+
 	q := "INSERT INTO " +
 		"    version_history (updated_entity) " +
 		"VALUES " +
@@ -48,6 +78,13 @@ func IncrementVersion(trans *sql.Tx, updatedEntity string) (id int64, err error)
 
 	result.Scan()
 
+	// The revisions of anything this transaction deletes are recorded against this version, see migration 7.
+	_, transErr = trans.Exec("SELECT set_config('sls.version', $1, true)", strconv.FormatInt(version, 10))
+	if transErr != nil {
+		err = errors.Errorf("unable to set version: %s", transErr)
+		return
+	}
+
 	return version, err
 }
 
@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidNetworkProfile = errors.New("network profile is invalid")
+
+const (
+	// NetworkProfileCompute are the networks of a cabinet for compute nodes and everything that isn't a node.
+	NetworkProfileCompute = "cn"
+	// NetworkProfileManagement are the networks of a cabinet for management nodes.
+	NetworkProfileManagement = "ncn"
+)
+
+// networkProfile returns the profile in the Networks of a cabinet for a node with the given Role.
+func networkProfile(role string) string {
+	if strings.EqualFold(role, "Management") {
+		return NetworkProfileManagement
+	}
+	return NetworkProfileCompute
+}
+
+// matchSubnet returns the name of the network and the subnet among networks that has the given CIDR. When more than
+// one does, one of a network called name, or name followed by an underscore such as HMN_MTN, wins. It returns nil if
+// there is no such subnet.
+func matchSubnet(name string, cidr string, networks []sls_common.Network) (string, *sls_common.IPV4Subnet) {
+	var matchNetwork string
+	var match *sls_common.IPV4Subnet
+	for _, network := range networks {
+		var properties sls_common.NetworkExtraProperties
+		networkBytes, err := json.Marshal(network.ExtraPropertiesRaw)
+		if err != nil || json.Unmarshal(networkBytes, &properties) != nil {
+			continue
+		}
+
+		preferred := strings.EqualFold(network.Name, name) ||
+			strings.HasPrefix(strings.ToUpper(network.Name), strings.ToUpper(name)+"_")
+		for i, subnet := range properties.Subnets {
+			if subnet.CIDR != cidr {
+				continue
+			}
+			if match == nil || preferred {
+				matchNetwork, match = network.Name, &properties.Subnets[i]
+			}
+			if preferred {
+				return matchNetwork, match
+			}
+		}
+	}
+
+	return matchNetwork, match
+}
+
+// cabinetNetworks returns the networks of profile in the Networks of cabinet, ordered by name, each along with the
+// subnet of networks it matches.
+func cabinetNetworks(cabinet sls_common.GenericHardware, profile string,
+	networks []sls_common.Network) []sls_common.HardwareNetwork {
+	found := []sls_common.HardwareNetwork{}
+
+	var properties sls_common.ComptypeCabinet
+	propertiesBytes, err := json.Marshal(cabinet.ExtraPropertiesRaw)
+	if err != nil || json.Unmarshal(propertiesBytes, &properties) != nil {
+		return found
+	}
+
+	for name, cabinetNetwork := range properties.Networks[profile] {
+		network := sls_common.HardwareNetwork{Name: name, CabinetNetworks: cabinetNetwork}
+		network.Network, network.Subnet = matchSubnet(name, cabinetNetwork.CIDR, networks)
+		if network.Subnet != nil {
+			if network.Gateway == "" && network.Subnet.Gateway != nil {
+				network.Gateway = network.Subnet.Gateway.String()
+			}
+			if network.VLan == 0 {
+				network.VLan = int(network.Subnet.VlanID)
+			}
+		}
+
+		found = append(found, network)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+
+	return found
+}
+
+// GetXnameNetworks returns the networks xname is on according to the Networks of the cabinet it is in. Unless profile
+// is given, a node and the hardware below it get the "ncn" networks if its Role is Management and everything else
+// gets the "cn" networks. It returns nil if there is no such xname.
+func GetXnameNetworks(xname string, profile string) (*sls_common.HardwareNetworks, error) {
+	if profile != "" && profile != NetworkProfileCompute && profile != NetworkProfileManagement {
+		return nil, InvalidNetworkProfile
+	}
+
+	hardware, err := GetXname(xname)
+	if hardware == nil || err != nil {
+		return nil, err
+	}
+
+	var node, cabinet string
+	for ancestor := hardware.Xname; ancestor != ""; ancestor = base.GetHMSCompParent(ancestor) {
+		switch base.GetHMSType(ancestor) {
+		case base.Node:
+			if node == "" {
+				node = ancestor
+			}
+		case base.Cabinet:
+			cabinet = ancestor
+		}
+		if cabinet != "" {
+			break
+		}
+	}
+
+	if profile == "" {
+		var properties struct {
+			Role string
+		}
+		if node != "" {
+			nodeHardware, err := GetXname(node)
+			if err != nil {
+				return nil, err
+			}
+			if nodeHardware != nil {
+				propertiesBytes, err := json.Marshal(nodeHardware.ExtraPropertiesRaw)
+				if err == nil {
+					_ = json.Unmarshal(propertiesBytes, &properties)
+				}
+			}
+		}
+		profile = networkProfile(properties.Role)
+	}
+
+	result := sls_common.HardwareNetworks{
+		Xname:    hardware.Xname,
+		Cabinet:  cabinet,
+		Profile:  profile,
+		Networks: []sls_common.HardwareNetwork{},
+	}
+	if cabinet == "" {
+		return &result, nil
+	}
+
+	cabinetHardware, err := GetXname(cabinet)
+	if cabinetHardware == nil || err != nil {
+		return &result, err
+	}
+
+	networks, err := database.GetAllNetworks()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Name < networks[j].Name
+	})
+
+	result.Networks = cabinetNetworks(*cabinetHardware, profile, networks)
+	return &result, nil
+}
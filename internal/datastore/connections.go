@@ -0,0 +1,117 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"encoding/json"
+	"sort"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/search"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+// connectorTypes are the types of hardware that are switch ports. Their NodeNics are what is plugged into them.
+var connectorTypes = []sls_common.HMSStringType{sls_common.MgmtSwitchConnector, sls_common.HSNConnector}
+
+// connectsTo reports whether device, which is plugged into a port, belongs to xname. That is device is xname or below
+// it, or xname is a node and device is its BMC, which is how nodes are usually cabled to the management network.
+func connectsTo(device string, xname string) bool {
+	if device == xname || isBelow(device, xname) {
+		return true
+	}
+
+	return base.GetHMSType(xname) == base.Node && device == base.GetHMSCompParent(xname)
+}
+
+// connections returns the connections of xname among connectors ordered by port. A port that is xname or below it
+// comes with everything plugged into it, any other port only with the devices that belong to xname, see connectsTo.
+func connections(xname string, connectors []sls_common.GenericHardware) []sls_common.HardwareConnection {
+	found := []sls_common.HardwareConnection{}
+	for _, connector := range connectors {
+		isPort := connector.Xname == xname || isBelow(connector.Xname, xname)
+
+		devices := []string{}
+		for _, device := range sls_common.LinkedXnames(connector) {
+			device = base.NormalizeHMSCompID(device)
+			if isPort || connectsTo(device, xname) {
+				devices = append(devices, device)
+			}
+		}
+		if !isPort && len(devices) == 0 {
+			continue
+		}
+
+		connection := sls_common.HardwareConnection{
+			Switch:  connector.Parent,
+			Port:    connector.Xname,
+			Type:    connector.Type,
+			Devices: devices,
+		}
+		if connection.Switch == "" {
+			connection.Switch = base.GetHMSCompParent(connector.Xname)
+		}
+
+		var properties struct {
+			VendorName string
+		}
+		propertiesBytes, err := json.Marshal(connector.ExtraPropertiesRaw)
+		if err == nil && json.Unmarshal(propertiesBytes, &properties) == nil {
+			connection.VendorName = properties.VendorName
+		}
+
+		found = append(found, connection)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return lessXname(found[i].Port, found[j].Port)
+	})
+
+	return found
+}
+
+// GetXnameConnections returns the switch ports that xname is plugged into, going by the NodeNics of every
+// MgmtSwitchConnector and HSNConnector, along with the ports of xname itself if it is a switch. It returns nil if
+// there is no such xname.
+func GetXnameConnections(xname string) (*sls_common.HardwareConnections, error) {
+	hardware, err := GetXname(xname)
+	if hardware == nil || err != nil {
+		return nil, err
+	}
+
+	filter := search.Comparison{Field: "type", Op: search.In}
+	for _, connectorType := range connectorTypes {
+		filter.Values = append(filter.Values, string(connectorType))
+	}
+
+	connectors, err := database.SearchGenericHardwareFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sls_common.HardwareConnections{
+		Xname:       hardware.Xname,
+		Connections: connections(hardware.Xname, connectors),
+	}, nil
+}
@@ -23,6 +23,7 @@
 package datastore
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
 	"strings"
@@ -31,6 +32,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/patch"
 )
 
 const xnameKeyPrefix = "/sls/xnames/"
@@ -74,6 +76,19 @@ func GetXname(xname string) (*sls_common.GenericHardware, error) {
 	return &res, err
 }
 
+/*
+GetXnameAndVersion is GetXname, but also returns the version the object was
+last updated in so callers can tell whether it has changed since.
+*/
+func GetXnameAndVersion(xname string) (*sls_common.GenericHardware, int64, error) {
+	xname = base.NormalizeHMSCompID(xname)
+	res, version, err := database.GetGenericHardwareAndVersion(xname)
+	if err == database.NoSuch {
+		return nil, 0, nil
+	}
+	return &res, version, err
+}
+
 /*
 Reduce all xnames to sane, normalized values
 */
@@ -184,24 +199,15 @@ func validateFields(obj sls_common.GenericHardware) error {
 		err := fmt.Errorf("%s: An %s object cannot be stored in SLS", obj.GetXname(), obj.GetType())
 		return err
 
-	/* Items in this section have specific properties that require validation */
+	/* Items in this section have ExtraProperties, see validateExtraProperties */
+	case sls_common.Cabinet:
+	case sls_common.ChassisBMC:
 	case sls_common.NodePowerConnector:
-		//PoweredBy
 	case sls_common.HSNConnector:
-		// NodeNics
 	case sls_common.MgmtSwitch:
-		//IP6addr
-		//IP4addr
-		//Username
-		//Password
 	case sls_common.MgmtSwitchConnector:
-		// NodeNics
-		// VendorName
+	case sls_common.MgmtHLSwitch:
 	case sls_common.RouterBMC:
-		//IP6addr
-		//IP4addr
-		//Username
-		//Password
 	case sls_common.RouterBMCNic:
 	case sls_common.CabinetPDUNic:
 	case sls_common.NodeBMCNic:
@@ -214,19 +220,16 @@ func validateFields(obj sls_common.GenericHardware) error {
 	case sls_common.CabinetPDUPowerConnector:
 	case sls_common.CDUMgmtSwitch:
 
-	/* These all have no specific properties that need validation */
-	/* for these, do nothing */
+	/* These all have no ExtraProperties */
 	case sls_common.CDU:
 	case sls_common.CEC:
 	case sls_common.CMMFpga:
 	case sls_common.CMMRectifier:
-	case sls_common.Cabinet:
 	case sls_common.CabinetCDU:
 	case sls_common.CabinetPDU:
 	case sls_common.CabinetPDUController:
 	case sls_common.CabinetPDUOutlet:
 	case sls_common.Chassis:
-	case sls_common.ChassisBMC:
 	case sls_common.HSNAsic:
 	case sls_common.HSNBoard:
 	case sls_common.HSNConnectorPort:
@@ -247,52 +250,211 @@ func validateFields(obj sls_common.GenericHardware) error {
 		return err
 	}
 
-	return nil
+	return checkExtraProperties(obj)
 }
 
 /*
 SetXname updates a specified xname with new or updated properties
 */
 func SetXname(xname string, obj sls_common.GenericHardware) error {
+	_, err := SetXnameIfMatch(xname, obj, database.Precondition{})
+	return err
+}
+
+/*
+SetXnameIfMatch is SetXname, but the write only happens if the currently
+stored object satisfies precondition.  It returns the version the object
+was written in.
+*/
+func SetXnameIfMatch(xname string, obj sls_common.GenericHardware, precondition database.Precondition) (int64, error) {
 	// Setup: make sure all data is clean
 	obj, err := normalizeFields(obj)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = validateFields(obj)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("%w: %s", InvalidHardware, err)
 	}
 
-	// check if xname exists
-	_, err = database.GetGenericHardwareFromXname(obj.Xname)
-	if err != nil && err != database.NoSuch {
-		return err
-	} else if err == database.NoSuch {
-		err = database.InsertGenericHardware(obj)
-	} else {
-		err = database.UpdateGenericHardware(obj)
+	err = checkReferences(obj, map[string]bool{obj.Xname: true}, true)
+	if err != nil {
+		return 0, err
 	}
 
-	// TODO If this is a connector object, make sure to update the peer (old and new) as well.
+	// Insert or update, depending on whether the xname exists.  The database
+	// updates the peers (old and new) of connectors and NICs as well.
+	return database.SetGenericHardware(obj, precondition)
+}
 
-	return err
+/*
+SetXnames creates or updates many hardware objects at once.  Every object
+goes through normalizeFields and validateFields first and gets its own
+result.  With atomic set nothing is written unless every object is valid
+and can be stored, otherwise the valid objects are written and the rest
+are reported.  Either way everything that is written shares one version,
+which is returned (0 if nothing was written).
+*/
+func SetXnames(objs []sls_common.GenericHardware, atomic bool) ([]sls_common.BulkHardwareResult, int64, error) {
+	results := make([]sls_common.BulkHardwareResult, len(objs))
+
+	var valid []sls_common.GenericHardware
+	var validIndexes []int
+	seen := make(map[string]int)
+	batch := xnameSet(objs)
+	for i, obj := range objs {
+		results[i].Xname = obj.Xname
+
+		obj, err := normalizeFields(obj)
+		if err == nil {
+			err = validateFields(obj)
+		}
+		if err == nil {
+			err = checkReferences(obj, batch, true)
+		}
+		if err == nil && obj.Class == "" {
+			err = fmt.Errorf("%s: missing Class field", obj.Xname)
+		}
+		if err == nil {
+			if first, ok := seen[obj.Xname]; ok {
+				err = fmt.Errorf("%s: duplicate of item %d", obj.Xname, first)
+			}
+		}
+		if err != nil {
+			results[i].Status = sls_common.BulkHardwareInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+
+		seen[obj.Xname] = i
+		results[i].Xname = obj.Xname
+		valid = append(valid, obj)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if atomic && len(valid) != len(objs) {
+		for _, i := range validIndexes {
+			results[i].Status = sls_common.BulkHardwareNotApplied
+		}
+		return results, 0, InvalidHardware
+	}
+
+	dbResults, version, err := database.SetGenericHardwareBulk(valid, atomic)
+	for j, dbResult := range dbResults {
+		i := validIndexes[j]
+		switch {
+		case dbResult.Err != nil:
+			results[i].Status = sls_common.BulkHardwareFailed
+			results[i].Error = dbResult.Err.Error()
+		case !dbResult.Attempted || version == 0:
+			results[i].Status = sls_common.BulkHardwareNotApplied
+		case dbResult.Created:
+			results[i].Status = sls_common.BulkHardwareCreated
+		default:
+			results[i].Status = sls_common.BulkHardwareUpdated
+		}
+	}
+
+	return results, version, err
+}
+
+/*
+PatchXname applies a JSON Merge Patch or a JSON Patch (chosen by contentType)
+to the hardware with the given xname.  Only the fields named in the patch
+change, everything else keeps its stored value.  The patched object goes
+through normalizeFields and validateFields like any other write.  Nothing
+is written unless the stored object satisfies precondition.
+*/
+func PatchXname(xname string, contentType string, patchBody []byte, precondition database.Precondition) (
+	sls_common.GenericHardware, int64, error) {
+	xname = base.NormalizeHMSCompID(xname)
+
+	return database.PatchGenericHardware(xname, precondition, func(obj sls_common.GenericHardware) (sls_common.GenericHardware, error) {
+		// These are computed by the database, don't let them take part in the patch.
+		obj.Children = nil
+		obj.LastUpdated = 0
+		obj.LastUpdatedTime = ""
+		obj.VaultData = nil
+
+		doc, err := json.Marshal(obj)
+		if err != nil {
+			return obj, err
+		}
+
+		patchedDoc, err := patch.Apply(contentType, doc, patchBody)
+		if err != nil {
+			return obj, err
+		}
+
+		var patched sls_common.GenericHardware
+		err = json.Unmarshal(patchedDoc, &patched)
+		if err != nil {
+			return obj, fmt.Errorf("%w: patched object is not valid: %s", patch.InvalidPatch, err)
+		}
+		if base.NormalizeHMSCompID(patched.Xname) != xname {
+			return obj, fmt.Errorf("%w: cannot change Xname from %s to %s", patch.InvalidPatch, xname,
+				patched.Xname)
+		}
+
+		patched, err = normalizeFields(patched)
+		if err != nil {
+			return obj, err
+		}
+
+		err = validateFields(patched)
+		if err != nil {
+			return obj, fmt.Errorf("%w: %s", InvalidHardware, err)
+		}
+
+		err = checkReferences(patched, map[string]bool{xname: true}, true)
+		if err != nil {
+			return obj, err
+		}
+
+		return patched, nil
+	})
 }
 
 /*
 DeleteXname removes hardware witht he appropriate name from the datastore.
-It handles updating the parent and any peers.
+It handles updating the parent and any peers, which no longer refer to it.
 */
 func DeleteXname(xname string) error {
-	// check if xname exists
-	_, err := database.GetGenericHardwareFromXname(base.NormalizeHMSCompID(xname))
-	if err != nil {
-		return err
-	}
+	return DeleteXnameIfMatch(xname, database.Precondition{})
+}
+
+/*
+DeleteXnameIfMatch is DeleteXname, but the object is only removed if it
+satisfies precondition.
+*/
+func DeleteXnameIfMatch(xname string, precondition database.Precondition) error {
 	gh := sls_common.GenericHardware{}
 	gh.Xname = base.NormalizeHMSCompID(xname)
-	return database.DeleteGenericHardware(gh)
+	return database.DeleteGenericHardwareIfMatch(gh, precondition)
+}
+
+/*
+DeleteXnameTree removes xname and all of its descendants in one
+transaction, as long as xname satisfies precondition.  The result lists
+what was removed and the references other hardware had to it, which are
+removed as well.  With dryRun nothing is removed, the result is what would
+have happened.
+*/
+func DeleteXnameTree(xname string, precondition database.Precondition, dryRun bool) (sls_common.HardwareDeleteResult, error) {
+	result := sls_common.HardwareDeleteResult{DryRun: dryRun}
+
+	deleted, references, err := database.DeleteGenericHardwareTree(base.NormalizeHMSCompID(xname), precondition, dryRun)
+	if err != nil {
+		return result, err
+	}
+
+	result.Xnames = deleted
+	result.References = references
+	if result.References == nil {
+		result.References = []sls_common.HardwareReference{}
+	}
+	return result, nil
 }
 
 /*
@@ -23,8 +23,11 @@
 package datastore
 
 import (
+	"errors"
 	"log"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
@@ -33,6 +36,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/schema"
 )
 
 type DatastoreTestSuite struct {
@@ -422,6 +426,393 @@ func (suite *DatastoreTestSuite) Test_DeleteXname() {
 
 }
 
+func (suite *DatastoreTestSuite) Test_validateExtraProperties() {
+	hardware := func(xname string, properties interface{}) sls_common.GenericHardware {
+		hmsType := base.GetHMSType(xname)
+		return sls_common.GenericHardware{
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Type:               sls_common.HMSTypeToHMSStringType(hmsType),
+			Class:              sls_common.ClassRiver,
+			TypeString:         hmsType,
+			ExtraPropertiesRaw: properties,
+		}
+	}
+
+	valid := []sls_common.GenericHardware{
+		hardware("x1000c0s0b0n0", nil),
+		hardware("x1000c0s0b0n0", sls_common.ComptypeNode{NID: 1, Role: "Compute"}),
+		hardware("x1000c0s0b0n0", map[string]interface{}{"NID": 1, "Aliases": []string{"nid000001"}}),
+		hardware("x1000c0w1j1", sls_common.ComptypeMgmtSwitchConnector{NodeNics: []string{}, VendorName: "1/1/1"}),
+		hardware("x1000c0s0v1", map[string]interface{}{"PoweredBy": []string{"x1000m0p0v1"}}),
+		hardware("x1000c0", map[string]interface{}{}),
+		hardware("x1000", map[string]interface{}{
+			"Networks": map[string]interface{}{"cn": map[string]interface{}{"HMN": map[string]interface{}{
+				"CIDR": "10.254.0.0/22", "VLan": 1,
+			}}},
+		}),
+	}
+	for _, obj := range valid {
+		suite.NoError(validateExtraProperties(obj), "%s: %v", obj.Xname, obj.ExtraPropertiesRaw)
+	}
+
+	invalid := []sls_common.GenericHardware{
+		hardware("x1000c0s0b0n0", map[string]interface{}{"NID": "abc"}),
+		hardware("x1000c0s0b0n0", map[string]interface{}{"nid": 1}),
+		hardware("x1000c0s0b0n0", []string{"NID"}),
+		hardware("x1000c0w1j1", map[string]interface{}{"VendorName": "1/1/1"}),
+		hardware("x1000c0w1j1", map[string]interface{}{"NodeNics": nil}),
+		hardware("x1000c0s0v1", nil),
+		hardware("x1000c0", map[string]interface{}{"NID": 1}),
+		hardware("x1000", map[string]interface{}{
+			"Networks": map[string]interface{}{"cn": map[string]interface{}{"HMN": map[string]interface{}{
+				"CIDR": "10.254.0.0/22", "Bogus": 1,
+			}}},
+		}),
+	}
+	for _, obj := range invalid {
+		err := validateExtraProperties(obj)
+		suite.True(errors.Is(err, InvalidExtraProperties), "%s: %v: %v", obj.Xname, obj.ExtraPropertiesRaw, err)
+	}
+
+	// Lenient mode only logs the problem
+	suite.Require().NoError(SetValidationMode(ValidationLenient))
+	suite.NoError(checkExtraProperties(invalid[0]))
+	suite.Require().NoError(SetValidationMode(ValidationStrict))
+	suite.Error(checkExtraProperties(invalid[0]))
+	suite.Require().NoError(SetValidationMode(ValidationLenient))
+
+	suite.Equal(InvalidValidationMode, SetValidationMode("sloppy"))
+}
+
+// sampleValue returns a value that s accepts.
+func sampleValue(s *schema.Schema, definitions map[string]*schema.Schema) interface{} {
+	if s.Ref != "" {
+		return sampleValue(definitions[strings.TrimPrefix(s.Ref, "#/definitions/")], definitions)
+	}
+
+	typ := s.Type
+	if types, ok := typ.([]string); ok {
+		typ = types[0]
+	}
+	switch typ {
+	case "string":
+		return "x1000"
+	case "integer":
+		return 1
+	case "boolean":
+		return true
+	case "array":
+		return []interface{}{sampleValue(s.Items, definitions)}
+	case "object":
+		value := make(map[string]interface{})
+		for name, property := range s.Properties {
+			value[name] = sampleValue(property, definitions)
+		}
+		if additional, ok := s.AdditionalProperties.(*schema.Schema); ok {
+			value["key"] = sampleValue(additional, definitions)
+		}
+		return value
+	}
+
+	return nil
+}
+
+func (suite *DatastoreTestSuite) Test_SchemasMatchValidation() {
+	for name, s := range Schemas() {
+		hmsType := sls_common.HMSStringType(name)
+		if validateType(hmsType) != nil {
+			continue
+		}
+
+		obj := sls_common.GenericHardware{Xname: "x1000", Type: hmsType}
+		extraProperties := s.Properties["ExtraProperties"]
+
+		// Everything the schema describes is accepted
+		properties := sampleValue(extraProperties, s.Definitions).(map[string]interface{})
+		obj.ExtraPropertiesRaw = properties
+		suite.NoError(validateExtraProperties(obj), "%s: %v", name, properties)
+
+		// So are just the required properties
+		required := make(map[string]interface{})
+		for _, property := range extraProperties.Required {
+			required[property] = properties[property]
+		}
+		obj.ExtraPropertiesRaw = required
+		suite.NoError(validateExtraProperties(obj), "%s: %v", name, required)
+
+		// But nothing else
+		for _, property := range extraProperties.Required {
+			delete(properties, property)
+			obj.ExtraPropertiesRaw = properties
+			suite.Error(validateExtraProperties(obj), "%s: missing %s", name, property)
+			properties[property] = required[property]
+		}
+
+		properties["Bogus"] = 1
+		obj.ExtraPropertiesRaw = properties
+		suite.Error(validateExtraProperties(obj), "%s: %v", name, properties)
+	}
+}
+
+func (suite *DatastoreTestSuite) Test_SetXnames() {
+	node := func(xname string, nid int) sls_common.GenericHardware {
+		return sls_common.GenericHardware{
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Type:               sls_common.Node,
+			Class:              sls_common.ClassMountain,
+			TypeString:         base.Node,
+			ExtraPropertiesRaw: sls_common.ComptypeNode{NID: nid, Role: "Compute"},
+		}
+	}
+	invalid := node("x1000c0s0b0n1", 91002)
+	invalid.Type = sls_common.Cabinet
+
+	objs := []sls_common.GenericHardware{
+		node("x1000c0s0b0n0", 91001),
+		invalid,
+		node("x1000c0s0b0n0", 91003),
+	}
+
+	// All or nothing, so nothing is written
+	results, version, err := SetXnames(objs, true)
+	suite.Equal(InvalidHardware, err)
+	suite.Equal(int64(0), version)
+	suite.Equal(sls_common.BulkHardwareNotApplied, results[0].Status)
+	suite.Equal(sls_common.BulkHardwareInvalid, results[1].Status)
+	suite.Equal(sls_common.BulkHardwareInvalid, results[2].Status)
+
+	res, err := GetXname("x1000c0s0b0n0")
+	suite.NoError(err)
+	suite.Nil(res)
+
+	// Best effort, so the first node is written anyway
+	results, version, err = SetXnames(objs, false)
+	suite.NoError(err)
+	suite.NotEqual(int64(0), version)
+	suite.Equal(sls_common.BulkHardwareCreated, results[0].Status)
+	suite.Equal(sls_common.BulkHardwareInvalid, results[1].Status)
+	suite.Equal(sls_common.BulkHardwareInvalid, results[2].Status)
+
+	res, err = GetXname("x1000c0s0b0n0")
+	suite.NoError(err)
+	suite.NotNil(res)
+
+	// Writing it again in bulk is an update
+	results, _, err = SetXnames(objs[:1], true)
+	suite.NoError(err)
+	suite.Equal(sls_common.BulkHardwareUpdated, results[0].Status)
+
+	suite.NoError(DeleteXname("x1000c0s0b0n0"))
+}
+
+func (suite *DatastoreTestSuite) Test_lessXname() {
+	ordered := []string{
+		"x9",
+		"x1000c0s0b0n0",
+		"x1000c0s0b0n1",
+		"x1000c0s0b1n0",
+		"x1000c0s1b0n0",
+		"x1000c0s9b0n0",
+		"x1000c0s10b0n0",
+		"x1000c1",
+		"x1000c1s0b0n0",
+		"x1001",
+		"x3000c0s1b0n0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			suite.Equal(i < j, lessXname(ordered[i], ordered[j]), "%s < %s", ordered[i], ordered[j])
+		}
+	}
+}
+
+func (suite *DatastoreTestSuite) Test_nidMap() {
+	m := nidMap([]sls_common.NodeNID{
+		{NID: 0, Xname: "x1000c0s10b0n0"},
+		{NID: 0, Xname: "x1000c0s2b0n0"},
+		{NID: 1, Xname: "x1000c0s0b0n0"},
+		{NID: 4, Xname: "x1000c0s0b0n1"},
+		{NID: 4, Xname: "x1000c0s1b0n0"},
+		{NID: 5, Xname: "x1000c0s1b0n1"},
+		{NID: 9, Xname: "x1000c0s3b0n0"},
+	})
+
+	suite.Len(m.NIDs, 5)
+	suite.Equal([]sls_common.NIDRange{{First: 2, Last: 3}, {First: 6, Last: 8}}, m.Gaps)
+	suite.Equal([]int{4}, m.Duplicates)
+	suite.Equal([]string{"x1000c0s2b0n0", "x1000c0s10b0n0"}, m.Unassigned)
+}
+
+func (suite *DatastoreTestSuite) Test_connections() {
+	connector := func(xname string, hmsType sls_common.HMSStringType, properties interface{}) sls_common.GenericHardware {
+		return sls_common.GenericHardware{
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Type:               hmsType,
+			ExtraPropertiesRaw: properties,
+		}
+	}
+
+	connectors := []sls_common.GenericHardware{
+		connector("x3000c0w22j10", sls_common.MgmtSwitchConnector,
+			sls_common.ComptypeMgmtSwitchConnector{NodeNics: []string{"x3000c0s7b0"}, VendorName: "1/1/10"}),
+		connector("x3000c0w22j9", sls_common.MgmtSwitchConnector,
+			sls_common.ComptypeMgmtSwitchConnector{NodeNics: []string{"X3000c0s9b0", "x3000c0s7b0n0i0"}, VendorName: "1/1/9"}),
+		connector("x3000c0w22j11", sls_common.MgmtSwitchConnector,
+			sls_common.ComptypeMgmtSwitchConnector{NodeNics: []string{}, VendorName: "1/1/11"}),
+		connector("x1000c0r1j1", sls_common.HSNConnector,
+			sls_common.ComptypeHSNConnector{NodeNics: []string{"x1000c0s0b0n0h0", "x1000c0s0b0n1h0"}}),
+	}
+
+	// A switch has all of its ports, in order, with everything plugged into them
+	suite.Equal([]sls_common.HardwareConnection{
+		{Switch: "x3000c0w22", Port: "x3000c0w22j9", Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/9",
+			Devices: []string{"x3000c0s9b0", "x3000c0s7b0n0i0"}},
+		{Switch: "x3000c0w22", Port: "x3000c0w22j10", Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/10",
+			Devices: []string{"x3000c0s7b0"}},
+		{Switch: "x3000c0w22", Port: "x3000c0w22j11", Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/11",
+			Devices: []string{}},
+	}, connections("x3000c0w22", connectors))
+
+	// A node lands on the ports of its NICs and of its BMC
+	suite.Equal([]sls_common.HardwareConnection{
+		{Switch: "x3000c0w22", Port: "x3000c0w22j9", Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/9",
+			Devices: []string{"x3000c0s7b0n0i0"}},
+		{Switch: "x3000c0w22", Port: "x3000c0w22j10", Type: sls_common.MgmtSwitchConnector, VendorName: "1/1/10",
+			Devices: []string{"x3000c0s7b0"}},
+	}, connections("x3000c0s7b0n0", connectors))
+	suite.Len(connections("x3000c0s7b0n1", connectors), 1)
+
+	// HSN cabling works the same way
+	suite.Equal([]sls_common.HardwareConnection{
+		{Switch: "x1000c0r1", Port: "x1000c0r1j1", Type: sls_common.HSNConnector,
+			Devices: []string{"x1000c0s0b0n1h0"}},
+	}, connections("x1000c0s0b0n1", connectors))
+	suite.Len(connections("x1000c0r1j1", connectors)[0].Devices, 2)
+
+	suite.Equal([]sls_common.HardwareConnection{}, connections("x3000c0s8b0", connectors))
+}
+
+func (suite *DatastoreTestSuite) Test_power() {
+	hardware := func(xname string, properties interface{}) sls_common.GenericHardware {
+		return sls_common.GenericHardware{
+			Parent:             base.GetHMSCompParent(xname),
+			Xname:              xname,
+			Type:               sls_common.HMSTypeToHMSStringType(base.GetHMSType(xname)),
+			ExtraPropertiesRaw: properties,
+		}
+	}
+
+	feeds := powerFeeds([]sls_common.GenericHardware{
+		hardware("x3000m0", nil),
+		hardware("x3000m0p0", nil),
+		hardware("x3000m0p0v1", nil),
+		hardware("x3000m0p0v2", nil),
+		hardware("x3000m1p0v1", nil),
+		hardware("x3000c0s7v1", sls_common.ComptypeCompmodPowerConnector{PoweredBy: []string{"x3000m0p0v1"}}),
+		hardware("x3000c0s7b0n0", sls_common.ComptypeNode{NID: 1, Role: "Compute"}),
+		hardware("x3000c0s9v1", sls_common.ComptypeCompmodPowerConnector{PoweredBy: []string{"x3000m0p0v2"}}),
+		hardware("x3000c0s9v2", sls_common.ComptypeCompmodPowerConnector{PoweredBy: []string{"X3000m1p0v1"}}),
+		hardware("x3000c0s9b0n0", nil),
+		hardware("x3000c0r15", sls_common.ComptypeRtrMod{PowerConnector: "x3000m0p0v2"}),
+	})
+
+	suite.Equal([]string{"x3000c0s9v1", "x3000c0s9v2"}, feeds["x3000c0s9"])
+	suite.Equal([]string{"x3000m0p0v2"}, feeds["x3000c0s9v1"])
+
+	// A node goes all the way back to the PDU controller, through the slot that isn't stored
+	upstream := powerUpstream("x3000c0s7b0n0", feeds)
+	var chain []string
+	for _, hop := range upstream {
+		chain = append(chain, hop.Xname)
+	}
+	suite.Equal([]string{"x3000c0s7b0", "x3000c0s7", "x3000c0s7v1", "x3000m0p0v1", "x3000m0p0", "x3000m0"}, chain)
+	suite.Equal(sls_common.PowerHop{Xname: "x3000m0p0v1", Type: sls_common.CabinetPDUPowerConnector, Depth: 4,
+		PoweredBy: []string{"x3000m0p0"}}, upstream[3])
+
+	// Everything fed by the PDU loses power, apart from the blade with a second feed from another PDU
+	lost := make(map[string]int)
+	for _, hop := range powerDownstream("x3000m0p0", feeds) {
+		lost[hop.Xname] = hop.Depth
+	}
+	suite.Equal(map[string]int{
+		"x3000m0p0v1":   1,
+		"x3000m0p0v2":   1,
+		"x3000c0s7v1":   2,
+		"x3000c0s9v1":   2,
+		"x3000c0r15":    2,
+		"x3000c0s7":     3,
+		"x3000c0s7b0":   4,
+		"x3000c0s7b0n0": 5,
+	}, lost)
+
+	suite.Equal([]sls_common.PowerHop{{Xname: "x3000c0s9v2", Type: sls_common.NodePowerConnector, Depth: 1,
+		PoweredBy: []string{"x3000m1p0v1"}}}, powerDownstream("x3000m1p0v1", feeds))
+}
+
+func (suite *DatastoreTestSuite) Test_cabinetNetworks() {
+	network := func(name string, subnets ...sls_common.IPV4Subnet) sls_common.Network {
+		return sls_common.Network{
+			Name:               name,
+			ExtraPropertiesRaw: sls_common.NetworkExtraProperties{Subnets: subnets},
+		}
+	}
+	networks := []sls_common.Network{
+		network("HMN", sls_common.IPV4Subnet{Name: "network_hardware", CIDR: "10.254.0.0/17", VlanID: 4}),
+		network("HMN_RVR", sls_common.IPV4Subnet{Name: "cabinet_3000", CIDR: "10.107.0.0/22", VlanID: 1513,
+			Gateway: net.ParseIP("10.107.0.1")}),
+		network("NMN", sls_common.IPV4Subnet{Name: "network_hardware", CIDR: "10.252.0.0/17", VlanID: 2}),
+		network("MIRROR", sls_common.IPV4Subnet{Name: "cabinet_3000", CIDR: "10.106.0.0/22", VlanID: 99}),
+		network("NMN_RVR", sls_common.IPV4Subnet{Name: "cabinet_3000", CIDR: "10.106.0.0/22", VlanID: 1770}),
+		network("Custom", sls_common.IPV4Subnet{Name: "other", CIDR: "10.108.0.0/22", VlanID: 7}),
+	}
+
+	cabinet := sls_common.GenericHardware{
+		Xname: "x3000",
+		Type:  sls_common.Cabinet,
+		ExtraPropertiesRaw: sls_common.ComptypeCabinet{Networks: map[string]map[string]sls_common.CabinetNetworks{
+			"cn": {
+				"NMN": {CIDR: "10.106.0.0/22", Gateway: "10.106.0.1", VLan: 1770},
+				"HMN": {CIDR: "10.107.0.0/22"},
+			},
+			"ncn": {
+				"HMN": {CIDR: "10.108.0.0/22", VLan: 8},
+				"XYZ": {CIDR: "10.109.0.0/22"},
+			},
+		}},
+	}
+
+	found := cabinetNetworks(cabinet, NetworkProfileCompute, networks)
+	suite.Require().Len(found, 2)
+
+	// The Gateway and VLan the cabinet leaves out come from the subnet with the same CIDR
+	suite.Equal("HMN", found[0].Name)
+	suite.Equal("HMN_RVR", found[0].Network)
+	suite.Equal("10.107.0.1", found[0].Gateway)
+	suite.Equal(1513, found[0].VLan)
+	suite.Equal("cabinet_3000", found[0].Subnet.Name)
+
+	// A network named after the cabinet network wins over any other with the same CIDR
+	suite.Equal("NMN", found[1].Name)
+	suite.Equal("NMN_RVR", found[1].Network)
+	suite.Equal(1770, found[1].VLan)
+
+	// Otherwise any subnet with the CIDR will do, but the cabinet has the last word
+	found = cabinetNetworks(cabinet, NetworkProfileManagement, networks)
+	suite.Require().Len(found, 2)
+	suite.Equal("Custom", found[0].Network)
+	suite.Equal(8, found[0].VLan)
+	suite.Equal("XYZ", found[1].Name)
+	suite.Nil(found[1].Subnet)
+
+	suite.Empty(cabinetNetworks(sls_common.GenericHardware{Xname: "x3001"}, NetworkProfileCompute, networks))
+	suite.Equal(NetworkProfileManagement, networkProfile("management"))
+	suite.Equal(NetworkProfileCompute, networkProfile("Application"))
+}
+
 func (suite *DatastoreTestSuite) Test_GetNetwork() {
 	nw := sls_common.Network{
 		Name:     "HSN",
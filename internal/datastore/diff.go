@@ -0,0 +1,241 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/diff"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidDiff = errors.New("invalid diff request")
+
+// UploadVersion is what a diff calls an SLSState that was sent rather than stored.
+const UploadVersion = "upload"
+
+// diffHardware strips hardware of what isn't part of its configuration, so only real changes are reported.
+func diffHardware(hardware sls_common.GenericHardware) sls_common.GenericHardware {
+	hardware.Xname = base.NormalizeHMSCompID(hardware.Xname)
+	hardware.Parent = base.NormalizeHMSCompID(hardware.Parent)
+	hardware.TypeString = sls_common.HMSStringTypeToHMSType(hardware.Type)
+	hardware.Children = nil
+	hardware.LastUpdated = 0
+	hardware.LastUpdatedTime = ""
+	hardware.VaultData = nil
+	return hardware
+}
+
+// diffNetwork strips a network of what isn't part of its configuration, so only real changes are reported.
+func diffNetwork(network sls_common.Network) sls_common.Network {
+	network.LastUpdated = 0
+	network.LastUpdatedTime = ""
+	return network
+}
+
+func compareHardware(from []sls_common.GenericHardware, to []sls_common.GenericHardware) (
+	result sls_common.HardwareDiff, err error) {
+	result = sls_common.HardwareDiff{
+		Added:    []sls_common.GenericHardware{},
+		Removed:  []sls_common.GenericHardware{},
+		Modified: []sls_common.ObjectDiff{},
+	}
+
+	fromByXname := make(map[string]sls_common.GenericHardware, len(from))
+	for _, hardware := range from {
+		hardware = diffHardware(hardware)
+		fromByXname[hardware.Xname] = hardware
+	}
+
+	toByXname := make(map[string]sls_common.GenericHardware, len(to))
+	for _, hardware := range to {
+		hardware = diffHardware(hardware)
+		toByXname[hardware.Xname] = hardware
+
+		fromHardware, found := fromByXname[hardware.Xname]
+		if !found {
+			result.Added = append(result.Added, hardware)
+			continue
+		}
+
+		changes, err := diff.Fields(fromHardware, hardware)
+		if err != nil {
+			return result, err
+		}
+		if len(changes) > 0 {
+			result.Modified = append(result.Modified, sls_common.ObjectDiff{Name: hardware.Xname, Changes: changes})
+		}
+	}
+
+	for xname, hardware := range fromByXname {
+		if _, found := toByXname[xname]; !found {
+			result.Removed = append(result.Removed, hardware)
+		}
+	}
+
+	sort.Slice(result.Added, func(i, j int) bool { return result.Added[i].Xname < result.Added[j].Xname })
+	sort.Slice(result.Removed, func(i, j int) bool { return result.Removed[i].Xname < result.Removed[j].Xname })
+	sort.Slice(result.Modified, func(i, j int) bool { return result.Modified[i].Name < result.Modified[j].Name })
+	return result, nil
+}
+
+func compareNetworks(from []sls_common.Network, to []sls_common.Network) (result sls_common.NetworkDiff, err error) {
+	result = sls_common.NetworkDiff{
+		Added:    []sls_common.Network{},
+		Removed:  []sls_common.Network{},
+		Modified: []sls_common.ObjectDiff{},
+	}
+
+	fromByName := make(map[string]sls_common.Network, len(from))
+	for _, network := range from {
+		fromByName[network.Name] = diffNetwork(network)
+	}
+
+	toByName := make(map[string]sls_common.Network, len(to))
+	for _, network := range to {
+		network = diffNetwork(network)
+		toByName[network.Name] = network
+
+		fromNetwork, found := fromByName[network.Name]
+		if !found {
+			result.Added = append(result.Added, network)
+			continue
+		}
+
+		changes, err := diff.Fields(fromNetwork, network)
+		if err != nil {
+			return result, err
+		}
+		if len(changes) > 0 {
+			result.Modified = append(result.Modified, sls_common.ObjectDiff{Name: network.Name, Changes: changes})
+		}
+	}
+
+	for name, network := range fromByName {
+		if _, found := toByName[name]; !found {
+			result.Removed = append(result.Removed, network)
+		}
+	}
+
+	sort.Slice(result.Added, func(i, j int) bool { return result.Added[i].Name < result.Added[j].Name })
+	sort.Slice(result.Removed, func(i, j int) bool { return result.Removed[i].Name < result.Removed[j].Name })
+	sort.Slice(result.Modified, func(i, j int) bool { return result.Modified[i].Name < result.Modified[j].Name })
+	return result, nil
+}
+
+// getDiffVersion returns the version called name in a diff request, the current one if name is empty.
+func getDiffVersion(name string) (int64, error) {
+	if name == "" {
+		_, current, err := database.GetVersionRange()
+		return current, err
+	}
+
+	version, _, err := GetAsOfVersion(name, "")
+	if errors.Is(err, InvalidAsOf) {
+		return 0, fmt.Errorf("%w: %s is not a version", InvalidDiff, name)
+	}
+	return version, err
+}
+
+// getVersionState returns all hardware and networks as they were at version.
+func getVersionState(version int64) (hardware []sls_common.GenericHardware, networks []sls_common.Network, err error) {
+	hardware, err = GetAllHardwareAsOf(version)
+	if err != nil {
+		return
+	}
+	networks, err = GetAllNetworksAsOf(version)
+	return
+}
+
+/*
+GetDiff returns what changed to hardware and networks from version from to
+version to, or to the current version if to is empty.  Both have to be
+versions revisions were recorded for, or VersionUnavailable is returned.
+*/
+func GetDiff(from string, to string) (result sls_common.SLSDiff, err error) {
+	if from == "" {
+		err = fmt.Errorf("%w: from has to be given", InvalidDiff)
+		return
+	}
+
+	fromVersion, err := getDiffVersion(from)
+	if err != nil {
+		return
+	}
+	toVersion, err := getDiffVersion(to)
+	if err != nil {
+		return
+	}
+
+	fromHardware, fromNetworks, err := getVersionState(fromVersion)
+	if err != nil {
+		return
+	}
+	toHardware, toNetworks, err := getVersionState(toVersion)
+	if err != nil {
+		return
+	}
+
+	result.From = strconv.FormatInt(fromVersion, 10)
+	result.To = strconv.FormatInt(toVersion, 10)
+	result.Hardware, err = compareHardware(fromHardware, toHardware)
+	if err != nil {
+		return
+	}
+	result.Networks, err = compareNetworks(fromNetworks, toNetworks)
+	return
+}
+
+/*
+DiffState returns what would change to the current hardware and networks if
+they were replaced with state, like a loadstate of it would.
+*/
+func DiffState(state sls_common.SLSState) (result sls_common.SLSDiff, err error) {
+	current, fromHardware, fromNetworks, err := database.GetState()
+	if err != nil {
+		return
+	}
+
+	toHardware := make([]sls_common.GenericHardware, 0, len(state.Hardware))
+	for _, hardware := range state.Hardware {
+		toHardware = append(toHardware, hardware)
+	}
+	toNetworks := make([]sls_common.Network, 0, len(state.Networks))
+	for _, network := range state.Networks {
+		toNetworks = append(toNetworks, network)
+	}
+
+	result.From = strconv.FormatInt(current, 10)
+	result.To = UploadVersion
+	result.Hardware, err = compareHardware(fromHardware, toHardware)
+	if err != nil {
+		return
+	}
+	result.Networks, err = compareNetworks(fromNetworks, toNetworks)
+	return
+}
@@ -0,0 +1,126 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/events"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+// eventsBacklog is how many versions a subscriber can fall behind by before it is dropped.
+const eventsBacklog = 100
+
+var eventsMutex sync.Mutex
+var eventsBroker *events.Broker
+var eventsStop chan struct{}
+
+/*
+SubscribeEvents returns a channel receiving every version committed from now
+on, from this or any other instance of SLS, see events.Broker.  It receives 0
+if versions may have been missed, and is closed if its reader falls too far
+behind.  The first subscriber starts listening to the database.
+*/
+func SubscribeEvents() (<-chan int64, error) {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	if eventsBroker == nil {
+		stop := make(chan struct{})
+		versions, err := database.ListenForVersions(stop)
+		if err != nil {
+			return nil, err
+		}
+
+		broker := events.NewBroker()
+		go func() {
+			for version := range versions {
+				broker.Publish(version)
+			}
+			broker.Close()
+		}()
+
+		eventsBroker = broker
+		eventsStop = stop
+	}
+
+	return eventsBroker.Subscribe(eventsBacklog), nil
+}
+
+// UnsubscribeEvents stops sending versions to a channel from SubscribeEvents.
+func UnsubscribeEvents(subscriber <-chan int64) {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	if eventsBroker != nil {
+		eventsBroker.Unsubscribe(subscriber)
+	}
+}
+
+// StopEvents closes every channel from SubscribeEvents and stops listening to the database.
+func StopEvents() {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	if eventsBroker != nil {
+		close(eventsStop)
+		eventsBroker.Close()
+		eventsBroker = nil
+	}
+}
+
+/*
+GetEventsSince returns every change made after version afterVersion, and the
+current version they go up to.  afterVersion has to be one revisions were
+recorded for and no newer than the current one, or VersionUnavailable is
+returned.  Versions commit in order, so no change up to current can turn up
+later.
+*/
+func GetEventsSince(afterVersion int64, documents bool) (changes []sls_common.Event, current int64, err error) {
+	start, current, err := database.GetVersionRange()
+	if err != nil {
+		return
+	}
+	if afterVersion < start || afterVersion > current {
+		err = fmt.Errorf("%w: only changes after versions %d to %d are", VersionUnavailable, start, current)
+		return
+	}
+
+	err = database.GetEvents(afterVersion, current, documents, func(event sls_common.Event) error {
+		changes = append(changes, event)
+		return nil
+	})
+	return
+}
+
+// GetVersionEvents returns the changes made in version.
+func GetVersionEvents(version int64, documents bool) (changes []sls_common.Event, err error) {
+	err = database.GetEvents(version-1, version, documents, func(event sls_common.Event) error {
+		changes = append(changes, event)
+		return nil
+	})
+	return
+}
@@ -23,11 +23,17 @@
 package datastore
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 )
 
@@ -37,6 +43,32 @@ var InvalidXnameType = errors.New("xname type is invalid")
 var InvalidClass = errors.New("class is invalid")
 var UnsupportedType = errors.New("type can not be stored in SLS")
 var UnknownType = errors.New("type is unknown")
+var InvalidHardware = errors.New("hardware object is invalid")
+var InvalidValidationMode = errors.New("validation mode is invalid")
+
+// ValidationMode is how hardware with invalid ExtraProperties is handled when it is written.
+type ValidationMode string
+
+const (
+	// ValidationStrict rejects hardware with invalid ExtraProperties.
+	ValidationStrict ValidationMode = "strict"
+	// ValidationLenient stores hardware with invalid ExtraProperties anyway and only logs the problem, which allows
+	// legacy data to be loaded and then fixed up.
+	ValidationLenient ValidationMode = "lenient"
+)
+
+var validationMode = ValidationLenient
+
+// SetValidationMode sets how hardware with invalid ExtraProperties is handled from now on.
+func SetValidationMode(mode ValidationMode) error {
+	switch mode {
+	case ValidationStrict, ValidationLenient:
+		validationMode = mode
+		return nil
+	}
+
+	return InvalidValidationMode
+}
 
 func validateXname(xname string) error {
 	xnameType := base.GetHMSType(xname)
@@ -75,27 +107,16 @@ func validateType(typeObj sls_common.HMSStringType) error {
 	case sls_common.HMSTypeAll, sls_common.HMSTypeAllComp, sls_common.HMSTypeAllSvc, sls_common.HMSTypeInvalid, sls_common.Partition:
 		return UnsupportedType
 
-	/* Items in this section have specific properties that require validation */
-	case sls_common.CabinetPDUPowerConnector:
+	/* Items in this section have ExtraProperties, see validateExtraProperties */
+	case sls_common.Cabinet:
+	case sls_common.ChassisBMC:
 	case sls_common.NodePowerConnector:
-		//PoweredBy
 	case sls_common.HSNConnector:
-		// NodeNics
 	case sls_common.MgmtSwitch:
-		//IP6addr
-		//IP4addr
-		//Username
-		//Password
 	case sls_common.MgmtSwitchConnector:
-		// NodeNics
-		// VendorName
 	case sls_common.MgmtHLSwitch:
 	case sls_common.CDUMgmtSwitch:
 	case sls_common.RouterBMC:
-		//IP6addr
-		//IP4addr
-		//Username
-		//Password
 	case sls_common.RouterBMCNic:
 	case sls_common.CabinetPDUNic:
 	case sls_common.NodeBMCNic:
@@ -105,20 +126,18 @@ func validateType(typeObj sls_common.HMSStringType) error {
 	case sls_common.ComputeModule:
 	case sls_common.Node:
 	case sls_common.NodeBMC:
+	case sls_common.CabinetPDUPowerConnector:
 
-	/* These all have no specific properties that need validation */
-	/* for these, do nothing */
+	/* These all have no ExtraProperties */
 	case sls_common.CDU:
 	case sls_common.CEC:
 	case sls_common.CMMFpga:
 	case sls_common.CMMRectifier:
-	case sls_common.Cabinet:
 	case sls_common.CabinetCDU:
 	case sls_common.CabinetPDU:
 	case sls_common.CabinetPDUController:
 	case sls_common.CabinetPDUOutlet:
 	case sls_common.Chassis:
-	case sls_common.ChassisBMC:
 	case sls_common.HSNAsic:
 	case sls_common.HSNBoard:
 	case sls_common.HSNConnectorPort:
@@ -141,13 +160,255 @@ func validateType(typeObj sls_common.HMSStringType) error {
 	return nil
 }
 
+// validateExtraProperties checks the ExtraProperties of obj against the struct for its type in
+// sls_common.ExtraPropertiesTypes. Every property must be a field of that struct and decode into it, and the ones in
+// sls_common.ExtraPropertiesRequired must be present. Types without a struct can't have any ExtraProperties, unless
+// they are in sls_common.ExtraPropertiesUnchecked.
+func validateExtraProperties(obj sls_common.GenericHardware) error {
+	if sls_common.ExtraPropertiesUnchecked[obj.Type] {
+		return nil
+	}
+
+	var properties map[string]json.RawMessage
+	if obj.ExtraPropertiesRaw != nil {
+		raw, err := json.Marshal(obj.ExtraPropertiesRaw)
+		if err == nil {
+			err = json.Unmarshal(raw, &properties)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: ExtraProperties must be an object", InvalidExtraProperties, obj.Xname)
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make(map[string]reflect.Type)
+	if propertiesType, ok := sls_common.ExtraPropertiesTypes[obj.Type]; ok {
+		for i := 0; i < propertiesType.NumField(); i++ {
+			field := propertiesType.Field(i)
+			if name := sls_common.JSONFieldName(field); name != "" {
+				fields[name] = field.Type
+			}
+		}
+	}
+
+	for _, name := range names {
+		fieldType, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%w: %s: unknown property %s for type %s",
+				InvalidExtraProperties, obj.Xname, name, obj.Type)
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(string(properties[name])))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(reflect.New(fieldType).Interface())
+		if err != nil {
+			return fmt.Errorf("%w: %s: property %s is invalid: %s", InvalidExtraProperties, obj.Xname, name, err)
+		}
+	}
+
+	for _, name := range sls_common.ExtraPropertiesRequired[obj.Type] {
+		value, ok := properties[name]
+		if !ok || string(value) == "null" {
+			return fmt.Errorf("%w: %s: missing required property %s for type %s",
+				InvalidExtraProperties, obj.Xname, name, obj.Type)
+		}
+	}
+
+	return nil
+}
+
+// checkExtraProperties is validateExtraProperties, except in ValidationLenient mode any problem is only logged.
+func checkExtraProperties(obj sls_common.GenericHardware) error {
+	err := validateExtraProperties(obj)
+	if err != nil && validationMode == ValidationLenient {
+		log.Printf("WARNING: storing %s with invalid ExtraProperties: %s", obj.Xname, err)
+		return nil
+	}
+
+	return err
+}
+
+// getXnameTree returns xname and everything below it down to depth, parents before their children, after checking
+// the requested types are valid. It returns nil if there is no such xname.
+func getXnameTree(xname string, depth int, types []sls_common.HMSStringType) ([]sls_common.GenericHardware, error) {
+	for _, hmsType := range types {
+		err := validateType(hmsType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hardware, err := database.GetGenericHardwareTree(base.NormalizeHMSCompID(xname), depth)
+	if err == database.NoSuch {
+		return nil, nil
+	}
+	return hardware, err
+}
+
+func hasType(hardware sls_common.GenericHardware, types []sls_common.HMSStringType) bool {
+	for _, hmsType := range types {
+		if hardware.Type == hmsType {
+			return true
+		}
+	}
+	return len(types) == 0
+}
+
+// GetXnameDescendants returns the hardware below xname, at most depth levels down unless depth is negative, ordered
+// by depth. If types are given only hardware of those types is returned. The bool is false if xname doesn't exist.
+func GetXnameDescendants(xname string, depth int, types []sls_common.HMSStringType) (
+	[]sls_common.GenericHardware, bool, error) {
+	hardware, err := getXnameTree(xname, depth, types)
+	if hardware == nil || err != nil {
+		return nil, false, err
+	}
+
+	descendants := []sls_common.GenericHardware{}
+	for _, hw := range hardware[1:] {
+		if hasType(hw, types) {
+			descendants = append(descendants, hw)
+		}
+	}
+
+	return descendants, true, nil
+}
+
+// GetXnameTree returns xname with the hardware below it nested in ChildHardware, at most depth levels down unless
+// depth is negative. If types are given the tree only keeps hardware of those types and what lies between them and
+// xname. It returns nil if xname doesn't exist.
+func GetXnameTree(xname string, depth int, types []sls_common.HMSStringType) (*sls_common.GenericHardwareTree, error) {
+	hardware, err := getXnameTree(xname, depth, types)
+	if hardware == nil || err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]int)
+	for i, hw := range hardware[1:] {
+		children[hw.Parent] = append(children[hw.Parent], i+1)
+	}
+
+	var build func(i int) (sls_common.GenericHardwareTree, bool)
+	build = func(i int) (sls_common.GenericHardwareTree, bool) {
+		tree := sls_common.GenericHardwareTree{GenericHardware: hardware[i]}
+		for _, child := range children[hardware[i].Xname] {
+			if childTree, keep := build(child); keep {
+				tree.ChildHardware = append(tree.ChildHardware, childTree)
+			}
+		}
+		return tree, len(tree.ChildHardware) != 0 || hasType(hardware[i], types)
+	}
+
+	tree, _ := build(0)
+	return &tree, nil
+}
+
 // ReplaceGenericHardware will in a single transaction remove all hardware from the database and subsequently insert
 // all of the provided hardware in its place. This make this a safe function to use for any bulk load operations.
+// Nothing is replaced if the ExtraProperties of any of the hardware are invalid, see checkExtraProperties, or any of
+// it is linked to hardware that isn't in hardware, see checkReferences.
 func ReplaceGenericHardware(hardware []sls_common.GenericHardware) error {
+	batch := xnameSet(hardware)
+	for _, obj := range hardware {
+		err := checkExtraProperties(obj)
+		if err != nil {
+			return fmt.Errorf("%w: %s", InvalidHardware, err)
+		}
+
+		err = checkReferences(obj, batch, false)
+		if err != nil {
+			return err
+		}
+	}
+
 	return database.ReplaceAllGenericHardware(hardware)
 }
 
-func SearchGenericHardware(searchHardware sls_common.GenericHardware) (returnHardware []sls_common.GenericHardware, err error) {
+// ResolveName returns the hardware that has name as one of its Aliases, ignoring case. It returns nil if no hardware
+// has that alias.
+func ResolveName(name string) (*sls_common.GenericHardware, error) {
+	hardware, err := database.GetGenericHardwareForAlias(name)
+	if err == database.NoSuch {
+		return nil, nil
+	}
+	return &hardware, err
+}
+
+// GetXnameNames returns the Aliases of xname along with every IPReservation that was made for it in any network, that
+// is every reservation with xname or one of its Aliases as its Name or Comment, ignoring case. It returns nil if there
+// is no such xname.
+func GetXnameNames(xname string) (*sls_common.HardwareNames, error) {
+	hardware, err := GetXname(xname)
+	if hardware == nil || err != nil {
+		return nil, err
+	}
+
+	names := sls_common.HardwareNames{
+		Xname:          hardware.Xname,
+		Aliases:        []string{},
+		IPReservations: []sls_common.NetworkIPReservation{},
+	}
+
+	var properties struct {
+		Aliases []string
+	}
+	propertiesBytes, err := json.Marshal(hardware.ExtraPropertiesRaw)
+	if err != nil {
+		return nil, err
+	}
+	// Hardware without any ExtraProperties has no aliases.
+	if json.Unmarshal(propertiesBytes, &properties) == nil && properties.Aliases != nil {
+		names.Aliases = properties.Aliases
+	}
+
+	isName := map[string]bool{strings.ToLower(hardware.Xname): true}
+	for _, alias := range names.Aliases {
+		isName[strings.ToLower(alias)] = true
+	}
+
+	networks, err := database.GetAllNetworks()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Name < networks[j].Name
+	})
+
+	for _, network := range networks {
+		var networkProperties sls_common.NetworkExtraProperties
+		networkBytes, err := json.Marshal(network.ExtraPropertiesRaw)
+		if err != nil {
+			return nil, err
+		}
+		// Networks are free to have ExtraProperties of their own making, those have no reservations.
+		if json.Unmarshal(networkBytes, &networkProperties) != nil {
+			continue
+		}
+
+		for _, subnet := range networkProperties.Subnets {
+			for _, reservation := range subnet.IPReservations {
+				if isName[strings.ToLower(reservation.Comment)] || isName[strings.ToLower(reservation.Name)] {
+					names.IPReservations = append(names.IPReservations, sls_common.NetworkIPReservation{
+						Network:       network.Name,
+						Subnet:        subnet.Name,
+						IPReservation: reservation,
+					})
+				}
+			}
+		}
+	}
+
+	return &names, nil
+}
+
+// SearchGenericHardware returns the hardware matching all of the set fields of searchHardware and the given filter
+// expression, see search.Parse. Either may be empty but not both.
+func SearchGenericHardware(searchHardware sls_common.GenericHardware, filter string) (
+	returnHardware []sls_common.GenericHardware, err error) {
 	conditions := make(map[string]string)
 
 	// Build conditions map.
@@ -191,7 +452,34 @@ func SearchGenericHardware(searchHardware sls_common.GenericHardware) (returnHar
 		return
 	}
 
-	returnHardware, err = database.SearchGenericHardware(conditions, propertiesMap)
+	legacyFilter, err := database.ConditionsFilter(conditions, propertiesMap)
+	if err != nil {
+		return
+	}
+
+	parsedFilter, err := search.Parse(filter)
+	if err != nil {
+		return
+	}
+	if parsedFilter != nil {
+		// Xnames are stored normalized so the values compared with them have to be as well.
+		parsedFilter = search.Walk(parsedFilter, func(comparison *search.Comparison) {
+			field := strings.ToLower(comparison.Field)
+			if field != "xname" && field != "parent" {
+				return
+			}
+			for i, value := range comparison.Values {
+				if comparison.Op == search.Like {
+					comparison.Values[i] = strings.ToLower(value)
+				} else {
+					comparison.Values[i] = base.NormalizeHMSCompID(value)
+				}
+			}
+		})
+		legacyFilter = search.And{legacyFilter, parsedFilter}
+	}
+
+	returnHardware, err = database.SearchGenericHardwareFilter(legacyFilter)
 
 	return
 }
@@ -0,0 +1,178 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidPage = errors.New("limit and offset must not be negative")
+var InvalidAsOf = errors.New("invalid asOfVersion or asOf")
+var VersionUnavailable = errors.New("SLS is not available as of that version")
+
+// GetXnameHistory returns a page of the changes made to xname, newest first. A limit of 0 returns every change. The
+// history of hardware that has been deleted is still returned, it returns nil only if there never was such an xname.
+func GetXnameHistory(xname string, limit int, offset int) (*sls_common.HardwareHistory, error) {
+	if limit < 0 || offset < 0 {
+		return nil, InvalidPage
+	}
+
+	xname = base.NormalizeHMSCompID(xname)
+	revisions, total, err := database.GetGenericHardwareRevisions(xname, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hardware stored before revisions were recorded has no history yet.
+	if total == 0 {
+		hardware, err := GetXname(xname)
+		if hardware == nil || err != nil {
+			return nil, err
+		}
+	}
+
+	return &sls_common.HardwareHistory{
+		Xname:     xname,
+		Total:     total,
+		Revisions: revisions,
+	}, nil
+}
+
+// GetNetworkHistory returns a page of the changes made to the named network, newest first. A limit of 0 returns
+// every change. The history of a network that has been deleted is still returned, it returns nil only if there never
+// was such a network.
+func GetNetworkHistory(name string, limit int, offset int) (*sls_common.NetworkHistory, error) {
+	if limit < 0 || offset < 0 {
+		return nil, InvalidPage
+	}
+
+	revisions, total, err := database.GetNetworkRevisions(name, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if total == 0 {
+		_, err := database.GetNetworkForName(name)
+		if err == database.NoSuch {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return &sls_common.NetworkHistory{
+		Name:      name,
+		Total:     total,
+		Revisions: revisions,
+	}, nil
+}
+
+/*
+GetAsOfVersion returns the version to read SLS as of, either asOfVersion or the
+version SLS was at at the RFC 3339 time asOf.  ok is false if neither is
+given.  The version has to be one revisions were recorded for and no newer
+than the current one, or VersionUnavailable is returned.
+*/
+func GetAsOfVersion(asOfVersion string, asOf string) (version int64, ok bool, err error) {
+	if asOfVersion == "" && asOf == "" {
+		return
+	}
+	if asOfVersion != "" && asOf != "" {
+		err = fmt.Errorf("%w: only one of them can be given", InvalidAsOf)
+		return
+	}
+
+	if asOfVersion != "" {
+		version, err = strconv.ParseInt(asOfVersion, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("%w: %s is not a version", InvalidAsOf, asOfVersion)
+			return
+		}
+	} else {
+		asOfTime, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			err = fmt.Errorf("%w: %s is not an RFC 3339 time", InvalidAsOf, asOf)
+			return
+		}
+
+		version, err = database.GetVersionAsOf(asOfTime)
+		if err == database.NoSuch {
+			err = fmt.Errorf("%w: nothing was stored yet at %s", VersionUnavailable, asOf)
+			return
+		} else if err != nil {
+			return
+		}
+	}
+
+	start, current, err := database.GetVersionRange()
+	if err != nil {
+		return
+	}
+	if version < start || version > current {
+		err = fmt.Errorf("%w: only versions %d to %d are", VersionUnavailable, start, current)
+		return
+	}
+
+	ok = true
+	return
+}
+
+// GetAllHardwareAsOf returns all hardware as it was at version, see GetAsOfVersion.
+func GetAllHardwareAsOf(version int64) ([]sls_common.GenericHardware, error) {
+	return database.GetAllGenericHardwareAsOf(version)
+}
+
+// GetAllNetworksAsOf returns all networks as they were at version, see GetAsOfVersion.
+func GetAllNetworksAsOf(version int64) ([]sls_common.Network, error) {
+	return database.GetAllNetworksAsOf(version)
+}
+
+/*
+RestoreVersion brings all hardware and networks back to how they were at the
+version or time of request, in one transaction that is recorded as a new
+version.  Vault is left alone.
+*/
+func RestoreVersion(request sls_common.RestoreRequest) (result sls_common.RestoreResult, err error) {
+	var asOfVersion string
+	if request.Version != 0 {
+		asOfVersion = strconv.FormatInt(request.Version, 10)
+	}
+
+	version, ok, err := GetAsOfVersion(asOfVersion, request.AsOf)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%w: one of Version and AsOf has to be given", InvalidAsOf)
+		return
+	}
+
+	return database.RestoreVersion(version)
+}
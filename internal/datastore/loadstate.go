@@ -0,0 +1,516 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/patch"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidLoadState = errors.New("invalid loadstate request")
+
+// LoadStateUndone is wrapped around the error from afterCommit when LoadState undid what it had written because of it.
+var LoadStateUndone = errors.New("loaded state was undone")
+
+// LoadStateMode is how a loaded SLS state is combined with the hardware and networks already stored.
+type LoadStateMode string
+
+const (
+	// LoadStateReplace replaces everything stored with the loaded state, removing what isn't in it.
+	LoadStateReplace LoadStateMode = "replace"
+	// LoadStateMerge adds what is in the loaded state and updates what is already stored, merging ExtraProperties
+	// key by key into the stored ones. Anything not in the loaded state is left alone.
+	LoadStateMerge LoadStateMode = "merge"
+	// LoadStateUpsertOnly adds what is in the loaded state and overwrites what is already stored with it. Anything
+	// not in the loaded state is left alone.
+	LoadStateUpsertOnly LoadStateMode = "upsert-only"
+)
+
+// ParseLoadStateMode returns the mode called name, LoadStateReplace if name is empty.
+func ParseLoadStateMode(name string) (LoadStateMode, error) {
+	switch mode := LoadStateMode(name); mode {
+	case "":
+		return LoadStateReplace, nil
+	case LoadStateReplace, LoadStateMerge, LoadStateUpsertOnly:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("%w: mode must be %s, %s or %s", InvalidLoadState, LoadStateReplace, LoadStateMerge,
+		LoadStateUpsertOnly)
+}
+
+// mergeExtraProperties merges the properties into the stored ones like a JSON Merge Patch would.
+func mergeExtraProperties(stored interface{}, properties interface{}) (interface{}, error) {
+	if properties == nil {
+		return stored, nil
+	}
+
+	doc, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	patchBody, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := patch.ApplyMergePatch(doc, patchBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(merged, &result)
+	return result, err
+}
+
+// hardwareKeys returns the keys of hardware in order.
+func hardwareKeys(hardware map[string]sls_common.GenericHardware) []string {
+	keys := make([]string, 0, len(hardware))
+	for key := range hardware {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// networkKeys returns the keys of networks in order.
+func networkKeys(networks map[string]sls_common.Network) []string {
+	keys := make([]string, 0, len(networks))
+	for key := range networks {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// loadHardware returns all hardware as it would be after loading state in mode on top of stored, and what has to be
+// written for that under the key it has in state.
+func loadHardware(stored []sls_common.GenericHardware, state sls_common.SLSState, mode LoadStateMode) (
+	loaded []sls_common.GenericHardware, written map[string]sls_common.GenericHardware, err error) {
+	byXname := make(map[string]sls_common.GenericHardware, len(stored))
+	if mode != LoadStateReplace {
+		for _, obj := range stored {
+			byXname[obj.Xname] = obj
+		}
+	}
+
+	written = make(map[string]sls_common.GenericHardware, len(state.Hardware))
+	for _, key := range hardwareKeys(state.Hardware) {
+		var obj sls_common.GenericHardware
+		obj, err = normalizeFields(state.Hardware[key])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", InvalidHardware, err)
+		}
+
+		if storedObj, found := byXname[obj.Xname]; found && mode == LoadStateMerge {
+			obj.ExtraPropertiesRaw, err = mergeExtraProperties(storedObj.ExtraPropertiesRaw, obj.ExtraPropertiesRaw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s: %s", InvalidHardware, obj.Xname, err)
+			}
+		}
+
+		byXname[obj.Xname] = obj
+		written[key] = obj
+	}
+
+	for _, obj := range byXname {
+		loaded = append(loaded, obj)
+	}
+
+	return
+}
+
+// loadNetworks returns all networks as they would be after loading state in mode on top of stored, and what has to
+// be written for that under the key it has in state.
+func loadNetworks(stored []sls_common.Network, state sls_common.SLSState, mode LoadStateMode) (
+	loaded []sls_common.Network, written map[string]sls_common.Network, err error) {
+	byName := make(map[string]sls_common.Network, len(stored))
+	if mode != LoadStateReplace {
+		for _, network := range stored {
+			byName[network.Name] = network
+		}
+	}
+
+	written = make(map[string]sls_common.Network, len(state.Networks))
+	for _, key := range networkKeys(state.Networks) {
+		network := state.Networks[key]
+
+		if storedNetwork, found := byName[network.Name]; found && mode == LoadStateMerge {
+			network.ExtraPropertiesRaw, err = mergeExtraProperties(storedNetwork.ExtraPropertiesRaw,
+				network.ExtraPropertiesRaw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s: %s", InvalidLoadState, network.Name, err)
+			}
+		}
+
+		byName[network.Name] = network
+		written[key] = network
+	}
+
+	for _, network := range byName {
+		loaded = append(loaded, network)
+	}
+
+	return
+}
+
+/*
+InvalidState is every problem found with the objects in a loaded SLS state.
+Conflict is set when all of them are aliases or NIDs used more than once.
+*/
+type InvalidState struct {
+	Errors   []sls_common.ValidationError
+	Conflict bool
+}
+
+func (invalid *InvalidState) Error() string {
+	problems := make([]string, len(invalid.Errors))
+	for i, validationError := range invalid.Errors {
+		problems[i] = validationError.Key + ": " + validationError.Error
+	}
+
+	return fmt.Sprintf("%s: %s", InvalidLoadState, strings.Join(problems, "; "))
+}
+
+func (invalid *InvalidState) Unwrap() error {
+	return InvalidLoadState
+}
+
+func (invalid *InvalidState) add(key string, err string) {
+	invalid.Errors = append(invalid.Errors, sls_common.ValidationError{Key: key, Error: err})
+}
+
+// hardwareAliases returns the Aliases in the ExtraProperties of obj.
+func hardwareAliases(obj sls_common.GenericHardware) (aliases []string) {
+	properties, _ := obj.ExtraPropertiesRaw.(map[string]interface{})
+	values, _ := properties["Aliases"].([]interface{})
+	for _, value := range values {
+		if alias, ok := value.(string); ok {
+			aliases = append(aliases, strings.ToLower(alias))
+		}
+	}
+
+	return
+}
+
+// hardwareNID returns the NID in the ExtraProperties of obj if it is a node that has one.
+func hardwareNID(obj sls_common.GenericHardware) (string, bool) {
+	if obj.Type != sls_common.Node {
+		return "", false
+	}
+
+	properties, _ := obj.ExtraPropertiesRaw.(map[string]interface{})
+	nid, ok := properties["NID"].(float64)
+	if !ok {
+		return "", false
+	}
+
+	return strconv.FormatFloat(nid, 'f', -1, 64), true
+}
+
+// isAncestor returns whether ancestor is xname or one of the parents above it.
+func isAncestor(ancestor string, xname string) bool {
+	for ; xname != ""; xname = base.GetHMSCompParent(xname) {
+		if xname == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+fileConventions returns obj with the conventions SLS files generated by CSI
+follow swapped for what validateFields expects: cabinets are children of
+the system s0, router BMCs of their cabinet rather than their router
+module, and Mountain chassis are stored as their ChassisBMC.
+*/
+func fileConventions(obj sls_common.GenericHardware) sls_common.GenericHardware {
+	parent := base.GetHMSCompParent(obj.Xname)
+	if (obj.Parent == "s0" && parent == "") || (obj.Parent != "" && isAncestor(obj.Parent, parent)) {
+		obj.Parent = parent
+	}
+
+	if base.GetHMSType(obj.Xname) == base.Chassis && obj.Type == sls_common.ChassisBMC {
+		obj.Type = sls_common.Chassis
+		obj.TypeString = base.Chassis
+	}
+
+	return obj
+}
+
+/*
+validateState checks all of hardware and networks, keyed like they are in
+the loaded state, before any of it is written.  Every object goes through
+normalizeFields and validateFields, allowing for fileConventions, or
+verifyNetwork, and has to be under its own name.  Across objects, names
+have to be unique, parents have to be in loaded (see checkParent), links
+have to be too (how strictly depends on the ReferenceMode) and aliases and
+NIDs can't be used by more than one of loaded.  It returns an InvalidState
+listing every problem it found.
+*/
+func validateState(hardware map[string]sls_common.GenericHardware, loaded []sls_common.GenericHardware,
+	networks map[string]sls_common.Network) error {
+	invalid := &InvalidState{}
+	loadedXnames := xnameSet(loaded)
+
+	keys := make(map[string]string, len(hardware))
+	for _, key := range hardwareKeys(hardware) {
+		obj := hardware[key]
+		pointer := "/Hardware/" + key
+
+		if base.NormalizeHMSCompID(key) != obj.Xname {
+			invalid.add(pointer, fmt.Sprintf("key does not match Xname %s", obj.Xname))
+		}
+		if other, found := keys[obj.Xname]; found {
+			invalid.add(pointer, fmt.Sprintf("%s is also under /Hardware/%s", obj.Xname, other))
+			continue
+		}
+		keys[obj.Xname] = key
+
+		err := validateFields(fileConventions(obj))
+		if err == nil {
+			err = checkParent(fileConventions(obj), loadedXnames)
+		}
+		if err == nil {
+			err = checkReferences(obj, loadedXnames, false)
+		}
+		if err != nil {
+			invalid.add(pointer, err.Error())
+		}
+	}
+
+	names := make(map[string]string, len(networks))
+	for _, key := range networkKeys(networks) {
+		network := networks[key]
+		pointer := "/Networks/" + key
+
+		if key != network.Name {
+			invalid.add(pointer, fmt.Sprintf("key does not match Name %s", network.Name))
+		}
+		if other, found := names[network.Name]; found {
+			invalid.add(pointer, fmt.Sprintf("%s is also under /Networks/%s", network.Name, other))
+			continue
+		}
+		names[network.Name] = key
+
+		err := verifyNetwork(network)
+		if err != nil {
+			invalid.add(pointer, err.Error())
+		}
+	}
+
+	// Only report a shared alias or NID for the hardware being written, anything else already had it.
+	invalidCount := len(invalid.Errors)
+	aliases := make(map[string][]string)
+	nids := make(map[string][]string)
+	for _, obj := range loaded {
+		for _, alias := range hardwareAliases(obj) {
+			aliases[alias] = append(aliases[alias], obj.Xname)
+		}
+		if nid, ok := hardwareNID(obj); ok {
+			nids[nid] = append(nids[nid], obj.Xname)
+		}
+	}
+	for _, shared := range []struct {
+		owners  map[string][]string
+		message string
+	}{
+		{aliases, "alias %s is also used by %s"},
+		{nids, "NID %s is also used by %s"},
+	} {
+		for value, xnames := range shared.owners {
+			if len(xnames) < 2 {
+				continue
+			}
+
+			sort.Strings(xnames)
+			for i, xname := range xnames {
+				key, found := keys[xname]
+				if !found {
+					continue
+				}
+
+				others := append(append([]string{}, xnames[:i]...), xnames[i+1:]...)
+				invalid.add("/Hardware/"+key, fmt.Sprintf(shared.message, value, strings.Join(others, ", ")))
+			}
+		}
+	}
+
+	if len(invalid.Errors) == 0 {
+		return nil
+	}
+
+	invalid.Conflict = invalidCount == 0
+	sort.SliceStable(invalid.Errors, func(i, j int) bool { return invalid.Errors[i].Key < invalid.Errors[j].Key })
+	return invalid
+}
+
+/*
+LoadState loads state on top of the hardware and networks stored now, the
+way mode says to, and returns what changed.  When dryRun is set nothing is
+written and the changes that would have been made are returned.  Nothing is
+written either unless everything in state is valid, see validateState.
+
+Whatever is written is written in a single transaction, which first takes
+a snapshot of everything as it was unless SetLoadStateSnapshots turned
+that off.  If anything else was written since the stored hardware and
+networks were read nothing is, and database.PreconditionFailed is returned.
+If afterCommit isn't nil it is called once that has been committed, for the
+writes that can't be part of it.  If it fails the hardware and networks
+that were stored before are put back, as long as nothing else has been
+written in the meantime, and its error is returned wrapped in
+LoadStateUndone.
+*/
+func LoadState(state sls_common.SLSState, mode LoadStateMode, dryRun bool,
+	afterCommit func() error) (result sls_common.SLSDiff, err error) {
+	current, storedHardware, storedNetworks, err := database.GetState()
+	if err != nil {
+		return
+	}
+
+	loadedHardware, writtenHardware, err := loadHardware(storedHardware, state, mode)
+	if err != nil {
+		return
+	}
+	loadedNetworks, writtenNetworks, err := loadNetworks(storedNetworks, state, mode)
+	if err != nil {
+		return
+	}
+
+	err = validateState(writtenHardware, loadedHardware, writtenNetworks)
+	if err != nil {
+		return
+	}
+
+	result.From = strconv.FormatInt(current, 10)
+	result.To = UploadVersion
+	result.Hardware, err = compareHardware(storedHardware, loadedHardware)
+	if err != nil {
+		return
+	}
+	result.Networks, err = compareNetworks(storedNetworks, loadedNetworks)
+	if err != nil || dryRun {
+		return
+	}
+
+	var version int64
+	if mode == LoadStateReplace {
+		var hardware []sls_common.GenericHardware
+		for _, key := range hardwareKeys(writtenHardware) {
+			hardware = append(hardware, writtenHardware[key])
+		}
+		var networks []sls_common.Network
+		for _, key := range networkKeys(writtenNetworks) {
+			networks = append(networks, writtenNetworks[key])
+		}
+
+		version, err = database.ReplaceState(hardware, networks, "loadstate:replace", current,
+			loadStateSnapshot)
+		if err != nil {
+			return
+		}
+	} else {
+		version, err = upsertChanged(result, writtenHardware, writtenNetworks, current)
+		if err != nil {
+			return
+		}
+	}
+
+	if afterCommit == nil {
+		return
+	}
+
+	err = afterCommit()
+	if err == nil {
+		return
+	}
+
+	// Nothing was written if a version wasn't made.
+	if version == 0 {
+		err = fmt.Errorf("%w: %s", LoadStateUndone, err)
+		return
+	}
+
+	_, undoErr := database.ReplaceState(storedHardware, storedNetworks, "loadstate:undo", version, nil)
+	if undoErr != nil {
+		err = fmt.Errorf("unable to undo version %d after %s: %s", version, err, undoErr)
+		return
+	}
+
+	err = fmt.Errorf("%w: %s", LoadStateUndone, err)
+	return
+}
+
+// upsertChanged writes the hardware and networks that result says were added or modified, so nothing else gets a new
+// revision, as long as current is still the current version. It returns the version they were written in, 0 if
+// nothing changed.
+func upsertChanged(result sls_common.SLSDiff, writtenHardware map[string]sls_common.GenericHardware,
+	writtenNetworks map[string]sls_common.Network, current int64) (version int64, err error) {
+	changedXnames := make(map[string]bool)
+	for _, obj := range result.Hardware.Added {
+		changedXnames[obj.Xname] = true
+	}
+	for _, objectDiff := range result.Hardware.Modified {
+		changedXnames[objectDiff.Name] = true
+	}
+	changedNames := make(map[string]bool)
+	for _, network := range result.Networks.Added {
+		changedNames[network.Name] = true
+	}
+	for _, objectDiff := range result.Networks.Modified {
+		changedNames[objectDiff.Name] = true
+	}
+
+	var changedHardware []sls_common.GenericHardware
+	for _, key := range hardwareKeys(writtenHardware) {
+		if obj := writtenHardware[key]; changedXnames[obj.Xname] {
+			changedHardware = append(changedHardware, obj)
+		}
+	}
+	var changedNetworks []sls_common.Network
+	for _, key := range networkKeys(writtenNetworks) {
+		if network := writtenNetworks[key]; changedNames[network.Name] {
+			changedNetworks = append(changedNetworks, network)
+		}
+	}
+
+	// Don't make a new version for a load that changes nothing.
+	if len(changedHardware) == 0 && len(changedNetworks) == 0 {
+		return
+	}
+
+	return database.UpsertState(changedHardware, changedNetworks, current, loadStateSnapshot)
+}
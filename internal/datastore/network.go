@@ -23,10 +23,14 @@
 package datastore
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/patch"
+	"github.com/Cray-HPE/hms-sls/internal/search"
 	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
 )
 
@@ -75,6 +79,11 @@ func GetNetwork(name string) (sls_common.Network, error) {
 	return database.GetNetworkForName(name)
 }
 
+// GetNetworkAndVersion returns the network object matching the given name and the version it was last updated in.
+func GetNetworkAndVersion(name string) (sls_common.Network, int64, error) {
+	return database.GetNetworkAndVersion(name)
+}
+
 // InsertNetwork adds a given network into the database assuming it passes validation.
 func InsertNetwork(network sls_common.Network) (err error) {
 	err = verifyNetwork(network)
@@ -95,27 +104,58 @@ func UpdateNetwork(network sls_common.Network) error {
 
 // Insert or update a network
 func SetNetwork(network sls_common.Network) error {
+	_, err := SetNetworkIfMatch(network, database.Precondition{})
+	return err
+}
+
+// SetNetworkIfMatch inserts or updates a network as long as the stored network satisfies precondition, returning the
+// version the network was written in.
+func SetNetworkIfMatch(network sls_common.Network, precondition database.Precondition) (int64, error) {
 	err := verifyNetwork(network)
 	if err != nil {
-		return err
-	}
-	_, nwerr := GetNetwork(network.Name)
-	if (nwerr != nil) && (nwerr != database.NoSuch) {
-		return nwerr
+		return 0, err
 	}
 
-	if (nwerr != nil) && (nwerr == database.NoSuch) {
-		inserr := database.InsertNetwork(network)
-		if inserr != nil {
-			return inserr
+	return database.SetNetwork(network, precondition)
+}
+
+// PatchNetwork applies a JSON Merge Patch or a JSON Patch (chosen by contentType) to the named network. The patched
+// network goes through the same validation as any other write and is stored in a single transaction, provided the
+// stored network satisfies precondition.
+func PatchNetwork(name string, contentType string, patchBody []byte, precondition database.Precondition) (
+	sls_common.Network, int64, error) {
+	return database.PatchNetwork(name, precondition, func(network sls_common.Network) (sls_common.Network, error) {
+		// These are maintained by the database, don't let them take part in the patch.
+		network.LastUpdated = 0
+		network.LastUpdatedTime = ""
+
+		doc, err := json.Marshal(network)
+		if err != nil {
+			return network, err
+		}
+
+		patchedDoc, err := patch.Apply(contentType, doc, patchBody)
+		if err != nil {
+			return network, err
+		}
+
+		var patched sls_common.Network
+		err = json.Unmarshal(patchedDoc, &patched)
+		if err != nil {
+			return network, fmt.Errorf("%w: patched network is not valid: %s", patch.InvalidPatch, err)
 		}
-	} else {
-		upderr := database.UpdateNetwork(network)
-		if upderr != nil {
-			return upderr
+		if patched.Name != name {
+			return network, fmt.Errorf("%w: cannot change Name from %s to %s", patch.InvalidPatch, name,
+				patched.Name)
 		}
-	}
-	return nil
+
+		err = verifyNetwork(patched)
+		if err != nil {
+			return network, err
+		}
+
+		return patched, nil
+	})
 }
 
 // DeleteNetwork removes a network from the DB.
@@ -123,12 +163,19 @@ func DeleteNetwork(networkName string) error {
 	return database.DeleteNetwork(networkName)
 }
 
+// DeleteNetworkIfMatch removes a network from the DB as long as it satisfies precondition.
+func DeleteNetworkIfMatch(networkName string, precondition database.Precondition) error {
+	return database.DeleteNetworkIfMatch(networkName, precondition)
+}
+
 // GetAllNetworks returns all the network objects in the DB.
 func GetAllNetworks() ([]sls_common.Network, error) {
 	return database.GetAllNetworks()
 }
 
-func SearchNetworks(network sls_common.Network) (networks []sls_common.Network, err error) {
+// SearchNetworks returns the networks matching all of the set fields of network and the given filter expression, see
+// search.Parse. Either may be empty but not both.
+func SearchNetworks(network sls_common.Network, filter string) (networks []sls_common.Network, err error) {
 	conditions := make(map[string]string)
 
 	if network.Name != "" {
@@ -160,7 +207,20 @@ func SearchNetworks(network sls_common.Network) (networks []sls_common.Network,
 		return
 	}
 
-	networks, err = database.SearchNetworks(conditions, propertiesMap)
+	searchFilter, err := database.ConditionsFilter(conditions, propertiesMap)
+	if err != nil {
+		return
+	}
+
+	parsedFilter, err := search.Parse(filter)
+	if err != nil {
+		return
+	}
+	if parsedFilter != nil {
+		searchFilter = search.And{searchFilter, parsedFilter}
+	}
+
+	networks, err = database.SearchNetworksFilter(searchFilter)
 
 	return
 }
@@ -0,0 +1,213 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidNIDAllocation = errors.New("NID allocation request is invalid")
+
+// maxNIDCount is the most NIDs a single request can ask for.
+const maxNIDCount = 100000
+
+// nidReservationTime is how long NIDs handed out without a node are kept from anyone else.
+const nidReservationTime = time.Hour
+
+// lessXname orders xnames by topology, comparing the numbers in them by value so x1000c0s10 comes after x1000c0s9.
+func lessXname(a, b string) bool {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+
+		// Compare whole numbers, a shorter number (once leading zeros are gone) is a smaller one.
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		starti, startj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		numa, numb := a[starti:i], b[startj:j]
+		if len(numa) != len(numb) {
+			return len(numa) < len(numb)
+		}
+		if numa != numb {
+			return numa < numb
+		}
+	}
+
+	return len(a)-i < len(b)-j
+}
+
+// isBelow is true if xname is somewhere below ancestor, going by the xnames alone.
+func isBelow(xname string, ancestor string) bool {
+	for parent := base.GetHMSCompParent(xname); parent != ""; parent = base.GetHMSCompParent(parent) {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// nidMap builds the NIDMap of the given NIDs, which are ordered by NID.
+func nidMap(nids []sls_common.NodeNID) sls_common.NIDMap {
+	m := sls_common.NIDMap{
+		NIDs:       []sls_common.NodeNID{},
+		Gaps:       []sls_common.NIDRange{},
+		Duplicates: []int{},
+		Unassigned: []string{},
+	}
+
+	for _, nid := range nids {
+		if nid.NID == 0 {
+			m.Unassigned = append(m.Unassigned, nid.Xname)
+			continue
+		}
+
+		if len(m.NIDs) > 0 {
+			last := m.NIDs[len(m.NIDs)-1].NID
+			if nid.NID == last {
+				if len(m.Duplicates) == 0 || m.Duplicates[len(m.Duplicates)-1] != last {
+					m.Duplicates = append(m.Duplicates, last)
+				}
+			} else if nid.NID > last+1 {
+				m.Gaps = append(m.Gaps, sls_common.NIDRange{First: last + 1, Last: nid.NID - 1})
+			}
+		}
+		m.NIDs = append(m.NIDs, nid)
+	}
+
+	sort.Slice(m.Unassigned, func(i, j int) bool {
+		return lessXname(m.Unassigned[i], m.Unassigned[j])
+	})
+
+	return m
+}
+
+// GetNIDMap returns the NID of every node, along with the gaps between them, the NIDs used by more than one node and
+// the nodes without a NID.
+func GetNIDMap() (sls_common.NIDMap, error) {
+	nids, err := database.GetGenericHardwareNIDs()
+	if err != nil {
+		return sls_common.NIDMap{}, err
+	}
+
+	return nidMap(nids), nil
+}
+
+/*
+AllocateNIDs hands out NIDs for request.  With a Count the lowest free NIDs
+are reserved for nidReservationTime, so they can be written to nodes without
+being handed out again in the meantime.  With an Xname every node below that cabinet or chassis without a NID is
+given the lowest free NID in topology order, all in one version.  It
+returns nil if the Xname does not exist.
+*/
+func AllocateNIDs(request sls_common.NIDAllocationRequest) (*sls_common.NIDAllocation, error) {
+	start := request.StartingNID
+	if start < 0 {
+		return nil, fmt.Errorf("%w: StartingNID must not be negative", InvalidNIDAllocation)
+	}
+	if start == 0 {
+		start = 1
+	}
+
+	if (request.Count == 0) == (request.Xname == "") {
+		return nil, fmt.Errorf("%w: exactly one of Count and Xname is required", InvalidNIDAllocation)
+	}
+
+	if request.Xname == "" {
+		if request.Count < 0 || request.Count > maxNIDCount {
+			return nil, fmt.Errorf("%w: Count must be between 1 and %d", InvalidNIDAllocation, maxNIDCount)
+		}
+
+		expires := time.Now().Add(nidReservationTime)
+		reserved, err := database.ReserveNIDs(request.Count, start, expires)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sls_common.NIDAllocation{ReservedUntil: expires.UTC().Format(time.RFC3339), NIDs: reserved}, nil
+	}
+
+	m, err := GetNIDMap()
+	if err != nil {
+		return nil, err
+	}
+
+	xname := base.NormalizeHMSCompID(request.Xname)
+	hmsType := base.GetHMSType(xname)
+	if hmsType != base.Cabinet && hmsType != base.Chassis {
+		return nil, fmt.Errorf("%w: %s is not a cabinet or chassis", InvalidNIDAllocation, request.Xname)
+	}
+
+	hardware, err := GetXname(xname)
+	if hardware == nil || err != nil {
+		return nil, err
+	}
+
+	var xnames []string
+	for _, node := range m.Unassigned {
+		if isBelow(node, xname) {
+			xnames = append(xnames, node)
+		}
+	}
+
+	allocation := sls_common.NIDAllocation{NIDs: []sls_common.NodeNID{}}
+	if len(xnames) == 0 {
+		return &allocation, nil
+	}
+
+	assigned, version, err := database.AssignGenericHardwareNIDs(xnames, start)
+	if err != nil {
+		return nil, err
+	}
+
+	allocation.Version = version
+	if assigned != nil {
+		allocation.NIDs = assigned
+	}
+	return &allocation, nil
+}
@@ -0,0 +1,261 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"encoding/json"
+	"sort"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+const (
+	PowerUpstream   = "upstream"
+	PowerDownstream = "downstream"
+)
+
+// powerBoundaries are the types that don't pass power on to the hardware below them. Where that hardware draws power
+// from is only known from its PoweredBy and PowerConnector.
+var powerBoundaries = map[base.HMSType]bool{
+	base.System:     true,
+	base.Cabinet:    true,
+	base.CabinetCDU: true,
+	base.CDU:        true,
+	base.Chassis:    true,
+}
+
+// powerProperties are the ExtraProperties that hold the xnames hardware draws power from, either as a list or as a
+// single xname. The misspelled one is how PowerConnector is stored.
+var powerProperties = []string{"PoweredBy", "PowerConenctor"}
+
+// powerSources returns the normalized xnames in the powerProperties of obj.
+func powerSources(obj sls_common.GenericHardware) []string {
+	var properties map[string]json.RawMessage
+	propertiesBytes, err := json.Marshal(obj.ExtraPropertiesRaw)
+	if err != nil || json.Unmarshal(propertiesBytes, &properties) != nil {
+		return nil
+	}
+
+	var sources []string
+	for _, property := range powerProperties {
+		var xnames []string
+		var xname string
+		if json.Unmarshal(properties[property], &xname) == nil {
+			xnames = []string{xname}
+		} else if json.Unmarshal(properties[property], &xnames) != nil {
+			continue
+		}
+
+		for _, xname := range xnames {
+			xname = base.NormalizeHMSCompID(xname)
+			if base.IsHMSCompIDValid(xname) {
+				sources = append(sources, xname)
+			}
+		}
+	}
+
+	return sources
+}
+
+// powerFeeds maps every xname in the power graph of hardware to the xnames it draws power from directly. Besides the
+// powerSources of hardware, everything draws power from its parent unless the parent is one of powerBoundaries. The
+// exception is a NodePowerConnector, which is where its parent blade draws power from instead. Hardware that isn't
+// stored, such as the slot of a River node, still passes power on.
+func powerFeeds(hardware []sls_common.GenericHardware) map[string][]string {
+	feeds := make(map[string][]string)
+	addFeed := func(xname string, feed string) {
+		if xname == feed {
+			return
+		}
+		for _, existing := range feeds[xname] {
+			if existing == feed {
+				return
+			}
+		}
+		feeds[xname] = append(feeds[xname], feed)
+	}
+
+	climbed := make(map[string]bool)
+	climb := func(xname string) {
+		for !climbed[xname] {
+			climbed[xname] = true
+
+			parent := base.GetHMSCompParent(xname)
+			if parent == "" || powerBoundaries[base.GetHMSType(parent)] {
+				return
+			}
+
+			if base.GetHMSType(xname) == base.NodePowerConnector {
+				addFeed(parent, xname)
+			} else {
+				addFeed(xname, parent)
+			}
+			xname = parent
+		}
+	}
+
+	for _, obj := range hardware {
+		for _, source := range powerSources(obj) {
+			addFeed(obj.Xname, source)
+			climb(source)
+		}
+		climb(obj.Xname)
+	}
+
+	for xname := range feeds {
+		sort.Slice(feeds[xname], func(i, j int) bool {
+			return lessXname(feeds[xname][i], feeds[xname][j])
+		})
+	}
+
+	return feeds
+}
+
+// powerHops walks from xname along edges, breadth first, and returns every xname it reaches that keep allows.
+func powerHops(xname string, edges map[string][]string, feeds map[string][]string,
+	keep func(xname string) bool) []sls_common.PowerHop {
+	hops := []sls_common.PowerHop{}
+	depths := map[string]int{xname: 0}
+	queue := []string{xname}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, next := range edges[current] {
+			if _, ok := depths[next]; ok {
+				continue
+			}
+			depths[next] = depths[current] + 1
+			queue = append(queue, next)
+
+			if keep(next) {
+				hops = append(hops, sls_common.PowerHop{
+					Xname:     next,
+					Type:      sls_common.HMSTypeToHMSStringType(base.GetHMSType(next)),
+					Depth:     depths[next],
+					PoweredBy: append([]string{}, feeds[next]...),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(hops, func(i, j int) bool {
+		if hops[i].Depth != hops[j].Depth {
+			return hops[i].Depth < hops[j].Depth
+		}
+		return lessXname(hops[i].Xname, hops[j].Xname)
+	})
+
+	return hops
+}
+
+// powerUpstream returns everything xname draws power from, directly or not.
+func powerUpstream(xname string, feeds map[string][]string) []sls_common.PowerHop {
+	return powerHops(xname, feeds, feeds, func(string) bool { return true })
+}
+
+// powerDownstream returns everything that loses power when xname does, which is everything downstream of it that
+// draws power from nothing but xname and hardware that loses power with it. Hardware with a redundant feed keeps
+// its power.
+func powerDownstream(xname string, feeds map[string][]string) []sls_common.PowerHop {
+	powers := make(map[string][]string)
+	for powered, sources := range feeds {
+		for _, source := range sources {
+			powers[source] = append(powers[source], powered)
+		}
+	}
+
+	reachable := powerHops(xname, powers, feeds, func(string) bool { return true })
+
+	lost := map[string]bool{xname: true}
+	for changed := true; changed; {
+		changed = false
+		for _, hop := range reachable {
+			if lost[hop.Xname] {
+				continue
+			}
+
+			losesPower := true
+			for _, feed := range hop.PoweredBy {
+				losesPower = losesPower && lost[feed]
+			}
+			if losesPower {
+				lost[hop.Xname] = true
+				changed = true
+			}
+		}
+	}
+
+	return powerHops(xname, powers, feeds, func(next string) bool { return lost[next] })
+}
+
+// GetPowerTopology returns the hardware upstream or downstream of xname, depending on direction, in the power graph
+// of all hardware (see powerFeeds). If types are given only hardware of those types is listed, but the walk still
+// goes through everything else. It returns nil if there is no such xname.
+func GetPowerTopology(xname string, direction string, types []sls_common.HMSStringType) (
+	*sls_common.PowerTopology, error) {
+	for _, hmsType := range types {
+		err := validateType(hmsType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	xname = base.NormalizeHMSCompID(xname)
+	hardware, err := database.GetAllGenericHardware()
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, obj := range hardware {
+		found = found || obj.Xname == xname
+	}
+	if !found {
+		return nil, nil
+	}
+
+	feeds := powerFeeds(hardware)
+
+	var hops []sls_common.PowerHop
+	if direction == PowerDownstream {
+		hops = powerDownstream(xname, feeds)
+	} else {
+		hops = powerUpstream(xname, feeds)
+	}
+
+	topology := sls_common.PowerTopology{
+		Xname:     xname,
+		Direction: direction,
+		Hardware:  []sls_common.PowerHop{},
+	}
+	for _, hop := range hops {
+		if hasType(sls_common.GenericHardware{Type: hop.Type}, types) {
+			topology.Hardware = append(topology.Hardware, hop)
+		}
+	}
+
+	return &topology, nil
+}
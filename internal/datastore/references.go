@@ -0,0 +1,136 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var DanglingReference = errors.New("hardware is linked to hardware that does not exist")
+var InvalidReferenceMode = errors.New("reference mode is invalid")
+
+// ReferenceMode is how hardware linked to hardware that doesn't exist is handled when it is written. See
+// sls_common.ExtraPropertiesLinks for which hardware is linked.
+type ReferenceMode string
+
+const (
+	// ReferencesIgnore stores dangling links without comment. They are picked up once the hardware they point at is
+	// created.
+	ReferencesIgnore ReferenceMode = "ignore"
+	// ReferencesReport stores dangling links anyway and logs them.
+	ReferencesReport ReferenceMode = "report"
+	// ReferencesReject rejects hardware with dangling links.
+	ReferencesReject ReferenceMode = "reject"
+)
+
+var referenceMode = ReferencesIgnore
+
+// SetReferenceMode sets how hardware with dangling links is handled from now on.
+func SetReferenceMode(mode ReferenceMode) error {
+	switch mode {
+	case ReferencesIgnore, ReferencesReport, ReferencesReject:
+		referenceMode = mode
+		return nil
+	}
+
+	return InvalidReferenceMode
+}
+
+// xnameSet returns the normalized xnames of hardware.
+func xnameSet(hardware []sls_common.GenericHardware) map[string]bool {
+	xnames := make(map[string]bool, len(hardware))
+	for _, obj := range hardware {
+		xnames[base.NormalizeHMSCompID(obj.Xname)] = true
+	}
+
+	return xnames
+}
+
+// danglingLinks returns the xnames obj is linked to that aren't in batch and, if stored is set, aren't in the
+// database either.
+func danglingLinks(obj sls_common.GenericHardware, batch map[string]bool, stored bool) ([]string, error) {
+	var dangling []string
+	for _, xname := range sls_common.LinkedXnames(obj) {
+		if !batch[xname] {
+			dangling = append(dangling, xname)
+		}
+	}
+
+	if len(dangling) == 0 || !stored {
+		return dangling, nil
+	}
+
+	return database.GetMissingXnames(dangling)
+}
+
+// checkReferences checks that obj is only linked to hardware in batch or, if stored is set, in the database. What
+// happens to any other link depends on the ReferenceMode.
+func checkReferences(obj sls_common.GenericHardware, batch map[string]bool, stored bool) error {
+	if referenceMode == ReferencesIgnore {
+		return nil
+	}
+
+	dangling, err := danglingLinks(obj, batch, stored)
+	if err != nil || len(dangling) == 0 {
+		return err
+	}
+
+	err = fmt.Errorf("%w: %s: %s %s", DanglingReference, obj.Xname,
+		sls_common.ExtraPropertiesLinks[obj.Type], strings.Join(dangling, ", "))
+	if referenceMode == ReferencesReport {
+		log.Printf("WARNING: storing %s anyway: %s", obj.Xname, err)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", InvalidHardware, err)
+}
+
+/*
+checkParent checks that the parent of obj is in loaded, whatever the
+ReferenceMode.  SLS files leave out hardware that is only there to hold
+other hardware, like node BMCs and router modules, so the nearest ancestor
+of the parent that is loaded stands in for it as long as it is in the same
+cabinet.  Hardware that isn't in a cabinet, like cabinets themselves and CDU
+switches, has nothing to check.
+*/
+func checkParent(obj sls_common.GenericHardware, loaded map[string]bool) error {
+	parent := base.NormalizeHMSCompID(obj.Parent)
+	for ancestor := parent; ancestor != ""; ancestor = base.GetHMSCompParent(ancestor) {
+		if loaded[ancestor] {
+			return nil
+		}
+		if base.GetHMSType(ancestor) == base.Cabinet {
+			return fmt.Errorf("%w: %s: parent %s is not loaded, and neither is its cabinet %s", InvalidHardware,
+				obj.Xname, parent, ancestor)
+		}
+	}
+
+	return nil
+}
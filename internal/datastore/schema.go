@@ -0,0 +1,60 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+
+	"github.com/Cray-HPE/hms-sls/internal/schema"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var UnknownSchema = errors.New("schema is unknown")
+
+// Schemas returns the JSON Schema of hardware of every type that can be stored, keyed by type, along with the
+// schemas of networks and their parts, keyed by struct name.
+func Schemas() map[string]*schema.Schema {
+	schemas := schema.Networks()
+	for _, hmsType := range sls_common.HMSStringTypes() {
+		if validateType(hmsType) == nil {
+			schemas[string(hmsType)] = schema.Hardware(hmsType)
+		}
+	}
+
+	return schemas
+}
+
+// GetSchema returns the JSON Schema with the given name, see Schemas.
+func GetSchema(name string) (*schema.Schema, error) {
+	hmsType := sls_common.HMSStringType(name)
+	if validateType(hmsType) == nil {
+		return schema.Hardware(hmsType), nil
+	}
+
+	s, ok := schema.Networks()[name]
+	if !ok {
+		return nil, UnknownSchema
+	}
+
+	return s, nil
+}
@@ -0,0 +1,125 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+var InvalidSnapshot = errors.New("invalid snapshot")
+
+// InvalidSnapshotsKeep is returned for a negative number of /loadstate snapshots to keep.
+var InvalidSnapshotsKeep = errors.New("the number of snapshots to keep can't be negative")
+
+// loadStateSnapshotPrefix starts the names of the snapshots LoadState takes, which is followed by the version they
+// are of. Other snapshots can't be called that.
+const loadStateSnapshotPrefix = "loadstate-"
+
+// loadStateSnapshot is the snapshot LoadState takes of what it is about to write over, nil when it takes none.
+var loadStateSnapshot = &database.StateSnapshot{
+	Prefix:  loadStateSnapshotPrefix,
+	Comment: "Taken automatically before /loadstate",
+	Keep:    10,
+}
+
+// SetLoadStateSnapshots sets whether LoadState takes a snapshot before it writes anything from now on, and how many
+// of them are kept. All of them are kept if keep is 0.
+func SetLoadStateSnapshots(enabled bool, keep int) error {
+	if keep < 0 {
+		return InvalidSnapshotsKeep
+	}
+
+	if !enabled {
+		loadStateSnapshot = nil
+		return nil
+	}
+
+	loadStateSnapshot = &database.StateSnapshot{
+		Prefix:  loadStateSnapshotPrefix,
+		Comment: "Taken automatically before /loadstate",
+		Keep:    keep,
+	}
+	return nil
+}
+
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// CreateSnapshot stores all hardware and networks as they are now as a snapshot called name, or returns
+// database.AlreadySuch if there is one.
+func CreateSnapshot(name string, comment string) (sls_common.Snapshot, error) {
+	if !snapshotName.MatchString(name) {
+		return sls_common.Snapshot{}, fmt.Errorf("%w: name must start with a letter or digit and only "+
+			"contain letters, digits, '.', '_' and '-'", InvalidSnapshot)
+	}
+	if strings.HasPrefix(name, loadStateSnapshotPrefix) {
+		return sls_common.Snapshot{}, fmt.Errorf("%w: names starting with %s are kept for the snapshots taken "+
+			"by /loadstate", InvalidSnapshot, loadStateSnapshotPrefix)
+	}
+
+	return database.InsertSnapshot(name, comment)
+}
+
+// GetSnapshots returns every snapshot, oldest first.
+func GetSnapshots() ([]sls_common.Snapshot, error) {
+	return database.GetSnapshots()
+}
+
+// GetSnapshot returns the hardware and networks in the snapshot called name, or nil if there is none.
+func GetSnapshot(name string) (*sls_common.SLSState, error) {
+	_, hardware, networks, err := database.GetSnapshot(name)
+	if err == database.NoSuch {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	state := sls_common.SLSState{
+		Hardware: make(map[string]sls_common.GenericHardware),
+		Networks: make(map[string]sls_common.Network),
+	}
+	for _, obj := range hardware {
+		state.Hardware[obj.Xname] = obj
+	}
+	for _, obj := range networks {
+		state.Networks[obj.Name] = obj
+	}
+
+	return &state, nil
+}
+
+// DeleteSnapshot deletes the snapshot called name, or returns database.NoSuch.
+func DeleteSnapshot(name string) error {
+	return database.DeleteSnapshot(name)
+}
+
+// RestoreSnapshot replaces all hardware and networks with the ones in the snapshot called name, or returns
+// database.NoSuch.
+func RestoreSnapshot(name string) (sls_common.SnapshotRestoreResult, error) {
+	return database.RestoreSnapshot(name)
+}
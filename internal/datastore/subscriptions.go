@@ -0,0 +1,319 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Cray-HPE/hms-sls/internal/database"
+	"github.com/Cray-HPE/hms-sls/internal/subscriptions"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+var InvalidSubscription = errors.New("invalid subscription")
+
+// subscriptionLease is how long an instance of SLS gets to deliver to a subscription before another one can.
+const subscriptionLease = 5 * time.Minute
+
+// subscriptionsPollInterval is how often subscriptions are checked for versions they haven't been notified of, in
+// case a version wasn't announced or the instance that was notifying them went away.
+const subscriptionsPollInterval = time.Minute
+
+// subscriptionNetworks are the networks subscribers can be in even if they are private, see
+// subscriptions.Allowed.
+var subscriptionNetworks []*net.IPNet
+
+// SetSubscriptionNetworks sets the comma separated networks, in CIDR notation, subscribers can be in from now on
+// even if they are private.
+func SetSubscriptionNetworks(networks string) (err error) {
+	subscriptionNetworks, err = subscriptions.ParseNetworks(networks)
+	return
+}
+
+func validateSubscription(subscription sls_common.Subscription) error {
+	callback, err := url.Parse(subscription.URL)
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+		return fmt.Errorf("%w: URL must be an absolute http or https URL", InvalidSubscription)
+	}
+	err = subscriptions.CheckHost(callback.Hostname(), subscriptionNetworks)
+	if err != nil {
+		return fmt.Errorf("%w: %s", InvalidSubscription, err)
+	}
+
+	for _, hardwareType := range subscription.Filter.HardwareTypes {
+		if validateType(hardwareType) != nil {
+			return fmt.Errorf("%w: %s is not a hardware type", InvalidSubscription, hardwareType)
+		}
+	}
+	for _, prefix := range subscription.Filter.XnamePrefixes {
+		if prefix == "" {
+			return fmt.Errorf("%w: xname prefixes can't be empty", InvalidSubscription)
+		}
+	}
+	for _, operation := range subscription.Filter.Operations {
+		if operation != "insert" && operation != "update" && operation != "delete" {
+			return fmt.Errorf("%w: operations must be insert, update or delete", InvalidSubscription)
+		}
+	}
+
+	return nil
+}
+
+/*
+CreateSubscription stores subscription, which is notified of every change
+made from now on that passes its filter.  A secret is made up for it if it
+has none.  The subscription is returned with its ID and secret.
+*/
+func CreateSubscription(subscription sls_common.Subscription) (sls_common.Subscription, error) {
+	err := validateSubscription(subscription)
+	if err != nil {
+		return subscription, err
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return subscription, err
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	return database.InsertSubscription(subscription)
+}
+
+// GetSubscriptions returns every subscription, without its secret.
+func GetSubscriptions() ([]sls_common.Subscription, error) {
+	all, err := database.GetSubscriptions()
+	for i := range all {
+		all[i].Secret = ""
+	}
+	return all, err
+}
+
+// GetSubscription returns the subscription with the given ID without its secret, or nil if there is none.
+func GetSubscription(id int64) (*sls_common.Subscription, error) {
+	subscription, err := database.GetSubscription(id)
+	if err == database.NoSuch {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	subscription.Secret = ""
+	return &subscription, nil
+}
+
+// DeleteSubscription deletes the subscription with the given ID, or returns database.NoSuch.
+func DeleteSubscription(id int64) error {
+	return database.DeleteSubscription(id)
+}
+
+// GetDeadLetters returns the notifications that could not be delivered to the subscription with the given ID, or
+// database.NoSuch if there is no such subscription.
+func GetDeadLetters(id int64) ([]sls_common.DeadLetter, error) {
+	_, err := database.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.GetDeadLetters(id)
+}
+
+// notifySubscription sends subscription a notification for every version after the last one it was notified of
+// with changes passing its filter. Notifications that fail are recorded as dead letters.
+func notifySubscription(ctx context.Context, client *retryablehttp.Client, subscription sls_common.Subscription) error {
+	// Versions commit in order, so every change up to current can already be read and none will turn up later.
+	_, current, err := database.GetVersionRange()
+	if err != nil || current <= subscription.Version {
+		return err
+	}
+
+	var changes []sls_common.Event
+	err = database.GetEvents(subscription.Version, current, subscription.Documents,
+		func(event sls_common.Event) error {
+			changes = append(changes, event)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(changes); {
+		version := changes[start].Version
+		end := start
+		for end < len(changes) && changes[end].Version == version {
+			end++
+		}
+
+		matching := subscriptions.Filter(subscription.Filter, changes[start:end])
+		start = end
+		if len(matching) == 0 {
+			continue
+		}
+
+		notification := sls_common.Notification{
+			SubscriptionID: subscription.ID,
+			Version:        version,
+			Events:         matching,
+		}
+		deliverErr := subscriptions.Deliver(ctx, client, subscription.URL, subscription.Secret, notification)
+		if ctx.Err() != nil {
+			// Shutting down, the next instance to get the lease tries again.
+			return nil
+		}
+		if deliverErr != nil {
+			log.Printf("ERROR: Unable to notify subscription %d of version %d: %s", subscription.ID, version,
+				deliverErr)
+			err = database.InsertDeadLetter(notification, deliverErr.Error())
+			if err != nil {
+				return err
+			}
+		}
+
+		err = database.AdvanceSubscription(subscription.ID, version, subscriptionLease)
+		if err != nil {
+			return err
+		}
+	}
+
+	return database.AdvanceSubscription(subscription.ID, current, subscriptionLease)
+}
+
+// notifySubscriptions brings every subscription this instance can get the lease of up to date.
+func notifySubscriptions(ctx context.Context, client *retryablehttp.Client) {
+	all, err := database.GetSubscriptions()
+	if err != nil {
+		log.Printf("ERROR: Unable to get subscriptions: %s", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range all {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+
+			subscription, err := database.LeaseSubscription(id, subscriptionLease)
+			if subscription == nil || err != nil {
+				if err != nil {
+					log.Printf("ERROR: Unable to lease subscription %d: %s", id, err)
+				}
+				return
+			}
+
+			err = notifySubscription(ctx, client, *subscription)
+			if err != nil {
+				log.Printf("ERROR: Unable to notify subscription %d: %s", id, err)
+			}
+
+			err = database.ReleaseSubscription(id)
+			if err != nil {
+				log.Printf("ERROR: Unable to release subscription %d: %s", id, err)
+			}
+		}(s.ID)
+	}
+	wg.Wait()
+}
+
+/*
+RunSubscriptions notifies subscriptions of the changes made in every version
+that is committed, by this or any other instance of SLS, until stop is
+closed.  Each subscription is notified by one instance at a time, in order of
+version, and a notification can be repeated if an instance goes away while
+sending it.
+*/
+func RunSubscriptions(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client := subscriptions.NewClient(subscriptionNetworks)
+	poll := time.NewTicker(subscriptionsPollInterval)
+	defer poll.Stop()
+
+	var versions <-chan int64
+	defer func() {
+		if versions != nil {
+			UnsubscribeEvents(versions)
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if versions == nil {
+			var err error
+			versions, err = SubscribeEvents()
+			if err != nil {
+				log.Printf("WARNING: Unable to subscribe to versions, only checking every %s: %s",
+					subscriptionsPollInterval, err)
+			}
+		}
+
+		notifySubscriptions(ctx, client)
+
+		select {
+		case <-stop:
+			return
+		case <-poll.C:
+		case _, ok := <-versions:
+			if !ok {
+				versions = nil
+			}
+		}
+
+		// All the versions committed meanwhile are caught up on at once.
+	drain:
+		for versions != nil {
+			select {
+			case _, ok := <-versions:
+				if !ok {
+					versions = nil
+				}
+			default:
+				break drain
+			}
+		}
+	}
+}
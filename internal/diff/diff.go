@@ -0,0 +1,135 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Fields returns every difference between the JSON representations of from and to, down to the individual values
+// in objects and arrays, ordered by key and index.
+func Fields(from interface{}, to interface{}) ([]sls_common.FieldChange, error) {
+	fromValue, err := normalize(from)
+	if err != nil {
+		return nil, err
+	}
+	toValue, err := normalize(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []sls_common.FieldChange{}
+	compare("", fromValue, toValue, &changes)
+	return changes, nil
+}
+
+// normalize turns value into what decoding its JSON representation gives, keeping numbers exact.
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&normalized)
+	return normalized, err
+}
+
+// escape makes token fit in a JSON pointer, see RFC 6901.
+func escape(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func compare(path string, from interface{}, to interface{}, changes *[]sls_common.FieldChange) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		toValue, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(fromValue)+len(toValue))
+		for key := range fromValue {
+			keys = append(keys, key)
+		}
+		for key := range toValue {
+			if _, found := fromValue[key]; !found {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fromField, inFrom := fromValue[key]
+			toField, inTo := toValue[key]
+			keyPath := path + "/" + escape(key)
+			switch {
+			case !inTo:
+				*changes = append(*changes, sls_common.FieldChange{Path: keyPath, Op: OpRemove, From: fromField})
+			case !inFrom:
+				*changes = append(*changes, sls_common.FieldChange{Path: keyPath, Op: OpAdd, To: toField})
+			default:
+				compare(keyPath, fromField, toField, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+		toValue, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(fromValue) || i < len(toValue); i++ {
+			indexPath := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(toValue):
+				*changes = append(*changes, sls_common.FieldChange{Path: indexPath, Op: OpRemove, From: fromValue[i]})
+			case i >= len(fromValue):
+				*changes = append(*changes, sls_common.FieldChange{Path: indexPath, Op: OpAdd, To: toValue[i]})
+			default:
+				compare(indexPath, fromValue[i], toValue[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, sls_common.FieldChange{Path: path, Op: OpReplace, From: from, To: to})
+	}
+}
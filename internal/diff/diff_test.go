@@ -0,0 +1,130 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+type DiffTestSuite struct {
+	suite.Suite
+}
+
+func (suite *DiffTestSuite) TestFields() {
+	from := sls_common.GenericHardware{
+		Xname: "x3000c0s1b0n0",
+		Type:  "comptype_node",
+		ExtraPropertiesRaw: map[string]interface{}{
+			"NID":     1,
+			"Role":    "Compute",
+			"Aliases": []string{"nid000001"},
+			"a/b~c":   true,
+		},
+	}
+	to := sls_common.GenericHardware{
+		Xname: "x3000c0s1b0n0",
+		Type:  "comptype_node",
+		ExtraPropertiesRaw: map[string]interface{}{
+			"NID":     2,
+			"Aliases": []string{"nid000002", "uan01"},
+			"SubRole": "UAN",
+		},
+	}
+
+	changes, err := Fields(from, to)
+	suite.NoError(err)
+	suite.Equal([]sls_common.FieldChange{
+		{Path: "/ExtraProperties/Aliases/0", Op: OpReplace, From: "nid000001", To: "nid000002"},
+		{Path: "/ExtraProperties/Aliases/1", Op: OpAdd, To: "uan01"},
+		{Path: "/ExtraProperties/NID", Op: OpReplace, From: json.Number("1"), To: json.Number("2")},
+		{Path: "/ExtraProperties/Role", Op: OpRemove, From: "Compute"},
+		{Path: "/ExtraProperties/SubRole", Op: OpAdd, To: "UAN"},
+		{Path: "/ExtraProperties/a~1b~0c", Op: OpRemove, From: true},
+	}, changes)
+
+	changes, err = Fields(from, from)
+	suite.NoError(err)
+	suite.Empty(changes)
+}
+
+func (suite *DiffTestSuite) TestFields_TypeChange() {
+	changes, err := Fields(
+		map[string]interface{}{"ExtraProperties": nil},
+		map[string]interface{}{"ExtraProperties": map[string]interface{}{"Role": "Compute"}})
+	suite.NoError(err)
+	suite.Equal([]sls_common.FieldChange{{
+		Path: "/ExtraProperties",
+		Op:   OpReplace,
+		To:   map[string]interface{}{"Role": "Compute"},
+	}}, changes)
+}
+
+func (suite *DiffTestSuite) TestUnified() {
+	d := sls_common.SLSDiff{
+		From: "41",
+		To:   "42",
+		Hardware: sls_common.HardwareDiff{
+			Removed: []sls_common.GenericHardware{{Xname: "x3000c0s2b0"}},
+			Modified: []sls_common.ObjectDiff{{
+				Name: "x3000c0s1b0n0",
+				Changes: []sls_common.FieldChange{
+					{Path: "/ExtraProperties/NID", Op: OpReplace, From: 1, To: 2},
+					{Path: "/ExtraProperties/Role", Op: OpRemove, From: "Compute"},
+				},
+			}},
+		},
+		Networks: sls_common.NetworkDiff{
+			Modified: []sls_common.ObjectDiff{{
+				Name:    "HMN",
+				Changes: []sls_common.FieldChange{{Path: "/FullName", Op: OpAdd, To: "HMN"}},
+			}},
+		},
+	}
+
+	suite.Equal(`--- 41
++++ 42
+@@ hardware x3000c0s1b0n0 modified @@
+-/ExtraProperties/NID: 1
++/ExtraProperties/NID: 2
+-/ExtraProperties/Role: "Compute"
+@@ hardware x3000c0s2b0 removed @@
+-{
+-  "Parent": "",
+-  "Xname": "x3000c0s2b0",
+-  "Type": "",
+-  "Class": "",
+-  "TypeString": ""
+-}
+@@ network HMN modified @@
++/FullName: "HMN"
+`, Unified(d))
+}
+
+func TestDiffSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
@@ -0,0 +1,127 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+// hunk is everything that changed about one object.
+type hunk struct {
+	name  string
+	state string
+	lines []string
+}
+
+// objectLines returns the lines of the indented JSON of object, each prefixed with prefix.
+func objectLines(prefix string, object interface{}) []string {
+	data, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return []string{prefix + fmt.Sprint(object)}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return lines
+}
+
+// valueString returns value as compact JSON.
+func valueString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// changeLines returns a line for what a field was and one for what it became, whichever there is.
+func changeLines(changes []sls_common.FieldChange) []string {
+	var lines []string
+	for _, change := range changes {
+		if change.Op != OpAdd {
+			lines = append(lines, "-"+change.Path+": "+valueString(change.From))
+		}
+		if change.Op != OpRemove {
+			lines = append(lines, "+"+change.Path+": "+valueString(change.To))
+		}
+	}
+	return lines
+}
+
+func writeHunks(builder *strings.Builder, kind string, hunks []hunk) {
+	sort.SliceStable(hunks, func(i, j int) bool {
+		return hunks[i].name < hunks[j].name
+	})
+
+	for _, h := range hunks {
+		fmt.Fprintf(builder, "@@ %s %s %s @@\n", kind, h.name, h.state)
+		for _, line := range h.lines {
+			builder.WriteString(line)
+			builder.WriteString("\n")
+		}
+	}
+}
+
+/*
+Unified renders d for people to review, like a unified diff.  Every object
+that changed gets a hunk headed with its kind and name: the whole object
+for one that was added or removed, and the old and new value of every
+field that changed for one that was modified.
+*/
+func Unified(d sls_common.SLSDiff) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", d.From, d.To)
+
+	var hardware []hunk
+	for _, added := range d.Hardware.Added {
+		hardware = append(hardware, hunk{added.Xname, "added", objectLines("+", added)})
+	}
+	for _, removed := range d.Hardware.Removed {
+		hardware = append(hardware, hunk{removed.Xname, "removed", objectLines("-", removed)})
+	}
+	for _, modified := range d.Hardware.Modified {
+		hardware = append(hardware, hunk{modified.Name, "modified", changeLines(modified.Changes)})
+	}
+	writeHunks(&builder, "hardware", hardware)
+
+	var networks []hunk
+	for _, added := range d.Networks.Added {
+		networks = append(networks, hunk{added.Name, "added", objectLines("+", added)})
+	}
+	for _, removed := range d.Networks.Removed {
+		networks = append(networks, hunk{removed.Name, "removed", objectLines("-", removed)})
+	}
+	for _, modified := range d.Networks.Modified {
+		networks = append(networks, hunk{modified.Name, "modified", changeLines(modified.Changes)})
+	}
+	writeHunks(&builder, "network", networks)
+
+	return builder.String()
+}
@@ -0,0 +1,98 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package events
+
+import (
+	"sync"
+)
+
+/*
+Broker hands every version published to it to all of its subscribers.  A
+subscriber that falls behind by more versions than its channel holds is
+dropped rather than holding up the others; its channel is closed, as is
+every channel once the broker is closed.
+*/
+type Broker struct {
+	mutex       sync.Mutex
+	closed      bool
+	subscribers map[<-chan int64]chan int64
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[<-chan int64]chan int64),
+	}
+}
+
+// Subscribe returns a channel receiving every version published from now on, holding up to size of them.
+func (broker *Broker) Subscribe(size int) <-chan int64 {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	subscriber := make(chan int64, size)
+	if broker.closed {
+		close(subscriber)
+		return subscriber
+	}
+
+	broker.subscribers[subscriber] = subscriber
+	return subscriber
+}
+
+// Unsubscribe stops sending versions to subscriber and closes it, if that didn't happen already.
+func (broker *Broker) Unsubscribe(subscriber <-chan int64) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	if channel, found := broker.subscribers[subscriber]; found {
+		delete(broker.subscribers, subscriber)
+		close(channel)
+	}
+}
+
+// Publish sends version to every subscriber.
+func (broker *Broker) Publish(version int64) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	for subscriber, channel := range broker.subscribers {
+		select {
+		case channel <- version:
+		default:
+			delete(broker.subscribers, subscriber)
+			close(channel)
+		}
+	}
+}
+
+// Close closes every subscriber, and those that subscribe later right away.
+func (broker *Broker) Close() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.closed = true
+	for subscriber, channel := range broker.subscribers {
+		delete(broker.subscribers, subscriber)
+		close(channel)
+	}
+}
@@ -0,0 +1,119 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BrokerTestSuite struct {
+	suite.Suite
+}
+
+// received returns what is waiting in subscriber and whether it is still open.
+func received(subscriber <-chan int64) (versions []int64, open bool) {
+	for {
+		select {
+		case version, ok := <-subscriber:
+			if !ok {
+				return versions, false
+			}
+			versions = append(versions, version)
+		default:
+			return versions, true
+		}
+	}
+}
+
+func (suite *BrokerTestSuite) TestPublish() {
+	broker := NewBroker()
+	first := broker.Subscribe(10)
+	broker.Publish(1)
+	second := broker.Subscribe(10)
+	broker.Publish(2)
+	broker.Publish(3)
+
+	versions, open := received(first)
+	suite.Equal([]int64{1, 2, 3}, versions)
+	suite.True(open)
+
+	versions, open = received(second)
+	suite.Equal([]int64{2, 3}, versions)
+	suite.True(open)
+
+	broker.Unsubscribe(first)
+	broker.Publish(4)
+	versions, open = received(first)
+	suite.Empty(versions)
+	suite.False(open)
+
+	versions, _ = received(second)
+	suite.Equal([]int64{4}, versions)
+
+	// Unsubscribing twice is fine
+	broker.Unsubscribe(first)
+}
+
+func (suite *BrokerTestSuite) TestSlowSubscriber() {
+	broker := NewBroker()
+	slow := broker.Subscribe(2)
+	fast := broker.Subscribe(10)
+
+	for version := int64(1); version <= 3; version++ {
+		broker.Publish(version)
+	}
+
+	versions, open := received(slow)
+	suite.Equal([]int64{1, 2}, versions)
+	suite.False(open)
+
+	versions, open = received(fast)
+	suite.Equal([]int64{1, 2, 3}, versions)
+	suite.True(open)
+
+	broker.Unsubscribe(slow)
+}
+
+func (suite *BrokerTestSuite) TestClose() {
+	broker := NewBroker()
+	before := broker.Subscribe(10)
+	broker.Publish(1)
+	broker.Close()
+
+	versions, open := received(before)
+	suite.Equal([]int64{1}, versions)
+	suite.False(open)
+
+	_, open = received(broker.Subscribe(10))
+	suite.False(open)
+
+	// Nothing to send to any more
+	broker.Publish(2)
+	broker.Close()
+}
+
+func TestBrokerSuite(t *testing.T) {
+	suite.Run(t, new(BrokerTestSuite))
+}
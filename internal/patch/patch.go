@@ -0,0 +1,475 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7396
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+var InvalidPatch = errors.New("patch document is invalid")
+var TestFailed = errors.New("patch test operation failed")
+var PathNotFound = errors.New("patch path does not exist")
+var UnsupportedContentType = errors.New("unsupported patch content type")
+
+// Operation is a single RFC 6902 JSON Patch operation.
+type Operation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// UnmarshalJSON decodes operation, keeping a null value apart from a missing one. Value is only nil if there was no
+// value at all.
+func (operation *Operation) UnmarshalJSON(data []byte) error {
+	type plainOperation Operation
+	var decoded struct {
+		plainOperation
+		Value json.RawMessage `json:"value"`
+	}
+
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*operation = Operation(decoded.plainOperation)
+	if decoded.Value != nil {
+		operation.Value = &decoded.Value
+	}
+
+	return nil
+}
+
+// Apply patches the given JSON document with the patch body, choosing between JSON Merge Patch and JSON Patch
+// based on the Content-Type of the request.
+func Apply(contentType string, doc []byte, patchBody []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", UnsupportedContentType, contentType)
+	}
+
+	switch mediaType {
+	case MergePatchContentType:
+		return ApplyMergePatch(doc, patchBody)
+	case JSONPatchContentType:
+		return ApplyJSONPatch(doc, patchBody)
+	default:
+		return nil, fmt.Errorf("%w: %s", UnsupportedContentType, mediaType)
+	}
+}
+
+// ApplyMergePatch applies an RFC 7396 JSON Merge Patch to the given document.
+func ApplyMergePatch(doc []byte, patchBody []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	mergePatch, err := decode(patchBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidPatch, err)
+	}
+
+	return json.Marshal(merge(target, mergePatch))
+}
+
+func merge(target interface{}, mergePatch interface{}) interface{} {
+	patchObj, ok := mergePatch.(map[string]interface{})
+	if !ok {
+		return mergePatch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = merge(targetObj[key], value)
+		}
+	}
+
+	return targetObj
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to the given document. Operations are applied in order and if any of
+// them fails the whole patch fails.
+func ApplyJSONPatch(doc []byte, patchBody []byte) ([]byte, error) {
+	var operations []Operation
+	err := json.Unmarshal(patchBody, &operations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidPatch, err)
+	}
+
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		target, err = applyOperation(target, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func applyOperation(doc interface{}, operation Operation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "replace":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", InvalidPatch)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case "test":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, TestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op '%s'", InvalidPatch, operation.Op)
+	}
+}
+
+func (operation Operation) value() (interface{}, error) {
+	if operation.Value == nil {
+		return nil, fmt.Errorf("%w: missing value", InvalidPatch)
+	}
+
+	value, err := decode(*operation.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidPatch, err)
+	}
+
+	return value, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid JSON pointer '%s'", InvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+func isProperPrefix(prefix []string, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func arrayIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: array index '%s'", PathNotFound, token)
+	}
+	return index, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member '%s'", PathNotFound, token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("%w: '%s' is not a container", PathNotFound, token)
+		}
+	}
+
+	return current, nil
+}
+
+// update walks to the parent of the last token in path and hands the parent container to the leaf function, storing
+// whatever container it returns back in place. This is needed because appending to or removing from a slice can
+// produce a new slice.
+func update(doc interface{}, path []string,
+	leaf func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return leaf(doc, path[0])
+	}
+
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member '%s'", PathNotFound, token)
+		}
+		newChild, err := update(child, path[1:], leaf)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = newChild
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		newChild, err := update(node[index], path[1:], leaf)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = newChild
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s' is not a container", PathNotFound, token)
+	}
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			index, err := arrayIndex(token, len(node)+1)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: '%s' is not a container", PathNotFound, token)
+		}
+	})
+}
+
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", InvalidPatch)
+	}
+
+	var removed interface{}
+	newDoc, err := update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member '%s'", PathNotFound, token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			removed = node[index]
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: '%s' is not a container", PathNotFound, token)
+		}
+	})
+
+	return newDoc, removed, err
+}
+
+func decode(data []byte) (interface{}, error) {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	// The document has to be a single value, like it would be for json.Unmarshal.
+	var extra interface{}
+	if decoder.Decode(&extra) != io.EOF {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+
+	return value, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		newMap := make(map[string]interface{}, len(node))
+		for key, child := range node {
+			newMap[key] = deepCopy(child)
+		}
+		return newMap
+	case []interface{}:
+		newSlice := make([]interface{}, len(node))
+		for i, child := range node {
+			newSlice[i] = deepCopy(child)
+		}
+		return newSlice
+	default:
+		return value
+	}
+}
+
+// equal compares two decoded JSON values the way RFC 6902 section 4.6 describes, numbers are compared by value and
+// not by their textual representation.
+func equal(a interface{}, b interface{}) bool {
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for key, child := range aValue {
+			other, ok := bValue[key]
+			if !ok || !equal(child, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for i := range aValue {
+			if !equal(aValue[i], bValue[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bValue, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		aFloat, aErr := aValue.Float64()
+		bFloat, bErr := bValue.Float64()
+		if aErr != nil || bErr != nil {
+			return aValue == bValue
+		}
+		return aFloat == bFloat
+	default:
+		return a == b
+	}
+}
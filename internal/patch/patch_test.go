@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package patch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PatchTestSuite struct {
+	suite.Suite
+}
+
+const networkDoc = `{
+  "Name": "HMN",
+  "FullName": "Hardware Management Network",
+  "IPRanges": ["10.254.0.0/17"],
+  "Type": "ethernet",
+  "ExtraProperties": {
+    "CIDR": "10.254.0.0/17",
+    "VlanRange": [4],
+    "Subnets": [
+      {
+        "Name": "network_hardware",
+        "CIDR": "10.254.0.0/17",
+        "VlanID": 4,
+        "IPReservations": [
+          {"Name": "sw-spine-001", "IPAddress": "10.254.0.2"}
+        ]
+      }
+    ]
+  }
+}`
+
+func (suite *PatchTestSuite) TestMergePatch() {
+	patched, err := ApplyMergePatch([]byte(networkDoc),
+		[]byte(`{"FullName": "HMN", "ExtraProperties": {"Comment": "patched", "VlanRange": null}}`))
+	suite.NoError(err)
+	suite.JSONEq(`{
+	  "Name": "HMN",
+	  "FullName": "HMN",
+	  "IPRanges": ["10.254.0.0/17"],
+	  "Type": "ethernet",
+	  "ExtraProperties": {
+	    "CIDR": "10.254.0.0/17",
+	    "Comment": "patched",
+	    "Subnets": [
+	      {
+	        "Name": "network_hardware",
+	        "CIDR": "10.254.0.0/17",
+	        "VlanID": 4,
+	        "IPReservations": [
+	          {"Name": "sw-spine-001", "IPAddress": "10.254.0.2"}
+	        ]
+	      }
+	    ]
+	  }
+	}`, string(patched))
+}
+
+func (suite *PatchTestSuite) TestMergePatch_Invalid() {
+	_, err := ApplyMergePatch([]byte(networkDoc), []byte(`{"FullName": `))
+	suite.True(errors.Is(err, InvalidPatch))
+
+	_, err = ApplyMergePatch([]byte(networkDoc), []byte(`{"FullName": "HMN"} {"Name": "CAN"}`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+
+	_, err = ApplyMergePatch([]byte(networkDoc), []byte(`{"FullName": "HMN"} ]`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+}
+
+func (suite *PatchTestSuite) TestJSONPatch_Reservations() {
+	patched, err := ApplyJSONPatch([]byte(networkDoc), []byte(`[
+	  {"op": "test", "path": "/ExtraProperties/Subnets/0/Name", "value": "network_hardware"},
+	  {"op": "add", "path": "/ExtraProperties/Subnets/0/IPReservations/-",
+	   "value": {"Name": "sw-spine-002", "IPAddress": "10.254.0.3"}},
+	  {"op": "replace", "path": "/ExtraProperties/Subnets/0/VlanID", "value": 5},
+	  {"op": "copy", "from": "/ExtraProperties/Subnets/0/IPReservations/0", "path": "/ExtraProperties/Subnets/0/IPReservations/0"},
+	  {"op": "remove", "path": "/ExtraProperties/Subnets/0/IPReservations/1"},
+	  {"op": "move", "from": "/ExtraProperties/CIDR", "path": "/ExtraProperties/Comment"}
+	]`))
+	suite.NoError(err)
+	suite.JSONEq(`{
+	  "Name": "HMN",
+	  "FullName": "Hardware Management Network",
+	  "IPRanges": ["10.254.0.0/17"],
+	  "Type": "ethernet",
+	  "ExtraProperties": {
+	    "Comment": "10.254.0.0/17",
+	    "VlanRange": [4],
+	    "Subnets": [
+	      {
+	        "Name": "network_hardware",
+	        "CIDR": "10.254.0.0/17",
+	        "VlanID": 5,
+	        "IPReservations": [
+	          {"Name": "sw-spine-001", "IPAddress": "10.254.0.2"},
+	          {"Name": "sw-spine-002", "IPAddress": "10.254.0.3"}
+	        ]
+	      }
+	    ]
+	  }
+	}`, string(patched))
+}
+
+func (suite *PatchTestSuite) TestJSONPatch_EscapedPointer() {
+	patched, err := ApplyJSONPatch([]byte(`{"a/b": {"c~d": 1}}`),
+		[]byte(`[{"op": "replace", "path": "/a~1b/c~0d", "value": 2}]`))
+	suite.NoError(err)
+	suite.JSONEq(`{"a/b": {"c~d": 2}}`, string(patched))
+}
+
+func (suite *PatchTestSuite) TestJSONPatch_TestNumbers() {
+	_, err := ApplyJSONPatch([]byte(`{"VlanID": 4}`), []byte(`[{"op": "test", "path": "/VlanID", "value": 4.0}]`))
+	suite.NoError(err)
+}
+
+func (suite *PatchTestSuite) TestJSONPatch_NullValue() {
+	patched, err := ApplyJSONPatch([]byte(`{"a": 1, "b": null}`), []byte(`[
+	  {"op": "test", "path": "/b", "value": null},
+	  {"op": "replace", "path": "/a", "value": null},
+	  {"op": "add", "path": "/c", "value": null}
+	]`))
+	suite.NoError(err)
+	suite.JSONEq(`{"a": null, "b": null, "c": null}`, string(patched))
+}
+
+func (suite *PatchTestSuite) TestJSONPatch_Errors() {
+	_, err := ApplyJSONPatch([]byte(networkDoc),
+		[]byte(`[{"op": "test", "path": "/Name", "value": "NMN"}]`))
+	suite.True(errors.Is(err, TestFailed), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc),
+		[]byte(`[{"op": "remove", "path": "/ExtraProperties/Subnets/3"}]`))
+	suite.True(errors.Is(err, PathNotFound), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc),
+		[]byte(`[{"op": "replace", "path": "/DoesNotExist", "value": 1}]`))
+	suite.True(errors.Is(err, PathNotFound), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc),
+		[]byte(`[{"op": "move", "from": "/ExtraProperties", "path": "/ExtraProperties/Subnets"}]`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc), []byte(`[{"op": "frobnicate", "path": "/Name"}]`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc), []byte(`[{"op": "add", "path": "/Name"}]`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+
+	_, err = ApplyJSONPatch([]byte(networkDoc), []byte(`{"op": "add"}`))
+	suite.True(errors.Is(err, InvalidPatch), "%v", err)
+}
+
+func (suite *PatchTestSuite) TestApply_ContentType() {
+	_, err := Apply("application/merge-patch+json; charset=utf-8", []byte(networkDoc), []byte(`{}`))
+	suite.NoError(err)
+
+	_, err = Apply(JSONPatchContentType, []byte(networkDoc), []byte(`[]`))
+	suite.NoError(err)
+
+	_, err = Apply("application/json", []byte(networkDoc), []byte(`{}`))
+	suite.True(errors.Is(err, UnsupportedContentType))
+}
+
+func TestPatchSuite(t *testing.T) {
+	suite.Run(t, new(PatchTestSuite))
+}
@@ -0,0 +1,218 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+// Package schema generates JSON Schema documents from the structs in pkg/sls-common, using the same tables the
+// datastore validates hardware with, so the documents describe what SLS really accepts.
+package schema
+
+import (
+	"encoding"
+	"reflect"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+)
+
+// Draft is the version of JSON Schema the documents are written in.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema. Only the keywords needed to describe the structs in pkg/sls-common are supported.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// generator builds the schemas of Go types. Named structs are put in definitions once and referred to with $ref.
+type generator struct {
+	// closed objects don't allow any properties besides the fields of their struct.
+	closed      bool
+	definitions map[string]*Schema
+}
+
+func newGenerator() *generator {
+	return &generator{definitions: make(map[string]*Schema)}
+}
+
+func limit(v int64) *int64 {
+	return &v
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Things like net.IP have their own text form.
+	if reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Minimum: limit(-1 << (t.Bits() - 1)), Maximum: limit(1<<(t.Bits()-1) - 1)}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Minimum: limit(0), Maximum: limit(1<<t.Bits() - 1)}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: limit(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		// A nil slice is marshalled as null.
+		return &Schema{Type: []string{"array", "null"}, Items: g.schema(t.Elem())}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, nil)
+		}
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// Claim the name first in case the struct refers to itself.
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.object(t, nil)
+		}
+		return &Schema{Ref: "#/definitions/" + t.Name()}
+	}
+
+	// An interface{} can hold anything.
+	return &Schema{}
+}
+
+// object returns the schema of struct t, which has a property for every field that is (un)marshalled. The required
+// properties can't be null either.
+func (g *generator) object(t reflect.Type, required []string) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+		Required:   required,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := sls_common.JSONFieldName(field); name != "" {
+			s.Properties[name] = g.schema(field.Type)
+		}
+	}
+	for _, name := range required {
+		if types, ok := s.Properties[name].Type.([]string); ok {
+			s.Properties[name].Type = types[0]
+		}
+	}
+	if g.closed {
+		s.AdditionalProperties = false
+	}
+
+	return s
+}
+
+// document finishes s as a top level schema.
+func (g *generator) document(title string, s *Schema) *Schema {
+	s.Schema = Draft
+	s.Title = title
+	if len(g.definitions) > 0 {
+		s.Definitions = g.definitions
+	}
+
+	return s
+}
+
+// Hardware returns the schema of hardware of type hmsType. Its ExtraProperties are the fields of the struct for
+// hmsType in sls_common.ExtraPropertiesTypes and nothing else, and the ones in sls_common.ExtraPropertiesRequired
+// must be there. Types without a struct can't have any ExtraProperties, unless they are in
+// sls_common.ExtraPropertiesUnchecked.
+func Hardware(hmsType sls_common.HMSStringType) *Schema {
+	g := newGenerator()
+	s := g.object(reflect.TypeOf(sls_common.GenericHardware{}), []string{"Xname", "Type", "TypeString"})
+	s.Properties["Type"] = &Schema{Type: "string", Const: hmsType}
+	s.Properties["TypeString"] = &Schema{Type: "string", Const: sls_common.HMSStringTypeToHMSType(hmsType)}
+	s.Properties["Class"] = &Schema{
+		Type: "string",
+		Enum: []interface{}{sls_common.ClassRiver, sls_common.ClassMountain, sls_common.ClassHill},
+	}
+	// Only ever filled in by /dumpstate.
+	delete(s.Properties, "VaultData")
+
+	// Unlike the hardware object itself, ExtraProperties are checked field by field.
+	g.closed = true
+	extraProperties := &Schema{Type: "object", AdditionalProperties: false}
+	if sls_common.ExtraPropertiesUnchecked[hmsType] {
+		extraProperties = &Schema{Type: "object"}
+	} else if propertiesType, ok := sls_common.ExtraPropertiesTypes[hmsType]; ok {
+		extraProperties = g.object(propertiesType, sls_common.ExtraPropertiesRequired[hmsType])
+		extraProperties.Title = propertiesType.Name()
+	}
+	s.Properties["ExtraProperties"] = extraProperties
+	if len(extraProperties.Required) > 0 {
+		s.Required = append(s.Required, "ExtraProperties")
+	}
+
+	return g.document(string(hmsType), s)
+}
+
+// Network returns the schema of a network. SLS only checks the Name and Type of networks, the shape of the rest is
+// what SLS and its clients expect but other properties are allowed.
+func Network() *Schema {
+	g := newGenerator()
+	s := g.object(reflect.TypeOf(sls_common.Network{}), []string{"Name", "Type"})
+	s.Properties["Name"].Pattern = "^[^ ]*$"
+	s.Properties["ExtraProperties"] = g.schema(reflect.TypeOf(sls_common.NetworkExtraProperties{}))
+
+	return g.document("Network", s)
+}
+
+// Struct returns the schema of the struct v is an instance of.
+func Struct(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	g := newGenerator()
+
+	return g.document(t.Name(), g.object(t, nil))
+}
+
+// Networks returns the schema of a network and the schemas of the parts of one, keyed by struct name.
+func Networks() map[string]*Schema {
+	return map[string]*Schema{
+		"Network":                Network(),
+		"NetworkExtraProperties": Struct(sls_common.NetworkExtraProperties{}),
+		"IPV4Subnet":             Struct(sls_common.IPV4Subnet{}),
+		"IPReservation":          Struct(sls_common.IPReservation{}),
+	}
+}
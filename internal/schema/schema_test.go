@@ -0,0 +1,120 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SchemaTestSuite) TestHardware() {
+	s := Hardware(sls_common.Node)
+	suite.Equal(Draft, s.Schema)
+	suite.Equal("comptype_node", s.Title)
+	suite.Equal([]string{"Xname", "Type", "TypeString"}, s.Required)
+	suite.Equal(sls_common.Node, s.Properties["Type"].Const)
+	suite.NotContains(s.Properties, "VaultData")
+
+	extraProperties := s.Properties["ExtraProperties"]
+	suite.Equal("ComptypeNode", extraProperties.Title)
+	suite.Equal(false, extraProperties.AdditionalProperties)
+	suite.Len(extraProperties.Properties, 4)
+	suite.Equal("integer", extraProperties.Properties["NID"].Type)
+	suite.Equal([]string{"array", "null"}, extraProperties.Properties["Aliases"].Type)
+	suite.Equal("string", extraProperties.Properties["Aliases"].Items.Type)
+}
+
+func (suite *SchemaTestSuite) TestHardware_Required() {
+	s := Hardware(sls_common.MgmtSwitchConnector)
+	suite.Equal([]string{"Xname", "Type", "TypeString", "ExtraProperties"}, s.Required)
+
+	extraProperties := s.Properties["ExtraProperties"]
+	suite.Equal([]string{"NodeNics"}, extraProperties.Required)
+	suite.Equal("array", extraProperties.Properties["NodeNics"].Type)
+	suite.Equal("string", extraProperties.Properties["VendorName"].Type)
+}
+
+func (suite *SchemaTestSuite) TestHardware_NoExtraProperties() {
+	s := Hardware(sls_common.Chassis)
+	suite.Equal(&Schema{Type: "object", AdditionalProperties: false}, s.Properties["ExtraProperties"])
+	suite.Nil(s.Definitions)
+}
+
+func (suite *SchemaTestSuite) TestHardware_UncheckedExtraProperties() {
+	s := Hardware(sls_common.CabinetPDUPowerConnector)
+	suite.Equal(&Schema{Type: "object"}, s.Properties["ExtraProperties"])
+}
+
+func (suite *SchemaTestSuite) TestHardware_Definitions() {
+	s := Hardware(sls_common.Cabinet)
+	networks := s.Properties["ExtraProperties"].Properties["Networks"]
+	suite.Equal(&Schema{Ref: "#/definitions/CabinetNetworks"},
+		networks.AdditionalProperties.(*Schema).AdditionalProperties)
+
+	suite.Contains(s.Definitions, "CabinetNetworks")
+	suite.Equal(false, s.Definitions["CabinetNetworks"].AdditionalProperties)
+	suite.Equal("integer", s.Definitions["CabinetNetworks"].Properties["VLan"].Type)
+}
+
+func (suite *SchemaTestSuite) TestNetworks() {
+	schemas := Networks()
+	suite.Len(schemas, 4)
+
+	network := schemas["Network"]
+	suite.Equal([]string{"Name", "Type"}, network.Required)
+	suite.Equal(&Schema{Ref: "#/definitions/NetworkExtraProperties"}, network.Properties["ExtraProperties"])
+	suite.Contains(network.Definitions, "NetworkExtraProperties")
+	suite.Contains(network.Definitions, "IPV4Subnet")
+	suite.Contains(network.Definitions, "IPReservation")
+	// SLS doesn't reject unknown network properties
+	suite.Nil(network.AdditionalProperties)
+
+	subnet := schemas["IPV4Subnet"]
+	suite.Equal("IPV4Subnet", subnet.Title)
+	suite.Equal(&Schema{Type: "integer", Minimum: limit(-32768), Maximum: limit(32767)}, subnet.Properties["VlanID"])
+	suite.Equal(&Schema{Type: "string"}, subnet.Properties["Gateway"])
+	suite.Equal(&Schema{Ref: "#/definitions/IPReservation"}, subnet.Properties["IPReservations"].Items)
+}
+
+func (suite *SchemaTestSuite) TestMarshal() {
+	ba, err := json.Marshal(Hardware(sls_common.NodePowerConnector))
+	suite.NoError(err)
+
+	var doc map[string]interface{}
+	suite.NoError(json.Unmarshal(ba, &doc))
+	suite.Equal(Draft, doc["$schema"])
+	extraProperties := doc["properties"].(map[string]interface{})["ExtraProperties"].(map[string]interface{})
+	suite.Equal(false, extraProperties["additionalProperties"])
+	suite.Equal([]interface{}{"PoweredBy"}, extraProperties["required"])
+}
+
+func TestSchemaSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
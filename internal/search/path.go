@@ -0,0 +1,102 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Step is one element of a Path. It either looks up Key in an object, looks up Key as an index in an array, or for
+// Each steps fans out over every element of an array.
+type Step struct {
+	Key   string
+	Index bool
+	Each  bool
+}
+
+// Path is a parsed ExtraProperties field.
+type Path []Step
+
+// ParsePath parses the part of an ExtraProperties field after ExtraPropertiesPrefix. Keys are separated by dots and
+// may be followed by any number of [] or [n], so
+//
+//	Subnets[].IPReservations[0].Name
+//
+// is the Name of the first IP reservation of any subnet.
+func ParsePath(field string) (Path, error) {
+	if field == "" {
+		return nil, fmt.Errorf("%w: ExtraProperties search does not include field", InvalidFilter)
+	}
+
+	var path Path
+	for _, segment := range strings.Split(field, ".") {
+		key := segment
+		brackets := ""
+		if i := strings.IndexAny(segment, "[]"); i >= 0 {
+			key, brackets = segment[:i], segment[i:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty key in %s", InvalidFilter, field)
+		}
+		path = append(path, Step{Key: key})
+
+		for brackets != "" {
+			end := strings.IndexByte(brackets, ']')
+			if brackets[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("%w: malformed array index in %s", InvalidFilter, field)
+			}
+
+			index := brackets[1:end]
+			if index == "" {
+				path = append(path, Step{Each: true})
+			} else if _, err := strconv.ParseUint(index, 10, 31); err == nil {
+				path = append(path, Step{Key: index, Index: true})
+			} else {
+				return nil, fmt.Errorf("%w: array index %s in %s is not a number", InvalidFilter, index, field)
+			}
+
+			brackets = brackets[end+1:]
+		}
+	}
+
+	return path, nil
+}
+
+func (path Path) String() string {
+	var s strings.Builder
+	for i, step := range path {
+		switch {
+		case step.Each:
+			s.WriteString("[]")
+		case step.Index:
+			s.WriteString("[" + step.Key + "]")
+		default:
+			if i > 0 {
+				s.WriteString(".")
+			}
+			s.WriteString(step.Key)
+		}
+	}
+	return s.String()
+}
@@ -0,0 +1,404 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Operator is the comparison made between a field and its value(s).
+type Operator string
+
+const (
+	Equal        Operator = "="
+	Less         Operator = "<"
+	LessEqual    Operator = "<="
+	Greater      Operator = ">"
+	GreaterEqual Operator = ">="
+	In           Operator = "IN"
+	Like         Operator = "LIKE" // Glob, * matches any run of characters and ? any single character
+)
+
+// ExtraPropertiesPrefix marks a field as a key of ExtraProperties rather than a column.
+const ExtraPropertiesPrefix = "extra_properties."
+
+var InvalidFilter = errors.New("search filter is invalid")
+
+// Expr is a parsed search filter. It is one of And, Or, Not or Comparison.
+type Expr interface {
+	isExpr()
+}
+
+// And matches when all of its expressions match.
+type And []Expr
+
+// Or matches when any of its expressions match.
+type Or []Expr
+
+// Not matches when its expression doesn't.
+type Not struct {
+	Expr Expr
+}
+
+// Comparison matches a single field against one value, or a list of values for In.
+type Comparison struct {
+	Field  string
+	Op     Operator
+	Values []string
+}
+
+func (And) isExpr()        {}
+func (Or) isExpr()         {}
+func (Not) isExpr()        {}
+func (Comparison) isExpr() {}
+
+// Walk calls fn for every comparison in expr, in order. fn may modify the comparison in place.
+func Walk(expr Expr, fn func(comparison *Comparison)) Expr {
+	switch e := expr.(type) {
+	case And:
+		for i := range e {
+			e[i] = Walk(e[i], fn)
+		}
+		return e
+	case Or:
+		for i := range e {
+			e[i] = Walk(e[i], fn)
+		}
+		return e
+	case Not:
+		e.Expr = Walk(e.Expr, fn)
+		return e
+	case Comparison:
+		fn(&e)
+		return e
+	}
+
+	return expr
+}
+
+// Parse turns a filter such as
+//
+//	extra_properties.NID >= 1000 AND (Role = Compute OR Role IN (Application, Service)) AND NOT xname LIKE x1000*
+//
+// into an Expr. AND binds tighter than OR, parentheses group, and NOT negates whatever follows it. Comparisons are
+// =, !=, <, <=, >, >=, [NOT] IN (a, b, ...) and [NOT] LIKE glob. Keywords are case insensitive. Values that contain
+// spaces or any of ()=!<>," have to be quoted with ' or ". An empty filter parses to nil.
+func Parse(filter string) (Expr, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("%w: unexpected %s", InvalidFilter, p.peek())
+	}
+
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	wordToken   tokenKind = iota // Field names, bare values and keywords
+	stringToken                  // Quoted values, never keywords
+	symbolToken                  // ( ) , and the comparison operators
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) String() string {
+	if t.kind == stringToken {
+		return fmt.Sprintf("%q", t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == wordToken && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isSymbol(symbol string) bool {
+	return t.kind == symbolToken && t.text == symbol
+}
+
+func isSpecial(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`()=!<>,"'`, r)
+}
+
+func lex(filter string) (tokens []token, err error) {
+	runes := []rune(filter)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',' || r == '=':
+			tokens = append(tokens, token{symbolToken, string(r)})
+			i++
+		case r == '!' || r == '<' || r == '>':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{symbolToken, string(runes[i : i+2])})
+				i += 2
+			} else if r == '!' {
+				return nil, fmt.Errorf("%w: '!' must be followed by '='", InvalidFilter)
+			} else {
+				tokens = append(tokens, token{symbolToken, string(r)})
+				i++
+			}
+		case r == '"' || r == '\'':
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated quote", InvalidFilter)
+			}
+			tokens = append(tokens, token{stringToken, text.String()})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !isSpecial(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{wordToken, string(runes[i:j])})
+			i = j
+		}
+	}
+
+	return
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{symbolToken, "end of filter"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	var or Or
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+
+		if !p.peek().isKeyword("OR") {
+			break
+		}
+		p.next()
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	var and And
+	for {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+
+		if !p.peek().isKeyword("AND") {
+			break
+		}
+		p.next()
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.isKeyword("NOT"):
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	case t.isSymbol("("):
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.next().isSymbol(")") {
+			return nil, fmt.Errorf("%w: missing ')'", InvalidFilter)
+		}
+		return expr, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	field := p.next()
+	if field.kind != wordToken || isKeyword(field) {
+		return nil, fmt.Errorf("%w: expected a field name, got %s", InvalidFilter, field)
+	}
+
+	negate := false
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		negate = true
+	}
+
+	comparison := Comparison{Field: field.text}
+	op := p.next()
+	switch {
+	case op.isKeyword("IN"):
+		comparison.Op = In
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = values
+	case op.isKeyword("LIKE"):
+		comparison.Op = Like
+	case negate:
+		return nil, fmt.Errorf("%w: NOT after %s must be followed by IN or LIKE", InvalidFilter, field)
+	case op.isSymbol("!="):
+		comparison.Op = Equal
+		negate = true
+	case op.kind == symbolToken && op.text != "(" && op.text != ")" && op.text != ",":
+		comparison.Op = Operator(op.text)
+	default:
+		return nil, fmt.Errorf("%w: expected a comparison after %s, got %s", InvalidFilter, field, op)
+	}
+
+	if comparison.Op != In {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = []string{value}
+	}
+
+	if negate {
+		return Not{comparison}, nil
+	}
+	return comparison, nil
+}
+
+func (p *parser) parseList() ([]string, error) {
+	if !p.next().isSymbol("(") {
+		return nil, fmt.Errorf("%w: IN must be followed by '('", InvalidFilter)
+	}
+
+	var values []string
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		t := p.next()
+		if t.isSymbol(")") {
+			return values, nil
+		}
+		if !t.isSymbol(",") {
+			return nil, fmt.Errorf("%w: expected ',' or ')' in IN list, got %s", InvalidFilter, t)
+		}
+	}
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind == symbolToken || isKeyword(t) {
+		return "", fmt.Errorf("%w: expected a value, got %s", InvalidFilter, t)
+	}
+	return t.text, nil
+}
+
+func isKeyword(t token) bool {
+	for _, keyword := range []string{"AND", "OR", "NOT", "IN", "LIKE"} {
+		if t.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobToLike converts a glob into a SQL LIKE pattern, using \ as the escape character.
+func GlobToLike(glob string) string {
+	var like strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			like.WriteRune('%')
+		case '?':
+			like.WriteRune('_')
+		case '%', '_', '\\':
+			like.WriteRune('\\')
+			like.WriteRune(r)
+		default:
+			like.WriteRune(r)
+		}
+	}
+	return like.String()
+}
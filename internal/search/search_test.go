@@ -0,0 +1,152 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SearchTestSuite) TestParseComparisons() {
+	tests := []struct {
+		filter   string
+		expected Expr
+	}{
+		{"", nil},
+		{"Role = Compute", Comparison{"Role", Equal, []string{"Compute"}}},
+		{"NID>=1000", Comparison{"NID", GreaterEqual, []string{"1000"}}},
+		{"extra_properties.NID < 10", Comparison{"extra_properties.NID", Less, []string{"10"}}},
+		{"Role != Compute", Not{Comparison{"Role", Equal, []string{"Compute"}}}},
+		{"type in (comptype_node, 'comptype_ncard')", Comparison{"type", In, []string{"comptype_node", "comptype_ncard"}}},
+		{"Role NOT IN (Compute)", Not{Comparison{"Role", In, []string{"Compute"}}}},
+		{"xname LIKE x3000c0s*", Comparison{"xname", Like, []string{"x3000c0s*"}}},
+		{"xname not like x3000*", Not{Comparison{"xname", Like, []string{"x3000*"}}}},
+		{`VendorName = "1/1/37"`, Comparison{"VendorName", Equal, []string{"1/1/37"}}},
+		{`FullName = 'Node Management Network'`, Comparison{"FullName", Equal, []string{"Node Management Network"}}},
+		{`Comment = "say \"hi\""`, Comparison{"Comment", Equal, []string{`say "hi"`}}},
+		{`Role = "AND"`, Comparison{"Role", Equal, []string{"AND"}}},
+	}
+
+	for _, test := range tests {
+		expr, err := Parse(test.filter)
+		suite.NoError(err, "Filter: %s", test.filter)
+		suite.Equal(test.expected, expr, "Filter: %s", test.filter)
+	}
+}
+
+func (suite *SearchTestSuite) TestParsePrecedence() {
+	expr, err := Parse("NID >= 1000 AND Role = Compute OR NOT (class = River AND xname LIKE x3000*)")
+	suite.NoError(err)
+	suite.Equal(Or{
+		And{
+			Comparison{"NID", GreaterEqual, []string{"1000"}},
+			Comparison{"Role", Equal, []string{"Compute"}},
+		},
+		Not{And{
+			Comparison{"class", Equal, []string{"River"}},
+			Comparison{"xname", Like, []string{"x3000*"}},
+		}},
+	}, expr)
+}
+
+func (suite *SearchTestSuite) TestParseErrors() {
+	filters := []string{
+		"Role",
+		"Role =",
+		"= Compute",
+		"Role = Compute AND",
+		"Role ! Compute",
+		"Role = 'Compute",
+		"(Role = Compute",
+		"Role = Compute)",
+		"Role IN Compute",
+		"Role IN (Compute",
+		"Role IN (Compute Service)",
+		"Role NOT = Compute",
+		"AND = Compute",
+		"Role = OR",
+	}
+
+	for _, filter := range filters {
+		_, err := Parse(filter)
+		suite.True(errors.Is(err, InvalidFilter), "Filter: %s, error: %v", filter, err)
+	}
+}
+
+func (suite *SearchTestSuite) TestWalk() {
+	expr, err := Parse("xname = X3000 OR NOT parent IN (X1000, x2000)")
+	suite.NoError(err)
+
+	var fields []string
+	expr = Walk(expr, func(comparison *Comparison) {
+		fields = append(fields, comparison.Field)
+		for i := range comparison.Values {
+			comparison.Values[i] = "changed"
+		}
+	})
+
+	suite.Equal([]string{"xname", "parent"}, fields)
+	suite.Equal(Or{
+		Comparison{"xname", Equal, []string{"changed"}},
+		Not{Comparison{"parent", In, []string{"changed", "changed"}}},
+	}, expr)
+}
+
+func (suite *SearchTestSuite) TestGlobToLike() {
+	suite.Equal("x3000c0s%", GlobToLike("x3000c0s*"))
+	suite.Equal("x3000c0s_b0", GlobToLike("x3000c0s?b0"))
+	suite.Equal(`100\%\_\\`, GlobToLike(`100%_\`))
+}
+
+func (suite *SearchTestSuite) TestParsePath() {
+	path, err := ParsePath("Subnets[].IPReservations[0].Name")
+	suite.NoError(err)
+	suite.Equal(Path{
+		{Key: "Subnets"},
+		{Each: true},
+		{Key: "IPReservations"},
+		{Key: "0", Index: true},
+		{Key: "Name"},
+	}, path)
+	suite.Equal("Subnets[].IPReservations[0].Name", path.String())
+
+	path, err = ParsePath("Networks.cn.HMN.VLan")
+	suite.NoError(err)
+	suite.Equal(Path{{Key: "Networks"}, {Key: "cn"}, {Key: "HMN"}, {Key: "VLan"}}, path)
+
+	for _, field := range []string{"", "Subnets.", ".Name", "Subnets[", "Subnets]", "Subnets[x]", "Subnets[-1]",
+		"Subnets[]Name", "[0]"} {
+		_, err := ParsePath(field)
+		suite.True(errors.Is(err, InvalidFilter), "Field: %s, error: %v", field, err)
+	}
+}
+
+func TestSearchSuite(t *testing.T) {
+	suite.Run(t, new(SearchTestSuite))
+}
@@ -0,0 +1,240 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// SignatureHeader holds the signature of a notification, see Sign.
+const SignatureHeader = "X-SLS-Signature"
+
+// How long a subscriber has to answer a notification before it is tried again.
+const deliveryTimeout = 10 * time.Second
+
+// ForbiddenAddress is returned for a subscriber at an address notifications aren't sent to, see Allowed.
+var ForbiddenAddress = errors.New("address is not allowed")
+
+// deniedNetworks are the loopback, private, link-local, multicast and unspecified networks. Subscribers there are
+// refused unless they are allowed, so a subscription can't be used to reach SLS itself, the metadata service of the
+// node it runs on or anything else that is only reachable from inside.
+var deniedNetworks, _ = ParseNetworks("0.0.0.0/8,10.0.0.0/8,100.64.0.0/10,127.0.0.0/8,169.254.0.0/16," +
+	"172.16.0.0/12,192.168.0.0/16,224.0.0.0/4,::/128,::1/128,fc00::/7,fe80::/10,ff00::/8")
+
+// ParseNetworks parses a comma separated list of networks in CIDR notation.
+func ParseNetworks(list string) (networks []*net.IPNet, err error) {
+	for _, cidr := range strings.Split(list, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, network, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		networks = append(networks, network)
+	}
+
+	return
+}
+
+func inNetworks(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns whether notifications can be sent to ip, which they can if it is in one of allowed or isn't
+// loopback, private, link-local, multicast or unspecified.
+func Allowed(ip net.IP, allowed []*net.IPNet) bool {
+	return inNetworks(allowed, ip) || !inNetworks(deniedNetworks, ip)
+}
+
+// CheckHost returns ForbiddenAddress unless every address host resolves to is Allowed.
+func CheckHost(host string, allowed []*net.IPNet) error {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %s: %w", host, err)
+	}
+
+	for _, ip := range ips {
+		if !Allowed(ip, allowed) {
+			return fmt.Errorf("%w: %s is %s", ForbiddenAddress, host, ip)
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefix(prefixes []string, xname string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(xname, base.NormalizeHMSCompID(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasType(types []sls_common.HMSStringType, xname string) bool {
+	hardwareType := sls_common.HMSTypeToHMSStringType(base.GetHMSType(xname))
+	for _, t := range types {
+		if t == hardwareType {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether event passes filter, see sls_common.SubscriptionFilter.
+func Matches(filter sls_common.SubscriptionFilter, event sls_common.Event) bool {
+	if len(filter.Operations) > 0 && !contains(filter.Operations, event.Operation) {
+		return false
+	}
+
+	hardwareFiltered := len(filter.HardwareTypes) > 0 || len(filter.XnamePrefixes) > 0
+	switch event.EntityType {
+	case "hardware":
+		if !hardwareFiltered {
+			return len(filter.NetworkNames) == 0
+		}
+		if len(filter.HardwareTypes) > 0 && !hasType(filter.HardwareTypes, event.Name) {
+			return false
+		}
+		return len(filter.XnamePrefixes) == 0 || hasPrefix(filter.XnamePrefixes, event.Name)
+	case "network":
+		if len(filter.NetworkNames) == 0 {
+			return !hardwareFiltered
+		}
+		return contains(filter.NetworkNames, event.Name)
+	}
+
+	return false
+}
+
+// Filter returns the events that pass filter.
+func Filter(filter sls_common.SubscriptionFilter, events []sls_common.Event) []sls_common.Event {
+	var matching []sls_common.Event
+	for _, event := range events {
+		if Matches(filter, event) {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// Sign returns the signature of body with secret, its HMAC-SHA256 in hex after "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewClient returns a client that retries a notification that fails with backoff. It only connects to addresses that
+// are Allowed, whatever the name it was given resolved to when the subscription was made.
+func NewClient(allowed []*net.IPNet) *retryablehttp.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !Allowed(ip, allowed) {
+				return fmt.Errorf("%w: %s", ForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient.Timeout = deliveryTimeout
+	if transport, ok := client.HTTPClient.Transport.(*http.Transport); ok {
+		// Through a proxy the address dialed wouldn't be the subscriber's.
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	}
+	// Left to itself it logs every URL it POSTs to on stderr, failures are logged by whoever delivers.
+	client.Logger = log.New(ioutil.Discard, "", 0)
+	return client
+}
+
+// Deliver POSTs notification to url, signed with secret. It fails unless the notification is accepted with a 2xx.
+func Deliver(ctx context.Context, client *retryablehttp.Client, url string, secret string,
+	notification sls_common.Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s answered %s", url, rsp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sls_common "github.com/Cray-HPE/hms-sls/pkg/sls-common"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriptionsTestSuite struct {
+	suite.Suite
+}
+
+// loopback lets the clients in these tests reach httptest servers.
+var loopback, _ = ParseNetworks("127.0.0.0/8, ::1/128")
+
+func event(entityType string, name string, operation string) sls_common.Event {
+	event := sls_common.Event{EntityType: entityType, Name: name}
+	event.Operation = operation
+	return event
+}
+
+func (suite *SubscriptionsTestSuite) TestMatches() {
+	node := event("hardware", "x3000c0s1b0n0", "update")
+	bmc := event("hardware", "x3000c0s1b0", "delete")
+	cabinet := event("hardware", "x1000", "insert")
+	hmn := event("network", "HMN", "update")
+
+	tests := []struct {
+		filter   sls_common.SubscriptionFilter
+		matching []sls_common.Event
+	}{
+		{sls_common.SubscriptionFilter{}, []sls_common.Event{node, bmc, cabinet, hmn}},
+		{sls_common.SubscriptionFilter{
+			HardwareTypes: []sls_common.HMSStringType{"comptype_node"},
+		}, []sls_common.Event{node}},
+		{sls_common.SubscriptionFilter{
+			XnamePrefixes: []string{"X3000"},
+		}, []sls_common.Event{node, bmc}},
+		{sls_common.SubscriptionFilter{
+			HardwareTypes: []sls_common.HMSStringType{"comptype_ncard", "comptype_cabinet"},
+			XnamePrefixes: []string{"x3000"},
+		}, []sls_common.Event{bmc}},
+		{sls_common.SubscriptionFilter{
+			NetworkNames: []string{"HMN", "NMN"},
+		}, []sls_common.Event{hmn}},
+		{sls_common.SubscriptionFilter{
+			XnamePrefixes: []string{"x1000"},
+			NetworkNames:  []string{"HMN"},
+		}, []sls_common.Event{cabinet, hmn}},
+		{sls_common.SubscriptionFilter{
+			Operations: []string{"update", "delete"},
+		}, []sls_common.Event{node, bmc, hmn}},
+		{sls_common.SubscriptionFilter{
+			NetworkNames: []string{"HMN"},
+			Operations:   []string{"insert"},
+		}, nil},
+	}
+
+	for _, test := range tests {
+		suite.Equal(test.matching, Filter(test.filter, []sls_common.Event{node, bmc, cabinet, hmn}),
+			"filter %+v", test.filter)
+	}
+}
+
+func (suite *SubscriptionsTestSuite) TestSign() {
+	// From RFC 4231, test case 2
+	suite.Equal("sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		Sign("Jefe", []byte("what do ya want for nothing?")))
+}
+
+func (suite *SubscriptionsTestSuite) TestDeliver() {
+	notification := sls_common.Notification{
+		SubscriptionID: 1,
+		Version:        42,
+		Events:         []sls_common.Event{event("hardware", "x3000c0s1b0n0", "update")},
+	}
+
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, err := ioutil.ReadAll(r.Body)
+		suite.NoError(err)
+		suite.Equal(Sign("secret", body), r.Header.Get(SignatureHeader))
+
+		var received sls_common.Notification
+		suite.NoError(json.Unmarshal(body, &received))
+		suite.Equal(notification, received)
+
+		// Fail the first time to be retried
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(loopback)
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+
+	err := Deliver(context.Background(), client, server.URL, "secret", notification)
+	suite.NoError(err)
+	suite.Equal(2, attempts)
+}
+
+func (suite *SubscriptionsTestSuite) TestDeliver_Fails() {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(loopback)
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+	client.RetryMax = 2
+
+	err := Deliver(context.Background(), client, server.URL, "secret", sls_common.Notification{})
+	suite.Error(err)
+	suite.Equal(3, attempts)
+
+	// Errors other than 5xx aren't retried
+	attempts = 0
+	err = Deliver(context.Background(), client, server.URL+"/gone", "secret", sls_common.Notification{})
+	suite.Error(err)
+	suite.Equal(1, attempts)
+}
+
+func (suite *SubscriptionsTestSuite) TestDeliver_Forbidden() {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(nil)
+	client.RetryMax = 0
+
+	err := Deliver(context.Background(), client, server.URL, "secret", sls_common.Notification{})
+	suite.Error(err)
+	suite.Equal(0, attempts)
+}
+
+func (suite *SubscriptionsTestSuite) TestAllowed() {
+	private, err := ParseNetworks("10.1.0.0/16")
+	suite.Require().NoError(err)
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"192.168.1.1", false},
+		{"0.0.0.0", false},
+		{"10.2.0.1", false},
+		{"10.1.0.1", true},
+	}
+	for _, test := range tests {
+		suite.Equal(test.allowed, Allowed(net.ParseIP(test.ip), private), test.ip)
+	}
+
+	_, err = ParseNetworks("10.1.0.0/16,nonsense")
+	suite.Error(err)
+
+	suite.True(errors.Is(CheckHost("localhost", nil), ForbiddenAddress))
+	suite.NoError(CheckHost("localhost", loopback))
+}
+
+func TestSubscriptionsSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionsTestSuite))
+}
@@ -0,0 +1,55 @@
+-- MIT License
+--
+-- (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a
+-- copy of this software and associated documentation files (the "Software"),
+-- to deal in the Software without restriction, including without limitation
+-- the rights to use, copy, modify, merge, publish, distribute, sublicense,
+-- and/or sell copies of the Software, and to permit persons to whom the
+-- Software is furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included
+-- in all copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+-- THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+-- OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+-- ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+-- OTHER DEALINGS IN THE SOFTWARE.
+
+
+-- Every subscription is sent a notification of the changes passing its filter in each version after last_version. One
+-- instance of SLS at a time delivers them, the one holding the lease until leased_until.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id           BIGSERIAL   NOT NULL
+        CONSTRAINT subscriptions_id_pk
+            PRIMARY KEY,
+    url          VARCHAR     NOT NULL,
+    secret       VARCHAR     NOT NULL,
+    filter       JSONB       NOT NULL,
+    documents    BOOLEAN     NOT NULL DEFAULT FALSE,
+    created      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_version BIGINT      NOT NULL,
+    leased_until TIMESTAMPTZ
+);
+
+-- Notifications that could not be delivered, kept until their subscription is deleted.
+CREATE TABLE IF NOT EXISTS subscription_dead_letters (
+    id              BIGSERIAL   NOT NULL
+        CONSTRAINT subscription_dead_letters_id_pk
+            PRIMARY KEY,
+    subscription_id BIGINT      NOT NULL
+        CONSTRAINT subscription_dead_letters_subscription_id_fk
+            REFERENCES subscriptions(id)
+            ON DELETE CASCADE,
+    version         BIGINT      NOT NULL,
+    timestamp       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    error           VARCHAR     NOT NULL,
+    notification    JSONB       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS subscription_dead_letters_subscription_id_index
+    ON subscription_dead_letters(subscription_id, id);
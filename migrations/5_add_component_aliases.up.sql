@@ -0,0 +1,81 @@
+-- MIT License
+--
+-- (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a
+-- copy of this software and associated documentation files (the "Software"),
+-- to deal in the Software without restriction, including without limitation
+-- the rights to use, copy, modify, merge, publish, distribute, sublicense,
+-- and/or sell copies of the Software, and to permit persons to whom the
+-- Software is furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included
+-- in all copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+-- THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+-- OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+-- ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+-- OTHER DEALINGS IN THE SOFTWARE.
+
+
+-- Aliases are the names people use for hardware, such as ncn-w001. They live in the ExtraProperties of the
+-- hardware, and are copied here lower cased so that an alias can only ever belong to one xname.
+CREATE TABLE IF NOT EXISTS component_aliases (
+    alias VARCHAR NOT NULL
+        CONSTRAINT component_aliases_alias_pk
+            PRIMARY KEY,
+    xname VARCHAR NOT NULL
+        CONSTRAINT component_aliases_xname_fk
+            REFERENCES components(xname) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS component_aliases_xname_index
+    ON component_aliases(xname);
+
+-- Existing hardware can't share an alias, there would be no telling which xname it belongs to. Fail the upgrade
+-- listing them so they can be sorted out first.
+DO $$
+DECLARE
+    shared TEXT;
+BEGIN
+    SELECT
+        string_agg(alias || ' (' || xnames || ')', ', ' ORDER BY alias)
+    INTO
+        shared
+    FROM (
+        SELECT
+            lower(alias) AS alias,
+            string_agg(DISTINCT xname, ', ' ORDER BY xname) AS xnames
+        FROM
+            components,
+            jsonb_array_elements_text(CASE jsonb_typeof(extra_properties->'Aliases')
+                WHEN 'array' THEN extra_properties->'Aliases' ELSE '[]' END) AS alias
+        GROUP BY
+            lower(alias)
+        HAVING
+            count(DISTINCT xname) > 1
+    ) AS aliases;
+
+    IF shared IS NOT NULL THEN
+        RAISE EXCEPTION 'aliases used by more than one xname: %', shared
+            USING HINT = 'Remove each alias from all but one xname and try again.';
+    END IF;
+END
+$$;
+
+-- An xname can list the same alias more than once, in different cases.
+INSERT INTO
+    component_aliases(alias, xname)
+SELECT DISTINCT ON (lower(alias))
+    lower(alias),
+    xname
+FROM
+    components,
+    jsonb_array_elements_text(CASE jsonb_typeof(extra_properties->'Aliases')
+        WHEN 'array' THEN extra_properties->'Aliases' ELSE '[]' END) AS alias
+ORDER BY
+    lower(alias), xname
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,82 @@
+-- MIT License
+--
+-- (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a
+-- copy of this software and associated documentation files (the "Software"),
+-- to deal in the Software without restriction, including without limitation
+-- the rights to use, copy, modify, merge, publish, distribute, sublicense,
+-- and/or sell copies of the Software, and to permit persons to whom the
+-- Software is furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included
+-- in all copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+-- THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+-- OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+-- ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+-- OTHER DEALINGS IN THE SOFTWARE.
+
+
+-- A NID can only ever belong to one node. The NIDs live in the ExtraProperties of the nodes, and are copied here so
+-- the primary key can keep it that way.
+CREATE TABLE IF NOT EXISTS component_nids (
+    nid BIGINT NOT NULL
+        CONSTRAINT component_nids_nid_pk
+            PRIMARY KEY,
+    xname VARCHAR NOT NULL
+        CONSTRAINT component_nids_xname_fk
+            REFERENCES components(xname) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS component_nids_xname_index
+    ON component_nids(xname);
+
+-- Existing nodes can't share a NID, there would be no telling which node it belongs to. Fail the upgrade listing
+-- them so they can be sorted out first.
+DO $$
+DECLARE
+    shared TEXT;
+BEGIN
+    SELECT
+        string_agg(nid || ' (' || xnames || ')', ', ' ORDER BY nid)
+    INTO
+        shared
+    FROM (
+        SELECT
+            (extra_properties ->> 'NID')::bigint AS nid,
+            string_agg(xname, ', ' ORDER BY xname) AS xnames
+        FROM
+            components
+        WHERE
+            comp_type = 'comptype_node'
+            AND jsonb_typeof(extra_properties -> 'NID') = 'number'
+            AND extra_properties ->> 'NID' ~ '^[1-9][0-9]{0,17}$'
+        GROUP BY
+            (extra_properties ->> 'NID')::bigint
+        HAVING
+            count(*) > 1
+    ) AS nids;
+
+    IF shared IS NOT NULL THEN
+        RAISE EXCEPTION 'NIDs used by more than one node: %', shared
+            USING HINT = 'Give each of these nodes its own NID and try again.';
+    END IF;
+END
+$$;
+
+INSERT INTO
+    component_nids(nid, xname)
+SELECT
+    (extra_properties ->> 'NID')::bigint,
+    xname
+FROM
+    components
+WHERE
+    comp_type = 'comptype_node'
+    AND jsonb_typeof(extra_properties -> 'NID') = 'number'
+    AND extra_properties ->> 'NID' ~ '^[1-9][0-9]{0,17}$'
+ON CONFLICT DO NOTHING;
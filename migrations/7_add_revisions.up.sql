@@ -0,0 +1,179 @@
+-- MIT License
+--
+-- (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a
+-- copy of this software and associated documentation files (the "Software"),
+-- to deal in the Software without restriction, including without limitation
+-- the rights to use, copy, modify, merge, publish, distribute, sublicense,
+-- and/or sell copies of the Software, and to permit persons to whom the
+-- Software is furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included
+-- in all copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+-- THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+-- OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+-- ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+-- OTHER DEALINGS IN THE SOFTWARE.
+
+
+-- Every insert, update and delete of hardware or a network is recorded here with the whole object before and after,
+-- so what something used to be can be seen after it changes. Rows outlive the hardware or network they are about.
+CREATE TABLE IF NOT EXISTS revisions (
+    revision    BIGSERIAL NOT NULL
+        CONSTRAINT revisions_revision_pk
+            PRIMARY KEY,
+    version     BIGINT    NOT NULL
+        CONSTRAINT revisions_version_fk
+            REFERENCES version_history(version),
+    entity_type VARCHAR   NOT NULL,
+    entity_name VARCHAR   NOT NULL,
+    operation   VARCHAR   NOT NULL,
+    before      JSONB,
+    after       JSONB
+);
+
+CREATE INDEX IF NOT EXISTS revisions_entity_index
+    ON revisions(entity_type, entity_name, revision);
+
+CREATE INDEX IF NOT EXISTS revisions_version_index
+    ON revisions(version);
+
+-- A row that is deleted keeps the version it was last updated in, so the version of a delete is the one the
+-- transaction deleting it made, which IncrementVersion puts in sls.version for the rest of the transaction. A
+-- transaction that deletes without having made a version fails rather than having its delete put down to somebody
+-- else's version.
+CREATE OR REPLACE FUNCTION revisions_current_version() RETURNS BIGINT AS $$
+DECLARE
+    current_version TEXT;
+BEGIN
+    -- Once set in a session it reads as empty rather than missing after the transaction that set it.
+    current_version := NULLIF(current_setting('sls.version', true), '');
+
+    IF current_version IS NULL THEN
+        RAISE EXCEPTION 'hardware or networks deleted by a transaction that made no version'
+            USING ERRCODE = 'object_not_in_prerequisite_state',
+                HINT = 'Insert into version_history and set sls.version in the same transaction before deleting.';
+    END IF;
+
+    RETURN current_version::BIGINT;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION components_revision_document(hardware components) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'Parent', hardware.parent,
+        'Xname', hardware.xname,
+        'Type', hardware.comp_type,
+        'Class', hardware.comp_class,
+        'ExtraProperties', hardware.extra_properties);
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION network_revision_document(nw network) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'Name', nw.name,
+        'FullName', nw.full_name,
+        'IPRanges', to_jsonb(nw.ip_ranges),
+        'Type', nw.type,
+        'ExtraProperties', nw.extra_properties);
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION components_revision() RETURNS TRIGGER AS $$
+DECLARE
+    before_document JSONB;
+    after_document  JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        after_document := components_revision_document(NEW);
+        INSERT INTO revisions(version, entity_type, entity_name, operation, after)
+        VALUES (NEW.last_updated_version, 'hardware', NEW.xname, 'insert', after_document);
+    ELSIF TG_OP = 'UPDATE' THEN
+        before_document := components_revision_document(OLD);
+        after_document := components_revision_document(NEW);
+        -- Keeping links in sync rewrites rows that often end up the same.
+        IF before_document IS DISTINCT FROM after_document THEN
+            INSERT INTO revisions(version, entity_type, entity_name, operation, before, after)
+            VALUES (NEW.last_updated_version, 'hardware', NEW.xname, 'update', before_document, after_document);
+        END IF;
+    ELSE
+        before_document := components_revision_document(OLD);
+        INSERT INTO revisions(version, entity_type, entity_name, operation, before)
+        VALUES (revisions_current_version(), 'hardware', OLD.xname, 'delete', before_document);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION network_revision() RETURNS TRIGGER AS $$
+DECLARE
+    before_document JSONB;
+    after_document  JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        after_document := network_revision_document(NEW);
+        INSERT INTO revisions(version, entity_type, entity_name, operation, after)
+        VALUES (NEW.last_updated_version, 'network', NEW.name, 'insert', after_document);
+    ELSIF TG_OP = 'UPDATE' THEN
+        before_document := network_revision_document(OLD);
+        after_document := network_revision_document(NEW);
+        IF before_document IS DISTINCT FROM after_document THEN
+            INSERT INTO revisions(version, entity_type, entity_name, operation, before, after)
+            VALUES (NEW.last_updated_version, 'network', NEW.name, 'update', before_document, after_document);
+        END IF;
+    ELSE
+        before_document := network_revision_document(OLD);
+        INSERT INTO revisions(version, entity_type, entity_name, operation, before)
+        VALUES (revisions_current_version(), 'network', OLD.name, 'delete', before_document);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- TRUNCATE skips the row triggers, so everything about to go is recorded as deleted first.
+CREATE OR REPLACE FUNCTION components_truncate_revision() RETURNS TRIGGER AS $$
+DECLARE
+    current_version BIGINT := revisions_current_version();
+BEGIN
+    INSERT INTO revisions(version, entity_type, entity_name, operation, before)
+    SELECT current_version, 'hardware', xname, 'delete', components_revision_document(components)
+    FROM components
+    ORDER BY xname;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION network_truncate_revision() RETURNS TRIGGER AS $$
+DECLARE
+    current_version BIGINT := revisions_current_version();
+BEGIN
+    INSERT INTO revisions(version, entity_type, entity_name, operation, before)
+    SELECT current_version, 'network', name, 'delete', network_revision_document(network)
+    FROM network
+    ORDER BY name;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS components_revision ON components;
+CREATE TRIGGER components_revision
+    AFTER INSERT OR UPDATE OR DELETE ON components
+    FOR EACH ROW EXECUTE PROCEDURE components_revision();
+
+DROP TRIGGER IF EXISTS components_truncate_revision ON components;
+CREATE TRIGGER components_truncate_revision
+    BEFORE TRUNCATE ON components
+    FOR EACH STATEMENT EXECUTE PROCEDURE components_truncate_revision();
+
+DROP TRIGGER IF EXISTS network_revision ON network;
+CREATE TRIGGER network_revision
+    AFTER INSERT OR UPDATE OR DELETE ON network
+    FOR EACH ROW EXECUTE PROCEDURE network_revision();
+
+DROP TRIGGER IF EXISTS network_truncate_revision ON network;
+CREATE TRIGGER network_truncate_revision
+    BEFORE TRUNCATE ON network
+    FOR EACH STATEMENT EXECUTE PROCEDURE network_truncate_revision();
@@ -23,6 +23,7 @@
 package sls_common
 
 import (
+	"sort"
 	"strings"
 
 	base "github.com/Cray-HPE/hms-base"
@@ -38,6 +39,31 @@ type SLSState struct {
 	Networks map[string]Network         `json:"Networks"`
 }
 
+/*
+BulkHardwareStatus is what happened to one object of a bulk hardware write.
+*/
+type BulkHardwareStatus string
+
+const (
+	BulkHardwareCreated    BulkHardwareStatus = "Created"    // Written, did not exist before
+	BulkHardwareUpdated    BulkHardwareStatus = "Updated"    // Written over an existing object
+	BulkHardwareInvalid    BulkHardwareStatus = "Invalid"    // Failed validation, not written
+	BulkHardwareFailed     BulkHardwareStatus = "Failed"     // Could not be written to the database
+	BulkHardwareNotApplied BulkHardwareStatus = "NotApplied" // Valid, but rolled back with the rest of the request
+)
+
+type BulkHardwareResult struct {
+	Xname  string             `json:"Xname"`
+	Status BulkHardwareStatus `json:"Status"`
+	Error  string             `json:"Error,omitempty"`
+}
+
+type BulkHardwareResponse struct {
+	Atomic  bool                 `json:"Atomic"`
+	Version int64                `json:"Version"` //Version the changes were written in, 0 if nothing was written
+	Results []BulkHardwareResult `json:"Results"`
+}
+
 /*
 CabinetType tells us what physical hardware profile is in use.  One of
 River, Mountain or Hill.
@@ -412,6 +438,19 @@ func HMSTypeToHMSStringType(str base.HMSType) HMSStringType {
 	return hmsTypeHMSStringTypeTable["invalid"].HMSStringType
 }
 
+/*
+HMSStringTypes returns every HMSStringType this module knows about, sorted.
+*/
+func HMSStringTypes() []HMSStringType {
+	types := make([]HMSStringType, 0, len(hmsTypeHMSStringTypeTable))
+	for _, tabEntry := range hmsTypeHMSStringTypeTable {
+		types = append(types, tabEntry.HMSStringType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return types
+}
+
 /*
 Verify that a cabinet type/class is valid.
 */
@@ -0,0 +1,154 @@
+// MIT License
+//
+// (C) Copyright 2021 Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package sls_common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+/*
+ExtraPropertiesTypes maps each HMSStringType that can have ExtraProperties to
+the struct they are made of.  Hardware of any other type has no
+ExtraProperties, unless it is in ExtraPropertiesUnchecked.
+*/
+var ExtraPropertiesTypes = map[HMSStringType]reflect.Type{
+	Cabinet:             reflect.TypeOf(ComptypeCabinet{}),
+	CabinetPDUNic:       reflect.TypeOf(ComptypeCabPduNic{}),
+	CDUMgmtSwitch:       reflect.TypeOf(ComptypeCDUMgmtSwitch{}),
+	ChassisBMC:          reflect.TypeOf(ComptypeChassisBmc{}),
+	ComputeModule:       reflect.TypeOf(comptypeCompmod{}),
+	HSNConnector:        reflect.TypeOf(ComptypeHSNConnector{}),
+	MgmtHLSwitch:        reflect.TypeOf(ComptypeMgmtHLSwitch{}),
+	MgmtSwitch:          reflect.TypeOf(ComptypeMgmtSwitch{}),
+	MgmtSwitchConnector: reflect.TypeOf(ComptypeMgmtSwitchConnector{}),
+	Node:                reflect.TypeOf(ComptypeNode{}),
+	NodeBMC:             reflect.TypeOf(ComptypeNodeBmc{}),
+	NodeBMCNic:          reflect.TypeOf(ComptypeBmcNic{}),
+	NodeHsnNIC:          reflect.TypeOf(ComptypeNodeHsnNic{}),
+	NodeNIC:             reflect.TypeOf(ComptypeNodeNic{}),
+	NodePowerConnector:  reflect.TypeOf(ComptypeCompmodPowerConnector{}),
+	RouterBMC:           reflect.TypeOf(ComptypeRtrBmc{}),
+	RouterBMCNic:        reflect.TypeOf(ComptypeRtrBmcNic{}),
+	RouterModule:        reflect.TypeOf(ComptypeRtrMod{}),
+}
+
+/*
+comptypeCompmod is what the ExtraProperties of a ComputeModule are checked
+against.  Besides the fields of ComptypeCompmod, SLS files list the
+NodePowerConnectors of a blade in PoweredBy.
+*/
+type comptypeCompmod struct {
+	PowerConnector string   `json:"PowerConenctor,omitempty"`
+	PoweredBy      []string `json:"PoweredBy,omitempty"`
+}
+
+/*
+ExtraPropertiesUnchecked lists the HMSStringTypes that have ExtraProperties
+SLS has no struct for.  They are stored as given.
+*/
+var ExtraPropertiesUnchecked = map[HMSStringType]bool{
+	CabinetPDUPowerConnector: true,
+}
+
+/*
+ExtraPropertiesRequired lists the ExtraProperties that hardware of each
+HMSStringType must have.
+*/
+var ExtraPropertiesRequired = map[HMSStringType][]string{
+	HSNConnector:        {"NodeNics"},
+	MgmtSwitchConnector: {"NodeNics"},
+	NodePowerConnector:  {"PoweredBy"},
+}
+
+/*
+ExtraPropertiesLinks maps each HMSStringType that is linked to other hardware
+to the ExtraProperty that holds the xnames of its peers.  Links go both ways:
+a connector lists the NICs plugged into it in NodeNics and each of those NICs
+lists the connector in Peers.
+*/
+var ExtraPropertiesLinks = map[HMSStringType]string{
+	CabinetPDUNic:       "Peers",
+	HSNConnector:        "NodeNics",
+	MgmtSwitchConnector: "NodeNics",
+	NodeBMCNic:          "Peers",
+	NodeHsnNIC:          "Peers",
+	NodeNIC:             "Peers",
+	RouterBMCNic:        "Peers",
+}
+
+/*
+LinkedXnames returns the xnames in the link property of hardware (see
+ExtraPropertiesLinks) in lower case.  Hardware of a type without a link
+property, or whose link property isn't a list of strings, has none.
+*/
+func LinkedXnames(hardware GenericHardware) []string {
+	property, ok := ExtraPropertiesLinks[hardware.Type]
+	if !ok || hardware.ExtraPropertiesRaw == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(hardware.ExtraPropertiesRaw)
+	if err != nil {
+		return nil
+	}
+
+	var properties map[string]json.RawMessage
+	if json.Unmarshal(raw, &properties) != nil {
+		return nil
+	}
+
+	var xnames []string
+	if json.Unmarshal(properties[property], &xnames) != nil {
+		return nil
+	}
+
+	for i := range xnames {
+		xnames[i] = strings.ToLower(xnames[i])
+	}
+
+	return xnames
+}
+
+/*
+JSONFieldName returns the name a struct field has in JSON, or "" if the
+field is never marshalled.
+*/
+func JSONFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
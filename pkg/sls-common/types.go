@@ -48,6 +48,173 @@ type GenericHardware struct {
 
 type GenericHardwareArray []GenericHardware
 
+/*
+HardwareReference is a property in the ExtraProperties of Xname that holds
+the xname of the Target hardware.
+*/
+type HardwareReference struct {
+	Xname    string `json:"Xname"`
+	Property string `json:"Property"`
+	Target   string `json:"Target"`
+}
+
+/*
+HardwareDeleteResult lists the hardware a DELETE removed, or would remove,
+along with the references other hardware had to it, which go with it.
+*/
+type HardwareDeleteResult struct {
+	DryRun     bool                `json:"DryRun"`
+	Xnames     []string            `json:"Xnames"`
+	References []HardwareReference `json:"References"`
+}
+
+/*
+HardwareNames lists the names the hardware with Xname goes by: the Aliases
+in its ExtraProperties and the IPReservations made for it in any network.
+*/
+type HardwareNames struct {
+	Xname          string                 `json:"Xname"`
+	Aliases        []string               `json:"Aliases"`
+	IPReservations []NetworkIPReservation `json:"IPReservations"`
+}
+
+/*
+HardwareConnection is a port on a switch, a connector of Type
+MgmtSwitchConnector or HSNConnector, along with the Devices in its NodeNics
+that are plugged into it.
+*/
+type HardwareConnection struct {
+	Switch     string        `json:"Switch"`
+	Port       string        `json:"Port"`
+	Type       HMSStringType `json:"Type"`
+	VendorName string        `json:"VendorName,omitempty"`
+	Devices    []string      `json:"Devices"`
+}
+
+/*
+HardwareConnections lists what the hardware with Xname is plugged into, or
+what is plugged into it.
+*/
+type HardwareConnections struct {
+	Xname       string               `json:"Xname"`
+	Connections []HardwareConnection `json:"Connections"`
+}
+
+/*
+HardwareNetworks lists the networks the hardware with Xname is on, as set up
+in the Networks of its Cabinet for Profile, "cn" or "ncn".
+*/
+type HardwareNetworks struct {
+	Xname    string            `json:"Xname"`
+	Cabinet  string            `json:"Cabinet"`
+	Profile  string            `json:"Profile"`
+	Networks []HardwareNetwork `json:"Networks"`
+}
+
+/*
+HardwareNetwork is the effective configuration of the network Name of a
+cabinet.  Subnet is the subnet of the top-level Network with the same CIDR,
+which fills in the Gateway and VLan the cabinet leaves out.
+*/
+type HardwareNetwork struct {
+	Name string `json:"Name"`
+	CabinetNetworks
+	Network string      `json:"Network,omitempty"`
+	Subnet  *IPV4Subnet `json:"Subnet,omitempty"`
+}
+
+/*
+PowerHop is hardware in a power chain along with the hardware it draws power
+from directly.  Depth is how many hops away it is from where the chain
+starts.
+*/
+type PowerHop struct {
+	Xname     string        `json:"Xname"`
+	Type      HMSStringType `json:"Type"`
+	Depth     int           `json:"Depth"`
+	PoweredBy []string      `json:"PoweredBy"`
+}
+
+/*
+PowerTopology lists the hardware Xname draws power from when Direction is
+"upstream", or the hardware that loses power along with Xname when it is
+"downstream", ordered by Depth.
+*/
+type PowerTopology struct {
+	Xname     string     `json:"Xname"`
+	Direction string     `json:"Direction"`
+	Hardware  []PowerHop `json:"Hardware"`
+}
+
+/*
+NetworkIPReservation is an IPReservation along with the network and subnet
+it was made in.
+*/
+type NetworkIPReservation struct {
+	Network string `json:"Network"`
+	Subnet  string `json:"Subnet"`
+	IPReservation
+}
+
+/*
+NodeNID is the NID of the node with Xname.
+*/
+type NodeNID struct {
+	NID   int    `json:"NID"`
+	Xname string `json:"Xname,omitempty"`
+}
+
+/*
+NIDRange is the NIDs from First up to and including Last.
+*/
+type NIDRange struct {
+	First int `json:"First"`
+	Last  int `json:"Last"`
+}
+
+/*
+NIDMap is every NID in use ordered by NID, along with the unused ranges
+between the lowest and highest NID, the NIDs used by more than one node
+and the nodes without a NID.
+*/
+type NIDMap struct {
+	NIDs       []NodeNID  `json:"NIDs"`
+	Gaps       []NIDRange `json:"Gaps"`
+	Duplicates []int      `json:"Duplicates"`
+	Unassigned []string   `json:"Unassigned"`
+}
+
+/*
+NIDAllocationRequest asks for either Count free NIDs, or for a NID for every
+node below the cabinet or chassis Xname that does not have one yet.  No NID
+below StartingNID is handed out.
+*/
+type NIDAllocationRequest struct {
+	Count       int    `json:"Count,omitempty"`
+	Xname       string `json:"Xname,omitempty"`
+	StartingNID int    `json:"StartingNID,omitempty"`
+}
+
+/*
+NIDAllocation is the NIDs handed out for a NIDAllocationRequest, along with
+the version they were written in if they were assigned to nodes, or the time
+they are reserved until if they were not.
+*/
+type NIDAllocation struct {
+	Version       int64     `json:"Version,omitempty"`
+	ReservedUntil string    `json:"ReservedUntil,omitempty"`
+	NIDs          []NodeNID `json:"NIDs"`
+}
+
+/*
+GenericHardwareTree is a hardware object along with the trees of the
+hardware objects below it.
+*/
+type GenericHardwareTree struct {
+	GenericHardware
+	ChildHardware []GenericHardwareTree `json:"ChildHardware,omitempty"`
+}
+
 /*
 GetParent returns the string xname of the parent of this object.
 */
@@ -373,6 +540,246 @@ type IPV4Subnet struct {
 
 type NetworkArray []Network
 
+/*
+RevisionInfo says when a change to hardware or a network was made and how.
+Version is the version of SLS the change was made in, UpdatedEntity is what
+that version was made for (the xname or network written, or the whole load
+for something like a loadstate), and Operation is "insert", "update" or
+"delete".
+*/
+type RevisionInfo struct {
+	Revision      int64  `json:"Revision"`
+	Version       int64  `json:"Version"`
+	Timestamp     int64  `json:"Timestamp"`
+	TimestampTime string `json:"TimestampTime"`
+	UpdatedEntity string `json:"UpdatedEntity"`
+	Operation     string `json:"Operation"`
+}
+
+/*
+HardwareRevision is one change to hardware.  Before is missing for an insert
+and After is missing for a delete.
+*/
+type HardwareRevision struct {
+	RevisionInfo
+	Before *GenericHardware `json:"Before,omitempty"`
+	After  *GenericHardware `json:"After,omitempty"`
+}
+
+/*
+HardwareHistory is a page of the changes made to the hardware with Xname,
+newest first.  Total is how many changes there are in all.
+*/
+type HardwareHistory struct {
+	Xname     string             `json:"Xname"`
+	Total     int64              `json:"Total"`
+	Revisions []HardwareRevision `json:"Revisions"`
+}
+
+/*
+NetworkRevision is one change to a network.  Before is missing for an insert
+and After is missing for a delete.
+*/
+type NetworkRevision struct {
+	RevisionInfo
+	Before *Network `json:"Before,omitempty"`
+	After  *Network `json:"After,omitempty"`
+}
+
+/*
+NetworkHistory is a page of the changes made to the network with Name, newest
+first.  Total is how many changes there are in all.
+*/
+type NetworkHistory struct {
+	Name      string            `json:"Name"`
+	Total     int64             `json:"Total"`
+	Revisions []NetworkRevision `json:"Revisions"`
+}
+
+/*
+RestoreRequest asks for SLS to be restored to how it was at Version, or at
+the time AsOf in RFC 3339 format.  Only one of them can be given.
+*/
+type RestoreRequest struct {
+	Version int64  `json:"Version,omitempty"`
+	AsOf    string `json:"AsOf,omitempty"`
+}
+
+/*
+RestoreCounts is how many objects a restore inserted, updated and deleted.
+*/
+type RestoreCounts struct {
+	Inserted int64 `json:"Inserted"`
+	Updated  int64 `json:"Updated"`
+	Deleted  int64 `json:"Deleted"`
+}
+
+/*
+RestoreResult is what a restore to RestoredVersion did.  Version is the new
+version the restore was recorded as.
+*/
+type RestoreResult struct {
+	Version         int64         `json:"Version"`
+	RestoredVersion int64         `json:"RestoredVersion"`
+	Hardware        RestoreCounts `json:"Hardware"`
+	Networks        RestoreCounts `json:"Networks"`
+}
+
+/*
+FieldChange is one difference within an object.  Path is the JSON pointer
+of the field, such as /ExtraProperties/NID, and Op is "add", "remove" or
+"replace" like in a JSON Patch.  From is missing for an add and To for a
+remove.
+*/
+type FieldChange struct {
+	Path string      `json:"Path"`
+	Op   string      `json:"Op"`
+	From interface{} `json:"From,omitempty"`
+	To   interface{} `json:"To,omitempty"`
+}
+
+/*
+ObjectDiff is how the hardware or network called Name changed.
+*/
+type ObjectDiff struct {
+	Name    string        `json:"Name"`
+	Changes []FieldChange `json:"Changes"`
+}
+
+/*
+HardwareDiff is the hardware that was added, removed or modified, each
+ordered by xname.
+*/
+type HardwareDiff struct {
+	Added    []GenericHardware `json:"Added"`
+	Removed  []GenericHardware `json:"Removed"`
+	Modified []ObjectDiff      `json:"Modified"`
+}
+
+/*
+NetworkDiff is the networks that were added, removed or modified, each
+ordered by name.
+*/
+type NetworkDiff struct {
+	Added    []Network    `json:"Added"`
+	Removed  []Network    `json:"Removed"`
+	Modified []ObjectDiff `json:"Modified"`
+}
+
+/*
+SLSDiff is what changed between From and To, each either an SLS version
+such as "42" or "upload" for an SLSState that was sent.
+*/
+type SLSDiff struct {
+	From     string       `json:"From"`
+	To       string       `json:"To"`
+	Hardware HardwareDiff `json:"Hardware"`
+	Networks NetworkDiff  `json:"Networks"`
+}
+
+/*
+Event is one change to hardware or a network as sent by /events.  EntityType
+is "hardware" or "network" and Name is its xname or network name.  Document
+is what it was changed to, if that was asked for and it wasn't deleted.
+*/
+type Event struct {
+	RevisionInfo
+	EntityType string      `json:"EntityType"`
+	Name       string      `json:"Name"`
+	Document   interface{} `json:"Document,omitempty"`
+}
+
+/*
+SubscriptionFilter picks the changes a subscription is notified of.  Every
+field that is set has to match: hardware has to be one of HardwareTypes and
+have an xname starting with one of XnamePrefixes, networks have to be one of
+NetworkNames, and changes have to be one of Operations.  Setting only
+hardware fields leaves out networks, and setting only NetworkNames leaves out
+hardware.
+*/
+type SubscriptionFilter struct {
+	HardwareTypes []HMSStringType `json:"HardwareTypes,omitempty"`
+	XnamePrefixes []string        `json:"XnamePrefixes,omitempty"`
+	NetworkNames  []string        `json:"NetworkNames,omitempty"`
+	Operations    []string        `json:"Operations,omitempty"`
+}
+
+/*
+Subscription has SLS POST a Notification to URL for every version with
+changes passing Filter, including what was changed to if Documents is set.
+Notifications are signed with Secret, which is only returned when the
+subscription is created.  Version is the last version it was notified of.
+*/
+type Subscription struct {
+	ID          int64              `json:"ID"`
+	URL         string             `json:"URL"`
+	Secret      string             `json:"Secret,omitempty"`
+	Filter      SubscriptionFilter `json:"Filter"`
+	Documents   bool               `json:"Documents"`
+	Created     int64              `json:"Created"`
+	CreatedTime string             `json:"CreatedTime"`
+	Version     int64              `json:"Version"`
+}
+
+/*
+Notification is what a subscription is sent, the changes passing its filter
+that were made in Version.
+*/
+type Notification struct {
+	SubscriptionID int64   `json:"SubscriptionID"`
+	Version        int64   `json:"Version"`
+	Events         []Event `json:"Events"`
+}
+
+/*
+DeadLetter is a notification that could not be delivered, and Error is why.
+*/
+type DeadLetter struct {
+	ID            int64        `json:"ID"`
+	Timestamp     int64        `json:"Timestamp"`
+	TimestampTime string       `json:"TimestampTime"`
+	Error         string       `json:"Error"`
+	Notification  Notification `json:"Notification"`
+}
+
+/*
+Snapshot is a copy of all hardware and networks kept by SLS under Name, as
+they were at Version.  It holds no Vault data.
+*/
+type Snapshot struct {
+	Name          string `json:"Name"`
+	Comment       string `json:"Comment"`
+	Version       int64  `json:"Version"`
+	Timestamp     int64  `json:"Timestamp"`
+	TimestampTime string `json:"TimestampTime"`
+}
+
+/*
+SnapshotRestoreResult is the new Version restoring Snapshot was recorded as.
+*/
+type SnapshotRestoreResult struct {
+	Version  int64    `json:"Version"`
+	Snapshot Snapshot `json:"Snapshot"`
+}
+
+/*
+ValidationError is a problem with the object under Key in an uploaded SLS
+state.  Key is a JSON pointer such as /Hardware/x3000c0s1b0n0.
+*/
+type ValidationError struct {
+	Key   string `json:"Key"`
+	Error string `json:"Error"`
+}
+
+/*
+ValidationProblem is an RFC 7807 problem listing every error found in an
+uploaded SLS state.
+*/
+type ValidationProblem struct {
+	base.ProblemDetails
+	Errors []ValidationError `json:"errors"`
+}
+
 // SLSGeneratorInputState is given to the SLS config generator in order to generator the SLS config file
 type SLSGeneratorInputState struct {
 	ManagementSwitches  map[string]GenericHardware `json:"ManagementSwitches"` // SLS Type: comptype_mgmt_switch